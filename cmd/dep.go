@@ -14,12 +14,17 @@ var depCmd = &cobra.Command{
 	Use:   "dep [flags] [args]",
 	Short: "Runs dep ensure for the project",
 	Long: `Executes "dep ensure" using the bundled version of dep with the provided flags and arguments. The "--" separator must 
-be used before specifying any flags for the "dep" program. For example, "./godelw dep -- -v" executes "dep ensure -v".`,
+be used before specifying any flags for the "dep" program. For example, "./godelw dep -- -v" executes "dep ensure -v".
+Any "ensure-args" specified in the plugin configuration are provided before the command-line arguments.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		param, err := depParam()
+		if err != nil {
+			return err
+		}
 		if verifyFlagVal {
-			return depplugin.Verify()
+			return depplugin.Verify(param, cmd.OutOrStdout(), cmd.OutOrStderr())
 		}
-		return depplugin.Run(append([]string{"ensure"}, args...), cmd.OutOrStdout())
+		return depplugin.Ensure(param, args, cmd.OutOrStdout(), cmd.OutOrStderr())
 	},
 }
 
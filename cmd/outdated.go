@@ -0,0 +1,42 @@
+// Copyright (c) 2018 Palantir Technologies Inc. All rights reserved.
+// Use of this source code is governed by the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/palantir/godel-dep-plugin/depplugin"
+)
+
+var (
+	outdatedFormatFlagVal string
+	outdatedAllFlagVal    bool
+)
+
+var outdatedCmd = &cobra.Command{
+	Use:   "outdated",
+	Short: "Report the available updates of the project's dependencies",
+	Long: `Executes "dep outdated" using the bundled version of dep. For each dependency in Gopkg.lock, reports the newest
+version allowed by its constraint and the newest version overall, the impact of each update (patch, minor, major, branch
+or revision) and the number of commits it adds to the locked revision.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		param, err := depParam()
+		if err != nil {
+			return err
+		}
+		outdatedParam := depplugin.OutdatedParam{
+			Format: depplugin.OutdatedFormat(outdatedFormatFlagVal),
+			All:    outdatedAllFlagVal,
+		}
+		return depplugin.Outdated(param, outdatedParam, cmd.OutOrStdout(), cmd.OutOrStderr())
+	},
+}
+
+func init() {
+	outdatedCmd.Flags().StringVar(&outdatedFormatFlagVal, "format", string(depplugin.OutdatedFormatTable), `output format: "table", "json" or "markdown"`)
+	outdatedCmd.Flags().BoolVar(&outdatedAllFlagVal, "all", false, "also report dependencies that are up to date")
+	rootCmd.AddCommand(outdatedCmd)
+}
@@ -19,6 +19,7 @@ var (
 		pluginapi.PluginInfoUsesConfigFile(),
 		pluginapi.PluginInfoGlobalFlagOptions(
 			pluginapi.GlobalFlagOptionsParamDebugFlag("--"+pluginapi.DebugFlagName),
+			pluginapi.GlobalFlagOptionsParamConfigFlag("--"+pluginapi.ConfigFlagName),
 		),
 		pluginapi.PluginInfoTaskInfo(
 			"dep",
@@ -34,6 +35,19 @@ var (
 			"Run dep with the provided flags and arguments",
 			pluginapi.TaskInfoCommand("run"),
 		),
+		pluginapi.PluginInfoTaskInfo(
+			"dep-status",
+			"Report the status of the project's dependencies",
+			pluginapi.TaskInfoCommand("status"),
+		),
+		pluginapi.PluginInfoTaskInfo(
+			"dep-outdated",
+			"Report the available updates of the project's dependencies",
+			pluginapi.TaskInfoCommand("outdated"),
+		),
+		pluginapi.PluginInfoUpgradeConfigTaskInfo(
+			pluginapi.UpgradeConfigTaskInfoCommand("upgrade-config"),
+		),
 	)
 )
 
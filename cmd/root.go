@@ -8,11 +8,15 @@ import (
 	"github.com/palantir/godel/framework/pluginapi"
 	"github.com/palantir/pkg/cobracli"
 	"github.com/spf13/cobra"
+
+	"github.com/palantir/godel-dep-plugin/depplugin"
+	"github.com/palantir/godel-dep-plugin/depplugin/config"
 )
 
 var (
-	debugFlagVal  bool
-	verifyFlagVal bool
+	debugFlagVal      bool
+	configFileFlagVal string
+	verifyFlagVal     bool
 )
 
 var rootCmd = &cobra.Command{
@@ -21,9 +25,25 @@ var rootCmd = &cobra.Command{
 }
 
 func Execute() int {
-	return cobracli.ExecuteWithDebugVarAndDefaultParams(rootCmd, &debugFlagVal)
+	// propagate the exit code of dep as the exit code of the plugin
+	return cobracli.ExecuteWithDebugVarAndDefaultParams(rootCmd, &debugFlagVal, cobracli.ExitCodeExtractorParam(depplugin.ExitCode))
 }
 
 func init() {
 	pluginapi.AddDebugPFlagPtr(rootCmd.PersistentFlags(), &debugFlagVal)
+	pluginapi.AddConfigPFlagPtr(rootCmd.PersistentFlags(), &configFileFlagVal)
+}
+
+// depParam returns the depplugin.Param for the configuration file specified by the "--config" flag. If the flag is not
+// specified, the Param for the default configuration is returned.
+func depParam() (depplugin.Param, error) {
+	var cfg config.DepPluginConfig
+	if configFileFlagVal != "" {
+		var err error
+		cfg, err = config.ReadConfigFromFile(configFileFlagVal)
+		if err != nil {
+			return depplugin.Param{}, err
+		}
+	}
+	return cfg.ToParam()
 }
@@ -14,9 +14,14 @@ var runCmd = &cobra.Command{
 	Use:   "run [flags] [args]",
 	Short: "Run dep with the provided arguments",
 	Long: `Executes "dep" using the bundled version of dep with the provided flags and arguments. The "--" separator must be used 
-before specifying any flags for the "dep" program. For example, "./godelw run-dep -- -h" executes "dep -h".`,
+before specifying any flags for the "dep" program. For example, "./godelw run-dep -- -h" executes "dep -h".
+The output of dep is written to stdout and stderr as-is, and the exit code of dep is used as the exit code.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return depplugin.Run(args, cmd.OutOrStdout())
+		param, err := depParam()
+		if err != nil {
+			return err
+		}
+		return depplugin.Run(param, args, cmd.OutOrStdout(), cmd.OutOrStderr())
 	},
 }
 
@@ -0,0 +1,55 @@
+// Copyright (c) 2018 Palantir Technologies Inc. All rights reserved.
+// Use of this source code is governed by the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/palantir/godel-dep-plugin/depplugin"
+)
+
+var (
+	statusJSONFlagVal     bool
+	statusTemplateFlagVal string
+	statusDetailFlagVal   bool
+	statusOldFlagVal      bool
+	statusMissingFlagVal  bool
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Report the status of the project's dependencies",
+	Long: `Executes "dep status" using the bundled version of dep. The output format is a table unless the "status.format"
+field of the plugin configuration specifies otherwise. The --json and --template flags override the configured format.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		param, err := depParam()
+		if err != nil {
+			return err
+		}
+		statusParam := depplugin.StatusParam{
+			Template: statusTemplateFlagVal,
+			Detail:   statusDetailFlagVal,
+			Old:      statusOldFlagVal,
+			Missing:  statusMissingFlagVal,
+		}
+		if cmd.Flags().Changed("json") {
+			statusParam.Format = depplugin.StatusFormatTable
+			if statusJSONFlagVal {
+				statusParam.Format = depplugin.StatusFormatJSON
+			}
+		}
+		return depplugin.Status(param, statusParam, cmd.OutOrStdout(), cmd.OutOrStderr())
+	},
+}
+
+func init() {
+	statusCmd.Flags().BoolVar(&statusJSONFlagVal, "json", false, "output in JSON format (--json=false outputs a table)")
+	statusCmd.Flags().StringVarP(&statusTemplateFlagVal, "template", "f", "", "output in text/template format")
+	statusCmd.Flags().BoolVar(&statusDetailFlagVal, "detail", false, "include more detail in the output")
+	statusCmd.Flags().BoolVar(&statusOldFlagVal, "old", false, "only show out-of-date dependencies")
+	statusCmd.Flags().BoolVar(&statusMissingFlagVal, "missing", false, "only show dependencies that are missing from Gopkg.lock")
+	rootCmd.AddCommand(statusCmd)
+}
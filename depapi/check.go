@@ -0,0 +1,200 @@
+// Copyright (c) 2018 Palantir Technologies Inc. All rights reserved.
+// Use of this source code is governed by the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package depapi
+
+import (
+	"sort"
+
+	"github.com/palantir/godel-dep-plugin/depplugin"
+	"github.com/palantir/godel-dep-plugin/generated_src/depcmd"
+)
+
+// CheckOptions specifies the options for Check.
+type CheckOptions struct {
+	Options
+
+	// SkipLock skips checking whether Gopkg.lock is in sync with the imports of the project and Gopkg.toml.
+	SkipLock bool
+	// SkipVendor skips checking whether vendor is in sync with Gopkg.lock.
+	SkipVendor bool
+	// NoVerify are project roots for which vendor digest mismatches are ignored in addition to the noverify list in
+	// Gopkg.toml.
+	NoVerify []string
+}
+
+// CheckResult is the result of Check. All of the slices are sorted.
+type CheckResult struct {
+	// LockChecked is true if Gopkg.lock was checked.
+	LockChecked bool
+	// MissingImports are imported or required, but missing from the input-imports of Gopkg.lock.
+	MissingImports []string
+	// ExcessImports are in the input-imports of Gopkg.lock, but neither imported nor required.
+	ExcessImports []string
+	// UnmetConstraints are locked versions that are not allowed by the constraints in Gopkg.toml.
+	UnmetConstraints []UnmetConstraint
+	// UnmetOverrides are locked versions that are not allowed by the overrides in Gopkg.toml.
+	UnmetOverrides []UnmetConstraint
+	// PruneOptsChanged are the project roots whose prune options in Gopkg.lock differ from those in Gopkg.toml.
+	PruneOptsChanged []string
+	// PruneParamsChanged are the project roots whose prune parameters (platforms, build tags and keep and exclude
+	// patterns) in Gopkg.lock differ from those in Gopkg.toml.
+	PruneParamsChanged []string
+	// HashVersionChanged are the project roots whose digests in Gopkg.lock were not computed with the current version
+	// of the hashing algorithm.
+	HashVersionChanged []string
+
+	// VendorChecked is true if vendor was checked.
+	VendorChecked bool
+	// Vendor is the status in vendor of every project root in vendor or Gopkg.lock.
+	Vendor []VendorProject
+}
+
+// UnmetConstraint is a locked version that is not allowed by a constraint.
+type UnmetConstraint struct {
+	ProjectRoot string
+	Version     string
+	Constraint  string
+}
+
+// VendorStatus is the status of a project in vendor relative to Gopkg.lock.
+type VendorStatus string
+
+const (
+	VendorMatch          VendorStatus = "match"
+	VendorNotInTree      VendorStatus = "not-in-tree"
+	VendorNotInLock      VendorStatus = "not-in-lock"
+	VendorEmptyDigest    VendorStatus = "empty-digest"
+	VendorDigestMismatch VendorStatus = "digest-mismatch"
+	VendorHashMismatch   VendorStatus = "hash-version-mismatch"
+)
+
+// VendorProject is the status of a single project root in vendor.
+type VendorProject struct {
+	ProjectRoot string
+	Status      VendorStatus
+	// NoVerify is true if digest mismatches are ignored for the project.
+	NoVerify bool
+	// Failed is true if the status means that vendor is out of sync.
+	Failed bool
+}
+
+// LockInSync returns true if Gopkg.lock was not checked or is in sync.
+func (r CheckResult) LockInSync() bool {
+	return len(r.MissingImports) == 0 && len(r.ExcessImports) == 0 && len(r.UnmetConstraints) == 0 &&
+		len(r.UnmetOverrides) == 0 && len(r.PruneOptsChanged) == 0 && len(r.PruneParamsChanged) == 0 &&
+		len(r.HashVersionChanged) == 0
+}
+
+// VendorInSync returns true if vendor was not checked or is in sync.
+func (r CheckResult) VendorInSync() bool {
+	for _, vp := range r.Vendor {
+		if vp.Failed {
+			return false
+		}
+	}
+	return true
+}
+
+// InSync returns true if all of the checked properties are in sync.
+func (r CheckResult) InSync() bool {
+	return r.LockInSync() && r.VendorInSync()
+}
+
+// Check determines whether Gopkg.lock is in sync with the imports of the project and Gopkg.toml and whether vendor is
+// in sync with Gopkg.lock. An error is returned only if the checks could not be performed.
+func Check(opts CheckOptions) (CheckResult, error) {
+	cfg, err := depplugin.NewConfig(opts.Options)
+	if err != nil {
+		return CheckResult{}, err
+	}
+	result, err := cfg.Check(opts.SkipLock, opts.SkipVendor, opts.NoVerify)
+	if err != nil {
+		return CheckResult{}, err
+	}
+
+	checkResult := CheckResult{
+		LockChecked:   result.LockChecked,
+		VendorChecked: result.VendorChecked,
+	}
+	if result.LockChecked {
+		lsat := result.LockSatisfaction
+		checkResult.MissingImports = sortedStrings(lsat.MissingImports)
+		checkResult.ExcessImports = sortedStrings(lsat.ExcessImports)
+		for pr, unmet := range lsat.UnmetConstraints {
+			checkResult.UnmetConstraints = append(checkResult.UnmetConstraints, UnmetConstraint{
+				ProjectRoot: string(pr),
+				Version:     unmet.V.String(),
+				Constraint:  unmet.C.String(),
+			})
+		}
+		for pr, unmet := range lsat.UnmetOverrides {
+			checkResult.UnmetOverrides = append(checkResult.UnmetOverrides, UnmetConstraint{
+				ProjectRoot: string(pr),
+				Version:     unmet.V.String(),
+				Constraint:  unmet.C.String(),
+			})
+		}
+		sortUnmetConstraints(checkResult.UnmetConstraints)
+		sortUnmetConstraints(checkResult.UnmetOverrides)
+		for pr, lpd := range result.LockDelta.ProjectDeltas {
+			if lpd.PruneOptsBefore != lpd.PruneOptsAfter {
+				checkResult.PruneOptsChanged = append(checkResult.PruneOptsChanged, string(pr))
+			}
+			if lpd.PruneParamsChanged() {
+				checkResult.PruneParamsChanged = append(checkResult.PruneParamsChanged, string(pr))
+			}
+			if lpd.HashVersionChanged() {
+				checkResult.HashVersionChanged = append(checkResult.HashVersionChanged, string(pr))
+			}
+		}
+		sort.Strings(checkResult.PruneOptsChanged)
+		sort.Strings(checkResult.PruneParamsChanged)
+		sort.Strings(checkResult.HashVersionChanged)
+	}
+	for pr, status := range result.VendorStatus {
+		checkResult.Vendor = append(checkResult.Vendor, VendorProject{
+			ProjectRoot: pr,
+			Status:      vendorStatus(status),
+			NoVerify:    result.NoVerify[pr],
+			Failed:      result.VendorFailed(pr),
+		})
+	}
+	sort.Slice(checkResult.Vendor, func(i, j int) bool {
+		return checkResult.Vendor[i].ProjectRoot < checkResult.Vendor[j].ProjectRoot
+	})
+	return checkResult, nil
+}
+
+func vendorStatus(status depcmd.VendorStatus) VendorStatus {
+	switch status {
+	case depcmd.NotInTree:
+		return VendorNotInTree
+	case depcmd.NotInLock:
+		return VendorNotInLock
+	case depcmd.EmptyDigestInLock:
+		return VendorEmptyDigest
+	case depcmd.DigestMismatchInLock:
+		return VendorDigestMismatch
+	case depcmd.HashVersionMismatch:
+		return VendorHashMismatch
+	default:
+		return VendorMatch
+	}
+}
+
+func sortedStrings(in []string) []string {
+	if len(in) == 0 {
+		return nil
+	}
+	out := append([]string(nil), in...)
+	sort.Strings(out)
+	return out
+}
+
+func sortUnmetConstraints(in []UnmetConstraint) {
+	sort.Slice(in, func(i, j int) bool {
+		return in[i].ProjectRoot < in[j].ProjectRoot
+	})
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2018 Palantir Technologies Inc. All rights reserved.
+// Use of this source code is governed by the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package depapi_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/nmiyake/pkg/dirs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/palantir/godel-dep-plugin/depapi"
+	"github.com/palantir/godel-dep-plugin/depplugin"
+)
+
+func TestInitializedProject(t *testing.T) {
+	gopath, cleanup, err := dirs.TempDir("", "")
+	require.NoError(t, err)
+	defer cleanup()
+
+	cacheDir := path.Join(gopath, "pkg", "dep")
+	err = os.MkdirAll(cacheDir, 0755)
+	require.NoError(t, err)
+
+	projectDir := path.Join(gopath, "src", "github.com", "org", "project")
+	err = os.MkdirAll(projectDir, 0755)
+	require.NoError(t, err)
+	err = ioutil.WriteFile(path.Join(projectDir, "main.go"), []byte("package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println()\n}\n"), 0644)
+	require.NoError(t, err)
+
+	outputBuf := &bytes.Buffer{}
+	opts := depapi.Options{
+		WorkingDir: projectDir,
+		Env: []string{
+			"GOPATH=" + gopath,
+			"DEPCACHEDIR=" + cacheDir,
+		},
+		Stdout: outputBuf,
+		Stderr: outputBuf,
+	}
+	err = depplugin.Exec([]string{"init"}, opts)
+	require.NoError(t, err, "Output: %s", outputBuf.String())
+
+	err = depapi.Ensure(depapi.EnsureOptions{
+		Options: opts,
+	})
+	require.NoError(t, err, "Output: %s", outputBuf.String())
+
+	lock, err := depapi.Lock(projectDir)
+	require.NoError(t, err)
+	assert.Equal(t, "gps-cdcl", lock.SolverName)
+	assert.Equal(t, []string{}, lock.InputImports)
+	assert.Empty(t, lock.Projects)
+	assert.Nil(t, lock.Project("github.com/org/other"))
+
+	result, err := depapi.Check(depapi.CheckOptions{
+		Options: opts,
+	})
+	require.NoError(t, err, "Output: %s", outputBuf.String())
+	assert.True(t, result.LockChecked)
+	assert.True(t, result.VendorChecked)
+	assert.True(t, result.InSync(), "%+v", result)
+
+	statuses, err := depapi.Status(opts)
+	require.NoError(t, err, "Output: %s", outputBuf.String())
+	assert.Empty(t, statuses)
+
+	// importing a package that is not in Gopkg.lock puts the lock out of sync
+	err = ioutil.WriteFile(path.Join(projectDir, "main.go"), []byte("package main\n\nimport _ \"github.com/org/other\"\n\nfunc main() {}\n"), 0644)
+	require.NoError(t, err)
+
+	result, err = depapi.Check(depapi.CheckOptions{
+		Options:    opts,
+		SkipVendor: true,
+	})
+	require.NoError(t, err, "Output: %s", outputBuf.String())
+	assert.False(t, result.VendorChecked)
+	assert.Equal(t, []string{"github.com/org/other"}, result.MissingImports)
+	assert.False(t, result.InSync())
+}
+
+func TestLockMissing(t *testing.T) {
+	projectDir, cleanup, err := dirs.TempDir("", "")
+	require.NoError(t, err)
+	defer cleanup()
+
+	_, err = depapi.Lock(projectDir)
+	assert.Error(t, err)
+}
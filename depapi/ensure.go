@@ -0,0 +1,62 @@
+// Copyright (c) 2018 Palantir Technologies Inc. All rights reserved.
+// Use of this source code is governed by the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package depapi provides typed functions for the dep operations that other plugins need. All of the operations are
+// performed in-process using the dep bundled with this plugin and return plain Go structs.
+package depapi
+
+import (
+	"github.com/palantir/godel-dep-plugin/depplugin"
+)
+
+// Options specifies the project directory and environment for an operation. If WorkingDir is blank, the current
+// working directory is used. Output written by dep (such as progress and warnings) goes to Stdout and Stderr.
+type Options = depplugin.ExecOptions
+
+// EnsureOptions specifies the options for Ensure.
+type EnsureOptions struct {
+	Options
+
+	// Update updates the locked versions of the dependencies in Packages (or of all dependencies if Packages is
+	// empty) to the newest versions allowed by Gopkg.toml.
+	Update bool
+	// Patch limits Update to versions with the same major and minor version as the locked version, and Minor limits
+	// it to versions with the same major version. Dependencies that are not locked to a semantic version are not
+	// limited.
+	Patch bool
+	Minor bool
+	// Minimal makes Update use the oldest versions allowed by Gopkg.toml instead of the newest.
+	Minimal bool
+	// NoVendor updates Gopkg.lock without updating vendor.
+	NoVendor bool
+	// VendorOnly populates vendor from Gopkg.lock without updating Gopkg.lock.
+	VendorOnly bool
+	// Packages are the project roots or import paths provided as arguments to "dep ensure".
+	Packages []string
+}
+
+// Ensure runs "dep ensure" with the provided options. Returns a *depplugin.ExitError if dep exits with a non-zero exit
+// code, in which case a description of the failure is written to opts.Stderr.
+func Ensure(opts EnsureOptions) error {
+	args := []string{"ensure"}
+	if opts.Update {
+		args = append(args, "-update")
+	}
+	if opts.Patch {
+		args = append(args, "-patch")
+	}
+	if opts.Minor {
+		args = append(args, "-minor")
+	}
+	if opts.Minimal {
+		args = append(args, "-minimal")
+	}
+	if opts.NoVendor {
+		args = append(args, "-no-vendor")
+	}
+	if opts.VendorOnly {
+		args = append(args, "-vendor-only")
+	}
+	return depplugin.Exec(append(args, opts.Packages...), opts.Options)
+}
@@ -0,0 +1,108 @@
+// Copyright (c) 2018 Palantir Technologies Inc. All rights reserved.
+// Use of this source code is governed by the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package depapi
+
+import (
+	"os"
+	"path"
+
+	"github.com/pkg/errors"
+
+	"github.com/palantir/godel-dep-plugin/generated_src/depcmd"
+)
+
+// LockFile is the content of a Gopkg.lock file.
+type LockFile struct {
+	AnalyzerName    string
+	AnalyzerVersion int
+	SolverName      string
+	SolverVersion   int
+	InputImports    []string
+	Projects        []LockedProject
+}
+
+// LockedProject is a single project in Gopkg.lock.
+type LockedProject struct {
+	// Name is the project root.
+	Name string
+	// Source is the alternate source of the project. Blank if the project is retrieved from its project root.
+	Source string
+	// Version, Branch and Revision identify the locked version. Revision is always set and at most one of Version and
+	// Branch is set.
+	Version  string
+	Branch   string
+	Revision string
+	// Packages are the packages of the project that are imported, relative to the project root.
+	Packages []string
+	// PruneOpts are the prune options applied to the project in vendor.
+	PruneOpts string
+	// PrunePlatforms and PruneTags are the target platforms (GOOS/GOARCH) and build tags for which source files were
+	// kept when pruning the files of other platforms.
+	PrunePlatforms []string
+	PruneTags      []string
+	// PruneKeep and PruneExclude are the glob patterns of files that were kept and removed regardless of the prune
+	// options.
+	PruneKeep    []string
+	PruneExclude []string
+	// Digest is the hash digest of the project in vendor.
+	Digest string
+}
+
+// Project returns the project with the provided project root, or nil if the project is not locked.
+func (l *LockFile) Project(name string) *LockedProject {
+	for i := range l.Projects {
+		if l.Projects[i].Name == name {
+			return &l.Projects[i]
+		}
+	}
+	return nil
+}
+
+// Lock reads the Gopkg.lock file in the provided directory.
+func Lock(dir string) (*LockFile, error) {
+	lockPath := path.Join(dir, depcmd.LockName)
+	f, err := os.Open(lockPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open %s", lockPath)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	l, err := depcmd.ReadLock(f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", lockPath)
+	}
+	lockFile := &LockFile{
+		AnalyzerName:    l.SolveMeta.AnalyzerName,
+		AnalyzerVersion: l.SolveMeta.AnalyzerVersion,
+		SolverName:      l.SolveMeta.SolverName,
+		SolverVersion:   l.SolveMeta.SolverVersion,
+		InputImports:    l.SolveMeta.InputImports,
+	}
+	for _, lp := range l.Projects() {
+		revision, branch, version := depcmd.VersionComponentStrings(lp.Version())
+		project := LockedProject{
+			Name:     string(lp.Ident().ProjectRoot),
+			Source:   lp.Ident().Source,
+			Version:  version,
+			Branch:   branch,
+			Revision: revision,
+			Packages: lp.Packages(),
+		}
+		if vp, ok := lp.(depcmd.VerifiableProject); ok {
+			project.PruneOpts = (vp.PruneOpts & ^depcmd.PruneNestedVendorDirs).String()
+			project.PrunePlatforms = vp.PruneParams.Platforms
+			project.PruneTags = vp.PruneParams.BuildTags
+			project.PruneKeep = vp.PruneParams.Keep
+			project.PruneExclude = vp.PruneParams.Exclude
+			if !vp.Digest.IsEmpty() {
+				project.Digest = vp.Digest.String()
+			}
+		}
+		lockFile.Projects = append(lockFile.Projects, project)
+	}
+	return lockFile, nil
+}
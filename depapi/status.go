@@ -0,0 +1,93 @@
+// Copyright (c) 2018 Palantir Technologies Inc. All rights reserved.
+// Use of this source code is governed by the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package depapi
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/palantir/godel-dep-plugin/depplugin"
+	"github.com/palantir/godel-dep-plugin/generated_src/depcmd"
+)
+
+// ProjectStatus is the status of a single dependency as reported by "dep status -detail".
+type ProjectStatus struct {
+	// ProjectRoot is the root of the project.
+	ProjectRoot string
+	// Source is the alternate source of the project. Blank if the project is retrieved from its project root.
+	Source string
+	// Constraint is the constraint on the project. For projects that are not constrained in Gopkg.toml, it is the
+	// intersection of the constraints imposed by the other dependencies.
+	Constraint string
+	// Override is true if Constraint is an override in Gopkg.toml.
+	Override bool
+	// Version, Branch and Revision identify the locked version.
+	Version  string
+	Branch   string
+	Revision string
+	// Latest is the newest version (or revision for branches) allowed by the constraint. Blank if the locked version
+	// is a plain revision.
+	Latest string
+	// LatestUnknown is true if the available versions of the project could not be retrieved, in which case Latest is
+	// blank.
+	LatestUnknown bool
+	// Packages are the packages of the project that are imported, relative to the project root.
+	Packages []string
+	// PruneOpts are the prune options applied to the project in vendor.
+	PruneOpts string
+	// Digest is the hash digest of the project in vendor.
+	Digest string
+}
+
+// Status returns the status of all of the dependencies locked in Gopkg.lock, ordered by project root. Returns an error
+// if Gopkg.lock is out of sync with the imports of the project. Retrieving the available versions of the dependencies
+// requires network access or a populated cache; projects for which this fails are reported with LatestUnknown set.
+func Status(opts Options) ([]ProjectStatus, error) {
+	cfg, err := depplugin.NewConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	detail, missing, err := cfg.Status()
+	if err != nil {
+		if len(missing) == 0 {
+			return nil, err
+		}
+		var roots []string
+		for _, ms := range missing {
+			roots = append(roots, ms.ProjectRoot)
+		}
+		sort.Strings(roots)
+		return nil, errors.Errorf("%v (projects missing from Gopkg.lock: %s)", err, strings.Join(roots, ", "))
+	}
+
+	statuses := make([]ProjectStatus, 0, len(detail))
+	for _, ds := range detail {
+		status := ProjectStatus{
+			ProjectRoot:   ds.ProjectRoot,
+			Source:        ds.Source,
+			Override:      ds.HasOverride(),
+			Revision:      string(ds.Revision),
+			LatestUnknown: ds.HasError(),
+			Packages:      ds.Packages,
+			PruneOpts:     (ds.PruneOpts & ^depcmd.PruneNestedVendorDirs).String(),
+		}
+		if ds.Constraint != nil {
+			status.Constraint = ds.Constraint.String()
+		}
+		if ds.Version != nil {
+			_, status.Branch, status.Version = depcmd.VersionComponentStrings(ds.Version)
+		}
+		if ds.Latest != nil {
+			status.Latest = ds.Latest.String()
+		}
+		if !ds.Digest.IsEmpty() {
+			status.Digest = ds.Digest.String()
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2018 Palantir Technologies Inc. All rights reserved.
+// Use of this source code is governed by the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+
+	"github.com/palantir/godel-dep-plugin/depplugin"
+	"github.com/palantir/godel-dep-plugin/depplugin/config/internal/v0"
+)
+
+type DepPluginConfig v0.Config
+
+// ReadConfigFromFile reads and upgrades the configuration in the provided file. If the file does not exist, the empty
+// configuration is returned.
+func ReadConfigFromFile(cfgFile string) (DepPluginConfig, error) {
+	cfgBytes, err := ioutil.ReadFile(cfgFile)
+	if os.IsNotExist(err) {
+		return DepPluginConfig{}, nil
+	}
+	if err != nil {
+		return DepPluginConfig{}, errors.Wrapf(err, "failed to read configuration file %s", cfgFile)
+	}
+	upgradedCfgBytes, err := UpgradeConfig(cfgBytes)
+	if err != nil {
+		return DepPluginConfig{}, errors.Wrapf(err, "failed to upgrade configuration")
+	}
+	var cfg DepPluginConfig
+	if err := yaml.Unmarshal(upgradedCfgBytes, &cfg); err != nil {
+		return DepPluginConfig{}, errors.Wrapf(err, "failed to unmarshal configuration")
+	}
+	return cfg, nil
+}
+
+func (c *DepPluginConfig) ToParam() (depplugin.Param, error) {
+	var cacheAge time.Duration
+	if c.CacheAge != "" {
+		var err error
+		cacheAge, err = time.ParseDuration(c.CacheAge)
+		if err != nil {
+			return depplugin.Param{}, errors.Wrapf(err, "failed to parse cache-age %q", c.CacheAge)
+		}
+	}
+
+	verifyStrategy := depplugin.VerifyStrategyAll
+	if c.Verify.Strategy != "" {
+		verifyStrategy = depplugin.VerifyStrategy(c.Verify.Strategy)
+		if !verifyStrategy.IsValid() {
+			return depplugin.Param{}, errors.Errorf("invalid verify strategy %q: must be one of %v", c.Verify.Strategy, depplugin.VerifyStrategies())
+		}
+	}
+
+	statusFormat := depplugin.StatusFormatTable
+	if c.Status.Format != "" {
+		statusFormat = depplugin.StatusFormat(c.Status.Format)
+		if !statusFormat.IsValid() {
+			return depplugin.Param{}, errors.Errorf("invalid status format %q: must be one of %v", c.Status.Format, depplugin.StatusFormats())
+		}
+	}
+
+	return depplugin.Param{
+		EnsureArgs:     c.EnsureArgs,
+		CacheDir:       c.CacheDir,
+		CacheAge:       cacheAge,
+		SharedCacheDir: c.SharedCacheDir,
+		Offline:        c.Offline,
+		DigestCache:    c.DigestCache,
+		VerifyStrategy: verifyStrategy,
+		NoVerify:       c.Verify.NoVerify,
+		StatusFormat:   statusFormat,
+	}, nil
+}
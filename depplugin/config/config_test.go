@@ -0,0 +1,121 @@
+// Copyright (c) 2018 Palantir Technologies Inc. All rights reserved.
+// Use of this source code is governed by the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package config_test
+
+import (
+	"io/ioutil"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/nmiyake/pkg/dirs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/palantir/godel-dep-plugin/depplugin"
+	"github.com/palantir/godel-dep-plugin/depplugin/config"
+)
+
+func TestReadConfigToParam(t *testing.T) {
+	tmpDir, cleanup, err := dirs.TempDir("", "")
+	require.NoError(t, err)
+	defer cleanup()
+
+	for i, tc := range []struct {
+		name      string
+		cfg       string
+		wantParam depplugin.Param
+		wantErr   string
+	}{
+		{
+			name: "empty configuration uses defaults",
+			cfg:  ``,
+			wantParam: depplugin.Param{
+				VerifyStrategy: depplugin.VerifyStrategyAll,
+				StatusFormat:   depplugin.StatusFormatTable,
+			},
+		},
+		{
+			name: "all fields are set",
+			cfg: `
+version: 0
+ensure-args:
+  - -v
+cache-dir: /tmp/dep-cache
+cache-age: 24h
+shared-cache-dir: /mnt/dep-cache
+offline: true
+digest-cache: true
+verify:
+  strategy: vendor-only
+  noverify:
+    - github.com/pkg/errors
+status:
+  format: json
+`,
+			wantParam: depplugin.Param{
+				EnsureArgs:     []string{"-v"},
+				CacheDir:       "/tmp/dep-cache",
+				CacheAge:       24 * time.Hour,
+				SharedCacheDir: "/mnt/dep-cache",
+				Offline:        true,
+				DigestCache:    true,
+				VerifyStrategy: depplugin.VerifyStrategyVendorOnly,
+				NoVerify:       []string{"github.com/pkg/errors"},
+				StatusFormat:   depplugin.StatusFormatJSON,
+			},
+		},
+		{
+			name: "invalid verify strategy",
+			cfg: `
+verify:
+  strategy: unknown
+`,
+			wantErr: `invalid verify strategy "unknown": must be one of [all lock-only vendor-only]`,
+		},
+		{
+			name: "invalid status format",
+			cfg: `
+status:
+  format: yaml
+`,
+			wantErr: `invalid status format "yaml": must be one of [table json]`,
+		},
+		{
+			name:    "unknown fields are rejected",
+			cfg:     `unknown-key: true`,
+			wantErr: "failed to upgrade configuration: failed to unmarshal dep-plugin v0 configuration: yaml: unmarshal errors:\n  line 1: field unknown-key not found in type v0.Config",
+		},
+		{
+			name:    "unsupported version",
+			cfg:     `version: 1`,
+			wantErr: "failed to upgrade configuration: unsupported version: 1",
+		},
+	} {
+		cfgFile := path.Join(tmpDir, "dep-plugin.yml")
+		err := ioutil.WriteFile(cfgFile, []byte(tc.cfg), 0644)
+		require.NoError(t, err, "Case %d: %s", i, tc.name)
+
+		cfg, err := config.ReadConfigFromFile(cfgFile)
+		if err == nil {
+			var param depplugin.Param
+			param, err = cfg.ToParam()
+			if err == nil {
+				assert.Equal(t, tc.wantParam, param, "Case %d: %s", i, tc.name)
+			}
+		}
+		if tc.wantErr == "" {
+			assert.NoError(t, err, "Case %d: %s", i, tc.name)
+		} else {
+			assert.EqualError(t, err, tc.wantErr, "Case %d: %s", i, tc.name)
+		}
+	}
+}
+
+func TestReadConfigMissingFile(t *testing.T) {
+	cfg, err := config.ReadConfigFromFile(path.Join("does", "not", "exist.yml"))
+	require.NoError(t, err)
+	assert.Equal(t, config.DepPluginConfig{}, cfg)
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2018 Palantir Technologies Inc. All rights reserved.
+// Use of this source code is governed by the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package v0
+
+import (
+	"github.com/palantir/godel/pkg/versionedconfig"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+type Config struct {
+	// Version of the configuration
+	versionedconfig.ConfigWithVersion `yaml:",inline,omitempty"`
+
+	// EnsureArgs are the arguments that are provided to "dep ensure" before any of the arguments specified on the
+	// command line. Because the command-line arguments are provided after these arguments, they take precedence.
+	EnsureArgs []string `yaml:"ensure-args,omitempty"`
+
+	// CacheDir is the directory used by dep as its cache. Used only if the $DEPCACHEDIR environment variable is not
+	// set. If blank, dep's default ($GOPATH/pkg/dep) is used.
+	CacheDir string `yaml:"cache-dir,omitempty"`
+
+	// CacheAge is the maximum age of cached source metadata before it is refreshed, expressed as a Go duration string
+	// (for example, "24h"). Used only if the $DEPCACHEAGE environment variable is not set.
+	CacheAge string `yaml:"cache-age,omitempty"`
+
+	// SharedCacheDir is a read-only cache directory, for example on a network file system or a CI cache volume, that
+	// is consulted for cached source metadata and local copies of sources that are not in the cache directory. Used
+	// only if the $DEPSHAREDCACHEDIR environment variable is not set.
+	SharedCacheDir string `yaml:"shared-cache-dir,omitempty"`
+
+	// Offline prevents dep from accessing the network: sources are only read from the cache directory, and operations
+	// that need to fetch them fail. Used only if the $DEPOFFLINE environment variable is not set.
+	Offline bool `yaml:"offline,omitempty"`
+
+	// DigestCache enables a persistent cache, in the cache directory, of the digests of vendored projects, so that
+	// projects that did not change since they were last verified are not read again. Used only if the $DEPDIGESTCACHE
+	// environment variable is not set.
+	DigestCache bool `yaml:"digest-cache,omitempty"`
+
+	// Verify is the configuration for the "verify" task.
+	Verify VerifyConfig `yaml:"verify,omitempty"`
+
+	// Status is the configuration for the "dep-status" task.
+	Status StatusConfig `yaml:"status,omitempty"`
+}
+
+type VerifyConfig struct {
+	// Strategy specifies the checks performed when verifying with apply=false. Must be one of "all" (the default),
+	// "lock-only" or "vendor-only".
+	Strategy string `yaml:"strategy,omitempty"`
+
+	// NoVerify specifies project roots that should be treated as if they were in the "noverify" list of Gopkg.toml
+	// when verifying the vendor directory.
+	NoVerify []string `yaml:"noverify,omitempty"`
+}
+
+type StatusConfig struct {
+	// Format is the default output format of the "dep-status" task. Must be one of "table" (the default) or "json".
+	Format string `yaml:"format,omitempty"`
+}
+
+func UpgradeConfig(cfgBytes []byte) ([]byte, error) {
+	var cfg Config
+	if err := yaml.UnmarshalStrict(cfgBytes, &cfg); err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal dep-plugin v0 configuration")
+	}
+	return cfgBytes, nil
+}
@@ -5,58 +5,133 @@
 package depplugin
 
 import (
-	"fmt"
 	"io"
-	"os/exec"
-	"strings"
+	"io/ioutil"
+	"os"
 
-	"github.com/kardianos/osext"
-	"github.com/palantir/amalgomate/amalgomated"
 	"github.com/pkg/errors"
+
+	"github.com/palantir/godel-dep-plugin/generated_src/depcmd"
 )
 
-func Run(args []string, stdout io.Writer) error {
-	pathToSelf, err := osext.Executable()
-	if err != nil {
-		return errors.Wrapf(err, "failed to determine path to self")
+// ExitError is returned when dep exits with a non-zero exit code. Its message is empty because dep writes a description
+// of the failure to its output before exiting.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return ""
+}
+
+// ExitCode returns the exit code of dep if the cause of the provided error is an *ExitError. Otherwise, returns 1 if
+// the provided error is non-nil and 0 if it is nil.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
 	}
+	if exitErr, ok := errors.Cause(err).(*ExitError); ok {
+		return exitErr.Code
+	}
+	return 1
+}
 
-	cmd := exec.Command(pathToSelf, append([]string{amalgomated.ProxyCmdPrefix + "dep"}, args...)...)
-	cmd.Stdout = stdout
-	cmd.Stderr = stdout
-	if err := cmd.Run(); err != nil {
-		if _, ok := err.(*exec.ExitError); !ok {
-			// if error is not an exit error, wrap it
-			return errors.Wrapf(err, "failed to execute command %v", cmd.Args)
+// ExecOptions specifies the environment for an in-process execution of dep.
+type ExecOptions struct {
+	// WorkingDir is the directory in which dep is run. If blank, the current working directory is used.
+	WorkingDir string
+	// Env is the environment of dep in the form of os.Environ. If nil, the current environment is used.
+	Env []string
+	// Stdout and Stderr are the writers for the output of dep. If nil, output is discarded.
+	Stdout, Stderr io.Writer
+	// Stdin is the reader for the input of dep, which is read by commands that are given "-" as an input file. If
+	// nil, such commands fail.
+	Stdin io.Reader
+}
+
+// Exec runs the bundled dep in-process with the provided arguments (which do not include the program name). Returns an
+// *ExitError if dep exits with a non-zero exit code.
+func Exec(args []string, opts ExecOptions) error {
+	cfg, err := NewConfig(opts)
+	if err != nil {
+		return err
+	}
+	cfg.Args = append([]string{"dep"}, args...)
+	if exitCode := cfg.Run(); exitCode != 0 {
+		return &ExitError{
+			Code: exitCode,
 		}
-		// otherwise, return empty error
-		return fmt.Errorf("")
 	}
 	return nil
 }
 
-func Verify() error {
-	pathToSelf, err := osext.Executable()
-	if err != nil {
-		return errors.Wrapf(err, "failed to determine path to self")
-	}
+// Run runs the bundled dep with the provided arguments in the current working directory. The output of dep is written
+// to the provided stdout and stderr writers and its input is read from os.Stdin. Returns an *ExitError if dep exits with
+// a non-zero exit code.
+func Run(param Param, args []string, stdout, stderr io.Writer) error {
+	return Exec(args, ExecOptions{
+		Env:    depEnv(param),
+		Stdout: stdout,
+		Stderr: stderr,
+		Stdin:  os.Stdin,
+	})
+}
 
-	args := []string{
-		"check",
-	}
-	cmd := exec.Command(pathToSelf, append([]string{amalgomated.ProxyCmdPrefix + "dep"}, args...)...)
-	if output, err := cmd.CombinedOutput(); err != nil {
-		if _, ok := err.(*exec.ExitError); !ok {
-			// if error is not an exit error, wrap it
-			return errors.Wrapf(err, "failed to execute command %v", cmd.Args)
-		}
+// Ensure runs "dep ensure" with the ensure arguments specified in the provided Param followed by the provided
+// arguments.
+func Ensure(param Param, args []string, stdout, stderr io.Writer) error {
+	ensureArgs := append([]string{"ensure"}, param.EnsureArgs...)
+	return Run(param, append(ensureArgs, args...), stdout, stderr)
+}
 
-		if strings.Contains(string(output), "Gopkg.lock was not up to date") {
-			// if error is that Gopkg.lock is not up-to-date, return empty error
-			return fmt.Errorf("")
+// NewConfig returns the configuration for an in-process execution of dep with the provided options. The Args of the
+// returned configuration are not set.
+func NewConfig(opts ExecOptions) (*depcmd.Config, error) {
+	wd := opts.WorkingDir
+	if wd == "" {
+		var err error
+		if wd, err = os.Getwd(); err != nil {
+			return nil, errors.Wrapf(err, "failed to determine working directory")
 		}
-		// otherwise, error with output
-		return errors.Errorf(strings.TrimSuffix(string(output), "\n"))
 	}
-	return nil
+	env := opts.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	stdout, stderr := opts.Stdout, opts.Stderr
+	if stdout == nil {
+		stdout = ioutil.Discard
+	}
+	if stderr == nil {
+		stderr = ioutil.Discard
+	}
+	return &depcmd.Config{
+		WorkingDir: wd,
+		Env:        env,
+		Stdout:     stdout,
+		Stderr:     stderr,
+		Stdin:      opts.Stdin,
+	}, nil
+}
+
+// depEnv returns the environment in which dep should be run. The cache settings in the provided Param are added to the
+// current environment unless the corresponding variable is already set.
+func depEnv(param Param) []string {
+	env := os.Environ()
+	if _, ok := os.LookupEnv("DEPCACHEDIR"); !ok && param.CacheDir != "" {
+		env = append(env, "DEPCACHEDIR="+param.CacheDir)
+	}
+	if _, ok := os.LookupEnv("DEPCACHEAGE"); !ok && param.CacheAge != 0 {
+		env = append(env, "DEPCACHEAGE="+param.CacheAge.String())
+	}
+	if _, ok := os.LookupEnv("DEPSHAREDCACHEDIR"); !ok && param.SharedCacheDir != "" {
+		env = append(env, "DEPSHAREDCACHEDIR="+param.SharedCacheDir)
+	}
+	if _, ok := os.LookupEnv("DEPOFFLINE"); !ok && param.Offline {
+		env = append(env, "DEPOFFLINE=1")
+	}
+	if _, ok := os.LookupEnv("DEPDIGESTCACHE"); !ok && param.DigestCache {
+		env = append(env, "DEPDIGESTCACHE=1")
+	}
+	return env
 }
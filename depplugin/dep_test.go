@@ -0,0 +1,1143 @@
+// Copyright (c) 2018 Palantir Technologies Inc. All rights reserved.
+// Use of this source code is governed by the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package depplugin_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nmiyake/pkg/dirs"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/palantir/godel-dep-plugin/depplugin"
+)
+
+func TestExecInit(t *testing.T) {
+	gopath, cleanup, err := dirs.TempDir("", "")
+	require.NoError(t, err)
+	defer cleanup()
+
+	cacheDir := path.Join(gopath, "pkg", "dep")
+	err = os.MkdirAll(cacheDir, 0755)
+	require.NoError(t, err)
+
+	projectDir := path.Join(gopath, "src", "github.com", "org", "project")
+	err = os.MkdirAll(projectDir, 0755)
+	require.NoError(t, err)
+
+	outputBuf := &bytes.Buffer{}
+	err = depplugin.Exec([]string{"init"}, depplugin.ExecOptions{
+		WorkingDir: projectDir,
+		Env: []string{
+			"GOPATH=" + gopath,
+			"DEPCACHEDIR=" + cacheDir,
+		},
+		Stdout: outputBuf,
+		Stderr: outputBuf,
+	})
+	require.NoError(t, err, "Output: %s", outputBuf.String())
+
+	for _, f := range []string{"Gopkg.toml", "Gopkg.lock", "vendor"} {
+		_, err := os.Stat(path.Join(projectDir, f))
+		assert.NoError(t, err, "Output: %s", outputBuf.String())
+	}
+}
+
+func TestExecOffline(t *testing.T) {
+	gopath, cleanup, err := dirs.TempDir("", "")
+	require.NoError(t, err)
+	defer cleanup()
+
+	cacheDir := path.Join(gopath, "pkg", "dep")
+	err = os.MkdirAll(cacheDir, 0755)
+	require.NoError(t, err)
+
+	projectDir := path.Join(gopath, "src", "github.com", "org", "project")
+	err = os.MkdirAll(projectDir, 0755)
+	require.NoError(t, err)
+	err = ioutil.WriteFile(path.Join(projectDir, "main.go"), []byte("package main\n\nimport _ \"github.com/org/dependency\"\n"), 0644)
+	require.NoError(t, err)
+
+	outputBuf := &bytes.Buffer{}
+	err = depplugin.Exec([]string{"init"}, depplugin.ExecOptions{
+		WorkingDir: projectDir,
+		Env: []string{
+			"GOPATH=" + gopath,
+			"DEPCACHEDIR=" + cacheDir,
+			"DEPOFFLINE=1",
+		},
+		Stdout: outputBuf,
+		Stderr: outputBuf,
+	})
+	require.Error(t, err)
+	assert.Contains(t, outputBuf.String(), "checking upstream for https://github.com/org/dependency requires network access, which is disabled in offline mode")
+}
+
+func TestExecUnknownCommandUsageExitCode(t *testing.T) {
+	stdoutBuf, stderrBuf := &bytes.Buffer{}, &bytes.Buffer{}
+	err := depplugin.Exec([]string{"unknown-command"}, depplugin.ExecOptions{
+		Stdout: stdoutBuf,
+		Stderr: stderrBuf,
+	})
+	require.Error(t, err)
+	exitErr, ok := err.(*depplugin.ExitError)
+	require.True(t, ok, "unexpected error type %T", err)
+	assert.Equal(t, 2, exitErr.Code)
+	assert.Equal(t, 2, depplugin.ExitCode(errors.Wrapf(err, "wrapped")))
+	assert.Equal(t, "", stdoutBuf.String())
+	assert.Contains(t, stderrBuf.String(), "dep: unknown-command: no such command")
+}
+
+func TestExecHelpExitCode(t *testing.T) {
+	for _, tc := range []struct {
+		args     []string
+		wantCode int
+		want     string
+	}{
+		{nil, 2, "Dep is a tool for managing dependencies for Go projects"},
+		{[]string{"-h"}, 0, "Dep is a tool for managing dependencies for Go projects"},
+		{[]string{"help"}, 0, "Dep is a tool for managing dependencies for Go projects"},
+		{[]string{"help", "ensure"}, 0, "Usage: dep ensure"},
+		{[]string{"ensure", "-h"}, 0, "Usage: dep ensure"},
+		{[]string{"ensure", "--help"}, 0, "Usage: dep ensure"},
+		{[]string{"ensure", "-unknown-flag"}, 2, "flag provided but not defined: -unknown-flag"},
+	} {
+		stdoutBuf, stderrBuf := &bytes.Buffer{}, &bytes.Buffer{}
+		err := depplugin.Exec(tc.args, depplugin.ExecOptions{
+			Stdout: stdoutBuf,
+			Stderr: stderrBuf,
+		})
+		assert.Equal(t, tc.wantCode, depplugin.ExitCode(err), "Args %v", tc.args)
+		assert.Equal(t, "", stdoutBuf.String(), "Args %v", tc.args)
+		assert.Contains(t, stderrBuf.String(), tc.want, "Args %v", tc.args)
+	}
+}
+
+func TestExecCommandFailureExitCode(t *testing.T) {
+	projectDir, cleanup, err := dirs.TempDir("", "")
+	require.NoError(t, err)
+	defer cleanup()
+
+	stdoutBuf, stderrBuf := &bytes.Buffer{}, &bytes.Buffer{}
+	err = depplugin.Exec([]string{"check"}, depplugin.ExecOptions{
+		WorkingDir: projectDir,
+		Stdout:     stdoutBuf,
+		Stderr:     stderrBuf,
+	})
+	require.Error(t, err)
+	assert.Equal(t, 1, depplugin.ExitCode(err))
+	assert.Equal(t, "", stdoutBuf.String())
+	assert.Contains(t, stderrBuf.String(), "could not find project Gopkg.toml")
+}
+
+func TestExecCheckMigrateHash(t *testing.T) {
+	gopath, cleanup, err := dirs.TempDir("", "")
+	require.NoError(t, err)
+	defer cleanup()
+
+	cacheDir := path.Join(gopath, "pkg", "dep")
+	err = os.MkdirAll(cacheDir, 0755)
+	require.NoError(t, err)
+
+	projectDir := path.Join(gopath, "src", "github.com", "org", "project")
+	dependencyDir := path.Join(projectDir, "vendor", "github.com", "org", "dependency")
+	err = os.MkdirAll(dependencyDir, 0755)
+	require.NoError(t, err)
+	for name, content := range map[string]string{
+		path.Join(projectDir, "main.go"):          "package main\n\nimport _ \"github.com/org/dependency\"\n",
+		path.Join(dependencyDir, "dependency.go"): "package dependency\n",
+		path.Join(projectDir, "Gopkg.toml"):       "[prune]\n  go-tests = true\n  unused-packages = true\n",
+		path.Join(projectDir, "Gopkg.lock"): `[[projects]]
+  digest = "1:138e43384f9b04e88f4bf416daf9953e2c8c4a5415f22781c06674a9c2faec90"
+  name = "github.com/org/dependency"
+  packages = ["."]
+  pruneopts = "UT"
+  revision = "0000000000000000000000000000000000000000"
+  version = "v1.0.0"
+
+[solve-meta]
+  analyzer-name = "dep"
+  analyzer-version = 1
+  input-imports = ["github.com/org/dependency"]
+  solver-name = "gps-cdcl"
+  solver-version = 1
+`,
+	} {
+		err = ioutil.WriteFile(name, []byte(content), 0644)
+		require.NoError(t, err)
+	}
+
+	check := func(args ...string) (string, error) {
+		outputBuf := &bytes.Buffer{}
+		err := depplugin.Exec(append([]string{"check"}, args...), depplugin.ExecOptions{
+			WorkingDir: projectDir,
+			Env: []string{
+				"GOPATH=" + gopath,
+				"DEPCACHEDIR=" + cacheDir,
+				"DEPOFFLINE=1",
+			},
+			Stdout: outputBuf,
+			Stderr: outputBuf,
+		})
+		return outputBuf.String(), err
+	}
+
+	// digests of the older hash version are valid and left alone
+	output, err := check()
+	require.NoError(t, err, "Output: %s", output)
+	assert.Equal(t, "", output)
+
+	output, err = check("-migrate-hash")
+	require.NoError(t, err, "Output: %s", output)
+	assert.Equal(t, "github.com/org/dependency: migrated digest from hash version 1 to 2\n\n", output)
+
+	lock, err := ioutil.ReadFile(path.Join(projectDir, "Gopkg.lock"))
+	require.NoError(t, err)
+	assert.Contains(t, string(lock), `digest = "2:a3a5914f57cd846f260e4a30256484aa26d5864d59ede11ea53913d6189711f8"`)
+
+	output, err = check()
+	require.NoError(t, err, "Output: %s", output)
+	assert.Equal(t, "", output)
+
+	// a modified vendored tree fails the check rather than being migrated
+	err = ioutil.WriteFile(path.Join(dependencyDir, "dependency.go"), []byte("package dependency\n\nvar Modified = true\n"), 0644)
+	require.NoError(t, err)
+	output, err = check("-migrate-hash")
+	require.Error(t, err)
+	assert.Equal(t, 1, depplugin.ExitCode(err))
+	assert.Contains(t, output, "github.com/org/dependency: hash of vendored tree not equal to digest in Gopkg.lock")
+}
+
+func TestExecCacheImportInput(t *testing.T) {
+	dir, cleanup, err := dirs.TempDir("", "")
+	require.NoError(t, err)
+	defer cleanup()
+
+	cacheDir := path.Join(dir, "cache")
+	err = os.MkdirAll(cacheDir, 0755)
+	require.NoError(t, err)
+	err = ioutil.WriteFile(path.Join(dir, "bundle.tgz"), []byte("not a bundle"), 0644)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name  string
+		arg   string
+		stdin io.Reader
+		want  string
+	}{
+		{"relative path", "bundle.tgz", nil, "failed to import cache bundle bundle.tgz: not a cache bundle"},
+		{"missing relative path", "missing.tgz", nil, "open " + path.Join(dir, "missing.tgz")},
+		{"stdin", "-", strings.NewReader("not a bundle either"), "failed to import cache bundle -: not a cache bundle"},
+		{"no stdin", "-", nil, "no standard input to read the bundle from"},
+	} {
+		outputBuf := &bytes.Buffer{}
+		err := depplugin.Exec([]string{"cache", "import", tc.arg}, depplugin.ExecOptions{
+			WorkingDir: dir,
+			Env: []string{
+				"GOPATH=" + path.Join(dir, "gopath"),
+				"DEPCACHEDIR=" + cacheDir,
+			},
+			Stdout: outputBuf,
+			Stderr: outputBuf,
+			Stdin:  tc.stdin,
+		})
+		require.Error(t, err, "Case %s", tc.name)
+		assert.Contains(t, outputBuf.String(), tc.want, "Case %s", tc.name)
+	}
+}
+
+func TestExecCache(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	dir, cleanup, err := dirs.TempDir("", "")
+	require.NoError(t, err)
+	defer cleanup()
+
+	// cached sources are created in place, as if they had been cloned
+	cacheDir := path.Join(dir, "cache")
+	libDir := path.Join(cacheDir, "sources", "https---github.com-org-lib")
+	utilDir := path.Join(cacheDir, "sources", "https---github.com-org-util")
+	for _, src := range []struct {
+		dir     string
+		url     string
+		fetched time.Time
+	}{
+		{libDir, "https://github.com/org/lib", time.Now()},
+		{utilDir, "https://github.com/org/util", time.Now().AddDate(0, 0, -60)},
+	} {
+		err = os.MkdirAll(src.dir, 0755)
+		require.NoError(t, err)
+		for _, args := range [][]string{
+			{"init"},
+			{"remote", "add", "origin", src.url},
+		} {
+			cmd := exec.Command("git", args...)
+			cmd.Dir = src.dir
+			output, err := cmd.CombinedOutput()
+			require.NoError(t, err, "git %v: %s", args, output)
+		}
+		err = os.Chtimes(path.Join(src.dir, ".git"), src.fetched, src.fetched)
+		require.NoError(t, err)
+	}
+
+	cache := func(args ...string) (string, error) {
+		outputBuf := &bytes.Buffer{}
+		err := depplugin.Exec(append([]string{"cache"}, args...), depplugin.ExecOptions{
+			WorkingDir: dir,
+			Env: []string{
+				"GOPATH=" + path.Join(dir, "gopath"),
+				"DEPCACHEDIR=" + cacheDir,
+			},
+			Stdout: outputBuf,
+			Stderr: outputBuf,
+		})
+		return outputBuf.String(), err
+	}
+
+	output, err := cache("ls", "-json")
+	require.NoError(t, err, "Output: %s", output)
+	var sources []struct {
+		Name string
+		Type string
+		URL  string
+	}
+	err = json.Unmarshal([]byte(output), &sources)
+	require.NoError(t, err, "Output: %s", output)
+	require.Len(t, sources, 2)
+	assert.Equal(t, "https---github.com-org-lib", sources[0].Name)
+	assert.Equal(t, "git", sources[0].Type)
+	assert.Equal(t, "https://github.com/org/lib", sources[0].URL)
+	assert.Equal(t, "https---github.com-org-util", sources[1].Name)
+
+	output, err = cache("verify")
+	require.NoError(t, err, "Output: %s", output)
+	assert.Equal(t, "Verified 2 cached sources and the metadata cache.\n", output)
+
+	// only the source that was last fetched before the cutoff is collected
+	output, err = cache("gc", "-older-than", "30", "-dry-run")
+	require.NoError(t, err, "Output: %s", output)
+	assert.Contains(t, output, "Would remove https://github.com/org/util (")
+	assert.Contains(t, output, "Would remove 1 of 2 cached sources")
+	assert.NotContains(t, output, "github.com/org/lib")
+	_, err = os.Stat(utilDir)
+	assert.NoError(t, err)
+
+	output, err = cache("gc", "-older-than", "30")
+	require.NoError(t, err, "Output: %s", output)
+	assert.Contains(t, output, "Removed 1 of 2 cached sources")
+	_, err = os.Stat(utilDir)
+	assert.True(t, os.IsNotExist(err), "%s was not removed", utilDir)
+	_, err = os.Stat(libDir)
+	assert.NoError(t, err)
+
+	// a project root as it appears in Gopkg.lock names its cached sources
+	output, err = cache("rm", "github.com/org/lib")
+	require.NoError(t, err, "Output: %s", output)
+	assert.Equal(t, "Removed "+libDir+"\n", output)
+	_, err = os.Stat(libDir)
+	assert.True(t, os.IsNotExist(err), "%s was not removed", libDir)
+
+	output, err = cache("rm", "github.com/org/lib")
+	require.Error(t, err)
+	assert.Equal(t, 1, depplugin.ExitCode(err))
+	assert.Contains(t, output, "github.com/org/lib is not a cached source")
+
+	// the sources of vanity import paths are only determined with network access, which has to be allowed
+	output, err = cache("rm", "example.org/vanity")
+	require.Error(t, err)
+	assert.Equal(t, 1, depplugin.ExitCode(err))
+	assert.Contains(t, output, "example.org/vanity is not a cached source, or the sources of example.org/vanity cannot be "+
+		"determined without network access; use -network to allow it")
+
+	err = ioutil.WriteFile(path.Join(dir, "vanity.lock"), []byte(`[[projects]]
+  name = "example.org/vanity"
+  packages = ["."]
+  revision = "0000000000000000000000000000000000000000"
+`), 0644)
+	require.NoError(t, err)
+	output, err = cache("gc", "vanity.lock")
+	require.Error(t, err)
+	assert.Equal(t, 1, depplugin.ExitCode(err))
+	assert.Contains(t, output, "the sources of example.org/vanity cannot be determined without network access; use -network to allow it")
+}
+
+func TestExecDiff(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	gopath, cleanup, err := dirs.TempDir("", "")
+	require.NoError(t, err)
+	defer cleanup()
+
+	cacheDir := path.Join(gopath, "pkg", "dep")
+	err = os.MkdirAll(cacheDir, 0755)
+	require.NoError(t, err)
+
+	projectDir := path.Join(gopath, "src", "github.com", "org", "project")
+	subDir := path.Join(projectDir, "cmd")
+	err = os.MkdirAll(subDir, 0755)
+	require.NoError(t, err)
+
+	lock := func(projects string) string {
+		return projects + `
+[solve-meta]
+  analyzer-name = "dep"
+  analyzer-version = 1
+  input-imports = ["github.com/org/dependency"]
+  solver-name = "gps-cdcl"
+  solver-version = 1
+`
+	}
+	dependency := func(version, revision string) string {
+		return `[[projects]]
+  name = "github.com/org/dependency"
+  packages = ["."]
+  pruneopts = "UT"
+  revision = "` + revision + `"
+  version = "` + version + `"
+`
+	}
+	oldLock := lock(dependency("v1.0.0", "0000000000000000000000000000000000000000"))
+	newLock := lock(dependency("v1.1.0", "1111111111111111111111111111111111111111") + `
+[[projects]]
+  name = "github.com/org/other"
+  packages = ["."]
+  pruneopts = "UT"
+  revision = "2222222222222222222222222222222222222222"
+  version = "v0.1.0"
+`)
+	for name, content := range map[string]string{
+		path.Join(projectDir, "main.go"):    "package main\n\nimport _ \"github.com/org/dependency\"\n",
+		path.Join(projectDir, "Gopkg.toml"): "",
+		path.Join(projectDir, "Gopkg.lock"): oldLock,
+	} {
+		err = ioutil.WriteFile(name, []byte(content), 0644)
+		require.NoError(t, err)
+	}
+	for _, args := range [][]string{
+		{"init"},
+		{"add", "."},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-m", "initial"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = projectDir
+		output, err := cmd.CombinedOutput()
+		require.NoError(t, err, "git %v: %s", args, output)
+	}
+	err = ioutil.WriteFile(path.Join(projectDir, "Gopkg.lock"), []byte(newLock), 0644)
+	require.NoError(t, err)
+
+	diff := func(workingDir string, args ...string) (string, error) {
+		outputBuf := &bytes.Buffer{}
+		err := depplugin.Exec(append([]string{"diff"}, args...), depplugin.ExecOptions{
+			WorkingDir: workingDir,
+			Env: []string{
+				"GOPATH=" + gopath,
+				"DEPCACHEDIR=" + cacheDir,
+			},
+			Stdout: outputBuf,
+			Stderr: outputBuf,
+		})
+		return outputBuf.String(), err
+	}
+
+	// the committed lock is read relative to the project root from a subdirectory
+	output, err := diff(subDir)
+	require.NoError(t, err, "Output: %s", output)
+	assert.Equal(t, `--- HEAD:Gopkg.lock
++++ `+path.Join(projectDir, "Gopkg.lock")+`
+
+Added:
+  github.com/org/other v0.1.0 (2222222)
+
+Changed:
+  github.com/org/dependency
+    version    v1.0.0 -> v1.1.0
+    revision   0000000 -> 1111111
+`, output)
+
+	// two lock files are compared without a project
+	err = ioutil.WriteFile(path.Join(gopath, "old.lock"), []byte(oldLock), 0644)
+	require.NoError(t, err)
+	output, err = diff(gopath, "-format", "markdown", "src/github.com/org/project/Gopkg.lock", "old.lock")
+	require.NoError(t, err, "Output: %s", output)
+	assert.Equal(t, "| Project | Change | Version | Revision | Source | Packages | Prune |\n"+
+		"| --- | --- | --- | --- | --- | --- | --- |\n"+
+		"| `github.com/org/other` | removed | v0.1.0 | `2222222` |  | . | UT |\n"+
+		"| `github.com/org/dependency` | changed | v1.1.0 → v1.0.0 | `1111111` → `0000000` |  |  |  |\n", output)
+
+	// revisions that git would take for options are rejected
+	outputFile := path.Join(gopath, "output")
+	output, err = diff(projectDir, "--", "--output="+outputFile)
+	require.Error(t, err)
+	assert.Contains(t, output, "--output="+outputFile+" is neither a lock file nor a git revision")
+	_, err = os.Stat(outputFile)
+	assert.True(t, os.IsNotExist(err), "git wrote %s", outputFile)
+}
+
+func TestExecChangelog(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	gopath, cleanup, err := dirs.TempDir("", "")
+	require.NoError(t, err)
+	defer cleanup()
+
+	cacheDir := path.Join(gopath, "pkg", "dep")
+	err = os.MkdirAll(cacheDir, 0755)
+	require.NoError(t, err)
+
+	// github.com/org/lib has an old revision on a branch, and github.com/org/fork only has its default branch
+	reposDir, restore := useTestRepos(t, gopath)
+	defer restore()
+	git := func(dir string, args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		output, err := cmd.CombinedOutput()
+		require.NoError(t, err, "git %v: %s", args, output)
+	}
+	libDir := path.Join(reposDir, "lib")
+	commitTestRepo(t, reposDir, "lib", "v1.0.0", map[string]string{
+		"lib.go": "package lib\n",
+	})
+	git(libDir, "checkout", "-b", "feature")
+	oldRevision := commitTestRepo(t, reposDir, "lib", "feature", map[string]string{
+		"feature.go": "package lib\n",
+	})
+	git(libDir, "checkout", "-")
+	newRevision := commitTestRepo(t, reposDir, "lib", "v1.1.0", map[string]string{
+		"release.go": "package lib\n",
+	})
+	git(reposDir, "clone", "-q", "--single-branch", "--no-tags", libDir, path.Join(reposDir, "fork"))
+
+	lock := func(project string) string {
+		return "[[projects]]\n" + project + `
+  packages = ["."]
+  pruneopts = "UT"
+
+[solve-meta]
+  analyzer-name = "dep"
+  analyzer-version = 1
+  input-imports = ["github.com/org/lib"]
+  solver-name = "gps-cdcl"
+  solver-version = 1
+`
+	}
+	for name, content := range map[string]string{
+		"old.lock": lock(`  name = "github.com/org/lib"
+  branch = "feature"
+  revision = "` + oldRevision + `"`),
+		"new.lock": lock(`  name = "github.com/org/lib"
+  source = "github.com/org/fork"
+  revision = "` + newRevision + `"
+  version = "v1.1.0"`),
+	} {
+		err = ioutil.WriteFile(path.Join(gopath, name), []byte(content), 0644)
+		require.NoError(t, err)
+	}
+
+	// the fork does not contain the old revision, so the commits are listed from the old source
+	outputBuf := &bytes.Buffer{}
+	err = depplugin.Exec([]string{"changelog", "old.lock", "new.lock"}, depplugin.ExecOptions{
+		WorkingDir: gopath,
+		Env: []string{
+			"GOPATH=" + gopath,
+			"DEPCACHEDIR=" + cacheDir,
+		},
+		Stdout: outputBuf,
+		Stderr: outputBuf,
+	})
+	require.NoError(t, err, "Output: %s", outputBuf.String())
+	assert.Equal(t, "github.com/org/lib branch feature ("+oldRevision[:7]+") -> v1.1.0 ("+newRevision[:7]+"), "+
+		"source (none) -> github.com/org/fork, not a fast-forward: 1 commit added, 1 commit dropped\n"+
+		"  note: the source changed from (none) to github.com/org/fork, and the commits were listed from the old source "+
+		"because the new source could not list them\n"+
+		"  + "+newRevision[:7]+" release v1.1.0\n"+
+		"  - "+oldRevision[:7]+" release feature\n", outputBuf.String())
+}
+
+func TestExecEnsureTraceJSON(t *testing.T) {
+	gopath, cleanup, err := dirs.TempDir("", "")
+	require.NoError(t, err)
+	defer cleanup()
+
+	cacheDir := path.Join(gopath, "pkg", "dep")
+	err = os.MkdirAll(cacheDir, 0755)
+	require.NoError(t, err)
+
+	projectDir := path.Join(gopath, "src", "github.com", "org", "project")
+	err = os.MkdirAll(projectDir, 0755)
+	require.NoError(t, err)
+	for name, content := range map[string]string{
+		path.Join(projectDir, "main.go"):    "package main\n\nimport _ \"fmt\"\n",
+		path.Join(projectDir, "Gopkg.toml"): "",
+	} {
+		err = ioutil.WriteFile(name, []byte(content), 0644)
+		require.NoError(t, err)
+	}
+
+	outputBuf := &bytes.Buffer{}
+	err = depplugin.Exec([]string{"ensure", "-trace-json", "solve.jsonl"}, depplugin.ExecOptions{
+		WorkingDir: projectDir,
+		Env: []string{
+			"GOPATH=" + gopath,
+			"DEPCACHEDIR=" + cacheDir,
+		},
+		Stdout: outputBuf,
+		Stderr: outputBuf,
+	})
+	require.NoError(t, err, "Output: %s", outputBuf.String())
+
+	// the trace is written relative to the working directory
+	trace, err := ioutil.ReadFile(path.Join(projectDir, "solve.jsonl"))
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(trace)), "\n")
+	assert.Equal(t, `{"event":"select-root","depth":0,"project":"github.com/org/project","success":false}`, lines[0])
+	assert.Equal(t, `{"event":"finish","depth":0,"success":true}`, lines[len(lines)-1])
+}
+
+func TestExecFetch(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	gopath, cleanup, err := dirs.TempDir("", "")
+	require.NoError(t, err)
+	defer cleanup()
+
+	cacheDir := path.Join(gopath, "pkg", "dep")
+	err = os.MkdirAll(cacheDir, 0755)
+	require.NoError(t, err)
+
+	// github.com/org only has github.com/org/dependency
+	reposDir, restore := useTestRepos(t, gopath)
+	defer restore()
+	revision := commitTestRepo(t, reposDir, "dependency", "v1.0.0", map[string]string{
+		"dependency.go": "package dependency\n",
+	})
+
+	projectDir := path.Join(gopath, "src", "github.com", "org", "project")
+	err = os.MkdirAll(projectDir, 0755)
+	require.NoError(t, err)
+	for name, content := range map[string]string{
+		path.Join(projectDir, "Gopkg.toml"): "",
+		path.Join(projectDir, "Gopkg.lock"): `[[projects]]
+  name = "github.com/org/dependency"
+  packages = ["."]
+  pruneopts = "UT"
+  revision = "` + revision + `"
+  version = "v1.0.0"
+
+[[projects]]
+  name = "github.com/org/missing"
+  packages = ["."]
+  pruneopts = "UT"
+  revision = "0000000000000000000000000000000000000000"
+  version = "v1.0.0"
+
+[solve-meta]
+  analyzer-name = "dep"
+  analyzer-version = 1
+  input-imports = ["github.com/org/dependency", "github.com/org/missing"]
+  solver-name = "gps-cdcl"
+  solver-version = 1
+`,
+	} {
+		err = ioutil.WriteFile(name, []byte(content), 0644)
+		require.NoError(t, err)
+	}
+
+	stdoutBuf, stderrBuf := &bytes.Buffer{}, &bytes.Buffer{}
+	err = depplugin.Exec([]string{"fetch", "-parallel", "1"}, depplugin.ExecOptions{
+		WorkingDir: projectDir,
+		Env: []string{
+			"GOPATH=" + gopath,
+			"DEPCACHEDIR=" + cacheDir,
+		},
+		Stdout: stdoutBuf,
+		Stderr: stderrBuf,
+	})
+	require.Error(t, err, "Output: %s", stderrBuf.String())
+
+	// the failing source is reported at the end without stopping the others
+	assert.Equal(t, 1, depplugin.ExitCode(err))
+	assert.Equal(t, "", stdoutBuf.String())
+	output := stderrBuf.String()
+	assert.Contains(t, output, "Fetched github.com/org/dependency@v1.0.0\n")
+	assert.Contains(t, output, "Failed to fetch github.com/org/missing@v1.0.0\n")
+	assert.Contains(t, output, "\nFailed to fetch 1 of 2 projects:\n  github.com/org/missing: ")
+	assert.NotContains(t, output, "  github.com/org/dependency: ")
+	assert.Contains(t, output, "failed to fetch some projects")
+	_, err = os.Stat(path.Join(cacheDir, "sources", "https---github.com-org-dependency", "dependency.go"))
+	assert.NoError(t, err)
+}
+
+func TestExecGraph(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	gopath, cleanup, err := dirs.TempDir("", "")
+	require.NoError(t, err)
+	defer cleanup()
+
+	cacheDir := path.Join(gopath, "pkg", "dep")
+	err = os.MkdirAll(cacheDir, 0755)
+	require.NoError(t, err)
+
+	reposDir, restore := useTestRepos(t, gopath)
+	defer restore()
+	commitTestRepo(t, reposDir, "dependency", "v1.0.0", map[string]string{
+		"dependency.go": "package dependency\n",
+	})
+
+	projectDir := path.Join(gopath, "src", "github.com", "org", "project")
+	for name, content := range map[string]string{
+		"main.go":     "package main\n\nimport _ \"github.com/org/project/a\"\n",
+		"a/a.go":      "package a\n\nimport _ \"github.com/org/dependency\"\n",
+		"b/b.go":      "package b\n",
+		"b/b_test.go": "package b\n\nimport _ \"github.com/org/project/a\"\n",
+		"Gopkg.toml":  "",
+	} {
+		err = os.MkdirAll(path.Dir(path.Join(projectDir, name)), 0755)
+		require.NoError(t, err)
+		err = ioutil.WriteFile(path.Join(projectDir, name), []byte(content), 0644)
+		require.NoError(t, err)
+	}
+
+	run := func(args ...string) string {
+		stdoutBuf, stderrBuf := &bytes.Buffer{}, &bytes.Buffer{}
+		err := depplugin.Exec(args, depplugin.ExecOptions{
+			WorkingDir: projectDir,
+			Env: []string{
+				"GOPATH=" + gopath,
+				"DEPCACHEDIR=" + cacheDir,
+			},
+			Stdout: stdoutBuf,
+			Stderr: stderrBuf,
+		})
+		require.NoError(t, err, "Output: %s%s", stdoutBuf.String(), stderrBuf.String())
+		return stdoutBuf.String()
+	}
+	run("ensure")
+
+	assert.JSONEq(t, `{
+  "view": "projects",
+  "nodes": [
+    {"id": "github.com/org/project"},
+    {"id": "github.com/org/dependency", "version": "v1.0.0"}
+  ],
+  "edges": [
+    {"from": "github.com/org/project", "to": "github.com/org/dependency"}
+  ]
+}`, run("graph", "-format", "json"))
+
+	// test imports are not part of the graph
+	assert.Equal(t, `graph TD
+    n2["github.com/org/dependency"]
+    subgraph c0 ["github.com/org/project"]
+        n0["github.com/org/project/a"]
+        n1["github.com/org/project/b"]
+    end
+    n0 --> n2
+    c0 --> n0
+`, run("graph", "-format", "mermaid", "-packages"))
+
+	// packages that are not reachable from the root are left out
+	assert.JSONEq(t, `{
+  "view": "packages",
+  "nodes": [
+    {"id": "github.com/org/project/a", "cluster": "github.com/org/project"},
+    {"id": "github.com/org/dependency"}
+  ],
+  "clusters": [
+    {"id": "github.com/org/project", "packages": ["github.com/org/project/a"]}
+  ],
+  "edges": [
+    {"from": "github.com/org/project/a", "to": "github.com/org/dependency"}
+  ]
+}`, run("graph", "-format", "json", "-packages", "-root", "github.com/org/project/a"))
+}
+
+func TestExecWhy(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	gopath, cleanup, err := dirs.TempDir("", "")
+	require.NoError(t, err)
+	defer cleanup()
+
+	cacheDir := path.Join(gopath, "pkg", "dep")
+	err = os.MkdirAll(cacheDir, 0755)
+	require.NoError(t, err)
+
+	reposDir, restore := useTestRepos(t, gopath)
+	defer restore()
+	commitTestRepo(t, reposDir, "dependency", "v1.0.0", map[string]string{
+		"dependency.go": "package dependency\n\nimport _ \"github.com/org/dependency/sub\"\n",
+		"sub/sub.go":    "package sub\n",
+	})
+
+	projectDir := path.Join(gopath, "src", "github.com", "org", "project")
+	for name, content := range map[string]string{
+		"main.go":     "package main\n\nimport _ \"github.com/org/project/a\"\n",
+		"a/a.go":      "package a\n\nimport _ \"github.com/org/dependency\"\n",
+		"b/b.go":      "package b\n",
+		"b/b_test.go": "package b\n\nimport _ \"github.com/org/dependency/sub\"\n",
+		"Gopkg.toml":  "",
+	} {
+		err = os.MkdirAll(path.Dir(path.Join(projectDir, name)), 0755)
+		require.NoError(t, err)
+		err = ioutil.WriteFile(path.Join(projectDir, name), []byte(content), 0644)
+		require.NoError(t, err)
+	}
+
+	run := func(args ...string) string {
+		stdoutBuf, stderrBuf := &bytes.Buffer{}, &bytes.Buffer{}
+		err := depplugin.Exec(args, depplugin.ExecOptions{
+			WorkingDir: projectDir,
+			Env: []string{
+				"GOPATH=" + gopath,
+				"DEPCACHEDIR=" + cacheDir,
+			},
+			Stdout: stdoutBuf,
+			Stderr: stderrBuf,
+		})
+		require.NoError(t, err, "Output: %s%s", stdoutBuf.String(), stderrBuf.String())
+		return stdoutBuf.String()
+	}
+	run("ensure")
+
+	assert.Equal(t, `# github.com/org/project/a
+github.com/org/project/a
+github.com/org/dependency
+github.com/org/dependency/sub
+
+# github.com/org/project/b (test imports only)
+github.com/org/project/b
+github.com/org/dependency/sub
+`, run("why", "github.com/org/dependency/sub"))
+
+	// chains to a project root end at the first package of the project
+	assert.JSONEq(t, `{
+  "Target": "github.com/org/dependency",
+  "Chains": [
+    {"Packages": ["github.com/org/project/a", "github.com/org/dependency"], "TestOnly": false},
+    {"Packages": ["github.com/org/project/b", "github.com/org/dependency/sub"], "TestOnly": true}
+  ]
+}`, run("why", "-json", "github.com/org/dependency"))
+
+	assert.Equal(t, "(github.com/org/other is not imported by any package of github.com/org/project)\n", run("why", "github.com/org/other"))
+}
+
+func TestExecExplain(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	gopath, cleanup, err := dirs.TempDir("", "")
+	require.NoError(t, err)
+	defer cleanup()
+
+	cacheDir := path.Join(gopath, "pkg", "dep")
+	err = os.MkdirAll(cacheDir, 0755)
+	require.NoError(t, err)
+
+	reposDir, restore := useTestRepos(t, gopath)
+	defer restore()
+	for _, version := range []string{"v1.0.0", "v1.1.0", "v2.0.0"} {
+		commitTestRepo(t, reposDir, "dependency", version, map[string]string{
+			"dependency.go": "package dependency\n\nconst Version = \"" + version + "\"\n",
+		})
+	}
+
+	projectDir := path.Join(gopath, "src", "github.com", "org", "project")
+	err = os.MkdirAll(projectDir, 0755)
+	require.NoError(t, err)
+	for name, content := range map[string]string{
+		"main.go":    "package main\n\nimport _ \"github.com/org/dependency\"\n",
+		"Gopkg.toml": "[[constraint]]\n  name = \"github.com/org/dependency\"\n  version = \"^1.0.0\"\n",
+	} {
+		err = ioutil.WriteFile(path.Join(projectDir, name), []byte(content), 0644)
+		require.NoError(t, err)
+	}
+
+	run := func(args ...string) string {
+		stdoutBuf, stderrBuf := &bytes.Buffer{}, &bytes.Buffer{}
+		err := depplugin.Exec(args, depplugin.ExecOptions{
+			WorkingDir: projectDir,
+			Env: []string{
+				"GOPATH=" + gopath,
+				"DEPCACHEDIR=" + cacheDir,
+			},
+			Stdout: stdoutBuf,
+			Stderr: stderrBuf,
+		})
+		require.NoError(t, err, "Output: %s%s", stdoutBuf.String(), stderrBuf.String())
+		return stdoutBuf.String()
+	}
+	run("ensure")
+
+	assert.Equal(t, `# github.com/org/dependency
+
+Constraints:
+  ^1.0.0 imposed by github.com/org/project (root)
+    imports github.com/org/dependency
+
+Locked version: v1.1.0
+
+Selected v1.1.0 because the version in Gopkg.lock satisfies all constraints.
+`, run("explain", "github.com/org/dependency"))
+
+	// the newest version is tried first when updating, and rejected by the constraint
+	assert.JSONEq(t, `{
+  "Project": "github.com/org/dependency",
+  "Constraints": [
+    {"Depender": "github.com/org/project", "Constraint": "^1.0.0", "Override": false, "Packages": ["github.com/org/dependency"]}
+  ],
+  "LockedVersion": "v1.1.0",
+  "ChangeRequested": true,
+  "Downgrade": false,
+  "Rejected": [
+    {"Version": "v2.0.0", "Reason": "github.com/org/dependency@v2.0.0 not allowed by constraint ^1.0.0:\n  ^1.0.0 from (root)\n"}
+  ],
+  "Selected": "v1.1.0",
+  "Reason": "changed"
+}`, run("explain", "-update", "-json", "github.com/org/dependency"))
+}
+
+func TestExecEnsureJSONFailure(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	gopath, cleanup, err := dirs.TempDir("", "")
+	require.NoError(t, err)
+	defer cleanup()
+
+	cacheDir := path.Join(gopath, "pkg", "dep")
+	err = os.MkdirAll(cacheDir, 0755)
+	require.NoError(t, err)
+
+	reposDir, restore := useTestRepos(t, gopath)
+	defer restore()
+	commitTestRepo(t, reposDir, "dependency", "v1.0.0", map[string]string{
+		"dependency.go": "package dependency\n",
+	})
+
+	// no version of the dependency satisfies the constraint
+	projectDir := path.Join(gopath, "src", "github.com", "org", "project")
+	err = os.MkdirAll(projectDir, 0755)
+	require.NoError(t, err)
+	for name, content := range map[string]string{
+		"main.go":    "package main\n\nimport _ \"github.com/org/dependency\"\n",
+		"Gopkg.toml": "[[constraint]]\n  name = \"github.com/org/dependency\"\n  version = \"^2.0.0\"\n",
+	} {
+		err = ioutil.WriteFile(path.Join(projectDir, name), []byte(content), 0644)
+		require.NoError(t, err)
+	}
+
+	ensure := func(args ...string) (string, string, error) {
+		stdoutBuf, stderrBuf := &bytes.Buffer{}, &bytes.Buffer{}
+		err := depplugin.Exec(append([]string{"ensure"}, args...), depplugin.ExecOptions{
+			WorkingDir: projectDir,
+			Env: []string{
+				"GOPATH=" + gopath,
+				"DEPCACHEDIR=" + cacheDir,
+			},
+			Stdout: stdoutBuf,
+			Stderr: stderrBuf,
+		})
+		return stdoutBuf.String(), stderrBuf.String(), err
+	}
+
+	remedy := "change the constraint ^2.0.0 on github.com/org/dependency in Gopkg.toml"
+	stdout, stderr, err := ensure()
+	require.Error(t, err)
+	assert.Equal(t, 1, depplugin.ExitCode(err))
+	assert.Equal(t, "", stdout)
+	assert.Contains(t, stderr, "Solving failure: No versions of github.com/org/dependency met constraints:")
+	assert.Contains(t, stderr, "\n\nPossible remedies:\n  * "+remedy+"\n")
+
+	stdout, _, err = ensure("-json-failure")
+	require.Error(t, err)
+	assert.Equal(t, 1, depplugin.ExitCode(err))
+	var failure struct {
+		Code    string
+		Project string
+		Causes  []struct {
+			Code    string
+			Version string
+		}
+		Remedies []string
+	}
+	err = json.Unmarshal([]byte(stdout), &failure)
+	require.NoError(t, err, "Output: %s", stdout)
+	assert.Equal(t, "no-version", failure.Code)
+	assert.Equal(t, "github.com/org/dependency", failure.Project)
+	require.Len(t, failure.Causes, 2)
+	assert.Equal(t, "version-not-allowed", failure.Causes[0].Code)
+	assert.Equal(t, "v1.0.0", failure.Causes[0].Version)
+	assert.Equal(t, []string{remedy}, failure.Remedies)
+
+	_, err = os.Stat(path.Join(projectDir, "Gopkg.lock"))
+	assert.True(t, os.IsNotExist(err), "Gopkg.lock was written")
+}
+
+func TestExecEnsureMinimal(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	gopath, cleanup, err := dirs.TempDir("", "")
+	require.NoError(t, err)
+	defer cleanup()
+
+	cacheDir := path.Join(gopath, "pkg", "dep")
+	err = os.MkdirAll(cacheDir, 0755)
+	require.NoError(t, err)
+
+	reposDir, restore := useTestRepos(t, gopath)
+	defer restore()
+	for _, version := range []string{"v1.0.0", "v1.1.0", "v1.2.0"} {
+		commitTestRepo(t, reposDir, "dependency", version, map[string]string{
+			"dependency.go": "package dependency\n\nconst Version = \"" + version + "\"\n",
+		})
+	}
+
+	projectDir := path.Join(gopath, "src", "github.com", "org", "project")
+	err = os.MkdirAll(projectDir, 0755)
+	require.NoError(t, err)
+	constraint := "[[constraint]]\n  name = \"github.com/org/dependency\"\n  version = \"^1.1.0\"\n"
+	for name, content := range map[string]string{
+		"main.go":    "package main\n\nimport _ \"github.com/org/dependency\"\n",
+		"Gopkg.toml": constraint,
+	} {
+		err = ioutil.WriteFile(path.Join(projectDir, name), []byte(content), 0644)
+		require.NoError(t, err)
+	}
+
+	ensure := func(args ...string) string {
+		stdoutBuf, stderrBuf := &bytes.Buffer{}, &bytes.Buffer{}
+		err := depplugin.Exec(append([]string{"ensure"}, args...), depplugin.ExecOptions{
+			WorkingDir: projectDir,
+			Env: []string{
+				"GOPATH=" + gopath,
+				"DEPCACHEDIR=" + cacheDir,
+			},
+			Stdout: stdoutBuf,
+			Stderr: stderrBuf,
+		})
+		require.NoError(t, err, "Output: %s%s", stdoutBuf.String(), stderrBuf.String())
+		return stdoutBuf.String()
+	}
+	lockedVersion := func() string {
+		lock, err := ioutil.ReadFile(path.Join(projectDir, "Gopkg.lock"))
+		require.NoError(t, err)
+		for _, line := range strings.Split(string(lock), "\n") {
+			if strings.HasPrefix(line, "  version = ") {
+				return strings.Trim(strings.TrimPrefix(line, "  version = "), `"`)
+			}
+		}
+		return ""
+	}
+
+	ensure()
+	assert.Equal(t, "v1.2.0", lockedVersion())
+
+	// the differences are reported without writing anything
+	assert.Equal(t, "PROJECT                    MINIMAL  MAXIMAL\n"+
+		"github.com/org/dependency  v1.1.0   v1.2.0\n", ensure("-update", "-minimal-diff"))
+	assert.Equal(t, "v1.2.0", lockedVersion())
+
+	ensure("-update", "-minimal")
+	assert.Equal(t, "v1.1.0", lockedVersion())
+
+	// the manifest setting applies to every solve
+	ensure("-update")
+	assert.Equal(t, "v1.2.0", lockedVersion())
+	err = ioutil.WriteFile(path.Join(projectDir, "Gopkg.toml"), []byte("minimal-versions = true\n\n"+constraint), 0644)
+	require.NoError(t, err)
+	ensure("-update")
+	assert.Equal(t, "v1.1.0", lockedVersion())
+	vendored, err := ioutil.ReadFile(path.Join(projectDir, "vendor", "github.com", "org", "dependency", "dependency.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(vendored), `"v1.1.0"`)
+}
+
+// useTestRepos makes git, which runs in the environment of the test rather than that of dep, fetch the projects under
+// github.com/org from the repositories in the returned directory under dir until the returned function is called.
+func useTestRepos(t *testing.T, dir string) (string, func()) {
+	home := path.Join(dir, "home")
+	reposDir := path.Join(dir, "repos")
+	err := os.MkdirAll(home, 0755)
+	require.NoError(t, err)
+	err = os.MkdirAll(reposDir, 0755)
+	require.NoError(t, err)
+	err = ioutil.WriteFile(path.Join(home, ".gitconfig"), []byte("[url \"file://"+reposDir+"/\"]\n\tinsteadOf = https://github.com/org/\n"), 0644)
+	require.NoError(t, err)
+
+	var restore []func()
+	for k, v := range map[string]string{"HOME": home, "XDG_CONFIG_HOME": home, "GIT_CONFIG_NOSYSTEM": "1"} {
+		k := k
+		old, ok := os.LookupEnv(k)
+		err = os.Setenv(k, v)
+		require.NoError(t, err)
+		restore = append(restore, func() {
+			if ok {
+				_ = os.Setenv(k, old)
+			} else {
+				_ = os.Unsetenv(k)
+			}
+		})
+	}
+	return reposDir, func() {
+		for _, f := range restore {
+			f()
+		}
+	}
+}
+
+// commitTestRepo writes the provided files to the named repository in reposDir, creating the repository if necessary,
+// and commits them with the provided tag. Returns the revision of the commit.
+func commitTestRepo(t *testing.T, reposDir, name, tag string, files map[string]string) string {
+	repoDir := path.Join(reposDir, name)
+	for file, content := range files {
+		err := os.MkdirAll(path.Dir(path.Join(repoDir, file)), 0755)
+		require.NoError(t, err)
+		err = ioutil.WriteFile(path.Join(repoDir, file), []byte(content), 0644)
+		require.NoError(t, err)
+	}
+
+	git := func(args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repoDir
+		output, err := cmd.CombinedOutput()
+		require.NoError(t, err, "git %v: %s", args, output)
+		return strings.TrimSpace(string(output))
+	}
+	if _, err := os.Stat(path.Join(repoDir, ".git")); os.IsNotExist(err) {
+		git("init")
+	}
+	git("add", ".")
+	git("-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-m", "release "+tag)
+	git("tag", tag)
+	return git("rev-parse", "HEAD")
+}
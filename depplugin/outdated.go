@@ -0,0 +1,41 @@
+// Copyright (c) 2018 Palantir Technologies Inc. All rights reserved.
+// Use of this source code is governed by the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package depplugin
+
+import (
+	"io"
+)
+
+// OutdatedFormat is the output format of Outdated.
+type OutdatedFormat string
+
+const (
+	// OutdatedFormatTable outputs the report as a table.
+	OutdatedFormatTable OutdatedFormat = "table"
+	// OutdatedFormatJSON outputs the report as JSON.
+	OutdatedFormatJSON OutdatedFormat = "json"
+	// OutdatedFormatMarkdown outputs the report as a Markdown table.
+	OutdatedFormatMarkdown OutdatedFormat = "markdown"
+)
+
+// OutdatedParam specifies the output of Outdated.
+type OutdatedParam struct {
+	// Format is the output format. If blank, the output is a table.
+	Format OutdatedFormat
+	// All includes the dependencies that are up to date.
+	All bool
+}
+
+// Outdated runs "dep outdated" with the flags that correspond to the provided OutdatedParam.
+func Outdated(param Param, outdatedParam OutdatedParam, stdout, stderr io.Writer) error {
+	args := []string{"outdated"}
+	if outdatedParam.Format != "" {
+		args = append(args, "-format", string(outdatedParam.Format))
+	}
+	if outdatedParam.All {
+		args = append(args, "-all")
+	}
+	return Run(param, args, stdout, stderr)
+}
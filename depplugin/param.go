@@ -0,0 +1,87 @@
+// Copyright (c) 2018 Palantir Technologies Inc. All rights reserved.
+// Use of this source code is governed by the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package depplugin
+
+import (
+	"time"
+)
+
+type VerifyStrategy string
+
+const (
+	// VerifyStrategyAll verifies both that Gopkg.lock is in sync with the project and that vendor is in sync with
+	// Gopkg.lock.
+	VerifyStrategyAll VerifyStrategy = "all"
+	// VerifyStrategyLockOnly only verifies that Gopkg.lock is in sync with the project.
+	VerifyStrategyLockOnly VerifyStrategy = "lock-only"
+	// VerifyStrategyVendorOnly only verifies that vendor is in sync with Gopkg.lock.
+	VerifyStrategyVendorOnly VerifyStrategy = "vendor-only"
+)
+
+// VerifyStrategies returns all of the valid verify strategies.
+func VerifyStrategies() []VerifyStrategy {
+	return []VerifyStrategy{
+		VerifyStrategyAll,
+		VerifyStrategyLockOnly,
+		VerifyStrategyVendorOnly,
+	}
+}
+
+func (s VerifyStrategy) IsValid() bool {
+	for _, curr := range VerifyStrategies() {
+		if s == curr {
+			return true
+		}
+	}
+	return false
+}
+
+type StatusFormat string
+
+const (
+	// StatusFormatTable outputs the status as a table.
+	StatusFormatTable StatusFormat = "table"
+	// StatusFormatJSON outputs the status as JSON.
+	StatusFormatJSON StatusFormat = "json"
+)
+
+// StatusFormats returns all of the valid status formats.
+func StatusFormats() []StatusFormat {
+	return []StatusFormat{
+		StatusFormatTable,
+		StatusFormatJSON,
+	}
+}
+
+func (f StatusFormat) IsValid() bool {
+	for _, curr := range StatusFormats() {
+		if f == curr {
+			return true
+		}
+	}
+	return false
+}
+
+// Param contains the project-level settings used when running dep.
+type Param struct {
+	// EnsureArgs are provided to "dep ensure" before the arguments specified on the command line.
+	EnsureArgs []string
+	// CacheDir is used as $DEPCACHEDIR if that variable is not already set.
+	CacheDir string
+	// CacheAge is used as $DEPCACHEAGE if that variable is not already set.
+	CacheAge time.Duration
+	// SharedCacheDir is used as $DEPSHAREDCACHEDIR if that variable is not already set.
+	SharedCacheDir string
+	// Offline sets $DEPOFFLINE if that variable is not already set.
+	Offline bool
+	// DigestCache sets $DEPDIGESTCACHE if that variable is not already set.
+	DigestCache bool
+	// VerifyStrategy specifies the checks performed by Verify.
+	VerifyStrategy VerifyStrategy
+	// NoVerify contains project roots that are treated as "noverify" in addition to those in Gopkg.toml.
+	NoVerify []string
+	// StatusFormat is the default output format of Status.
+	StatusFormat StatusFormat
+}
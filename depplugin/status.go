@@ -0,0 +1,48 @@
+// Copyright (c) 2018 Palantir Technologies Inc. All rights reserved.
+// Use of this source code is governed by the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package depplugin
+
+import (
+	"io"
+)
+
+// StatusParam specifies the output of Status.
+type StatusParam struct {
+	// Format is the output format. If blank, the StatusFormat of the Param is used. Ignored if Template is non-empty.
+	Format StatusFormat
+	// Template is a text/template used to format the output.
+	Template string
+	// Detail includes more detail in the output.
+	Detail bool
+	// Old only outputs the dependencies that are out of date.
+	Old bool
+	// Missing only outputs the dependencies that are imported but missing from Gopkg.lock.
+	Missing bool
+}
+
+// Status runs "dep status" with the flags that correspond to the provided StatusParam.
+func Status(param Param, statusParam StatusParam, stdout, stderr io.Writer) error {
+	format := statusParam.Format
+	if format == "" {
+		format = param.StatusFormat
+	}
+
+	args := []string{"status"}
+	if statusParam.Template != "" {
+		args = append(args, "-f", statusParam.Template)
+	} else if format == StatusFormatJSON {
+		args = append(args, "-json")
+	}
+	if statusParam.Detail {
+		args = append(args, "-detail")
+	}
+	if statusParam.Old {
+		args = append(args, "-old")
+	}
+	if statusParam.Missing {
+		args = append(args, "-missing")
+	}
+	return Run(param, args, stdout, stderr)
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2018 Palantir Technologies Inc. All rights reserved.
+// Use of this source code is governed by the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package depplugin
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/palantir/godel-dep-plugin/generated_src/depcmd"
+)
+
+// Verify checks that Gopkg.lock is in sync with the imports of the project and Gopkg.toml and that vendor is in sync
+// with Gopkg.lock, as determined by the VerifyStrategy of the provided Param. The checks are performed in-process. If
+// the project is out of sync, the returned error contains a report of the differences. Warnings emitted while loading
+// the project are written to the provided stderr writer.
+func Verify(param Param, stdout, stderr io.Writer) error {
+	cfg, err := NewConfig(ExecOptions{
+		Env:    depEnv(param),
+		Stdout: stdout,
+		Stderr: stderr,
+	})
+	if err != nil {
+		return err
+	}
+	result, err := cfg.Check(
+		param.VerifyStrategy == VerifyStrategyVendorOnly,
+		param.VerifyStrategy == VerifyStrategyLockOnly,
+		param.NoVerify,
+	)
+	if err != nil {
+		return err
+	}
+	if result.InSync() {
+		return nil
+	}
+	return errors.New(checkReport(result))
+}
+
+// checkReport returns a report of the properties of the provided result that are out of sync. All of the entries in
+// the report are sorted so that the output is deterministic.
+func checkReport(result depcmd.CheckResult) string {
+	buf := &bytes.Buffer{}
+	if !result.LockInSync() {
+		var lines []string
+		lsat := result.LockSatisfaction
+		for _, missing := range lsat.MissingImports {
+			lines = append(lines, fmt.Sprintf("%s: imported or required, but missing from Gopkg.lock's input-imports", missing))
+		}
+		for _, excess := range lsat.ExcessImports {
+			lines = append(lines, fmt.Sprintf("%s: in Gopkg.lock's input-imports, but neither imported nor required", excess))
+		}
+		for pr, unmatched := range lsat.UnmetOverrides {
+			lines = append(lines, fmt.Sprintf("%s@%s: not allowed by override %s", pr, unmatched.V, unmatched.C))
+		}
+		for pr, unmatched := range lsat.UnmetConstraints {
+			lines = append(lines, fmt.Sprintf("%s@%s: not allowed by constraint %s", pr, unmatched.V, unmatched.C))
+		}
+		for pr, lpd := range result.LockDelta.ProjectDeltas {
+			if lpd.PruneOptsBefore != lpd.PruneOptsAfter {
+				before := lpd.PruneOptsBefore & ^depcmd.PruneNestedVendorDirs
+				after := lpd.PruneOptsAfter & ^depcmd.PruneNestedVendorDirs
+				lines = append(lines, fmt.Sprintf("%s: prune options changed (%s -> %s)", pr, before, after))
+			}
+			if lpd.PruneParamsChanged() {
+				before := orNone(lpd.PruneParamsBefore.String())
+				after := orNone(lpd.PruneParamsAfter.String())
+				lines = append(lines, fmt.Sprintf("%s: prune parameters changed (%s -> %s)", pr, before, after))
+			}
+			if lpd.HashVersionWasZero() {
+				lines = append(lines, fmt.Sprintf("%s: no hash digest in Gopkg.lock", pr))
+			}
+		}
+		writeReportSection(buf, "Gopkg.lock is out of sync with imports and Gopkg.toml:", lines)
+	}
+
+	if !result.VendorInSync() {
+		var lines []string
+		for pr, status := range result.VendorStatus {
+			if !result.VendorFailed(pr) {
+				continue
+			}
+			lines = append(lines, fmt.Sprintf("%s: %s", pr, vendorStatusDescription(status)))
+		}
+		writeReportSection(buf, "vendor is out of sync with Gopkg.lock:", lines)
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func writeReportSection(w io.Writer, header string, lines []string) {
+	sort.Strings(lines)
+	fmt.Fprintln(w, header)
+	for _, line := range lines {
+		fmt.Fprintln(w, "  "+line)
+	}
+}
+
+// orNone returns s, or "(none)" if s is empty.
+func orNone(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
+
+func vendorStatusDescription(status depcmd.VendorStatus) string {
+	switch status {
+	case depcmd.NotInTree:
+		return "missing from vendor"
+	case depcmd.NotInLock:
+		return "in vendor, but not in Gopkg.lock"
+	case depcmd.DigestMismatchInLock:
+		return "hash of vendored tree not equal to digest in Gopkg.lock"
+	case depcmd.EmptyDigestInLock:
+		return "no digest in Gopkg.lock to compare against hash of vendored tree"
+	case depcmd.HashVersionMismatch:
+		return fmt.Sprintf("hash algorithm mismatch, want version %d", depcmd.HashVersion)
+	default:
+		return status.String()
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright (c) 2018 Palantir Technologies Inc. All rights reserved.
+// Use of this source code is governed by the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package depplugin_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/nmiyake/pkg/dirs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/palantir/godel-dep-plugin/depplugin"
+)
+
+const (
+	verifyMainGo = "package main\n\nimport _ \"github.com/org/dependency\"\n"
+	verifyLock   = `[[projects]]
+  digest = "2:a3a5914f57cd846f260e4a30256484aa26d5864d59ede11ea53913d6189711f8"
+  name = "github.com/org/dependency"
+  packages = ["."]
+  pruneopts = "UT"
+  revision = "0000000000000000000000000000000000000000"
+  version = "v1.0.0"
+
+[solve-meta]
+  analyzer-name = "dep"
+  analyzer-version = 1
+  input-imports = ["github.com/org/dependency"]
+  solver-name = "gps-cdcl"
+  solver-version = 1
+`
+)
+
+func TestVerify(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		// files replace those of an in-sync project, which are left out if the replacement is empty
+		files     map[string]string
+		strategy  depplugin.VerifyStrategy
+		noVerify  []string
+		checkArgs []string
+		want      string
+		wantCode  int
+	}{
+		{
+			name:     "in sync",
+			strategy: depplugin.VerifyStrategyAll,
+		},
+		{
+			name: "import missing from lock",
+			files: map[string]string{
+				"main.go": verifyMainGo + "import _ \"github.com/org/other\"\n",
+			},
+			strategy: depplugin.VerifyStrategyAll,
+			want: "Gopkg.lock is out of sync with imports and Gopkg.toml:\n" +
+				"  github.com/org/other: imported or required, but missing from Gopkg.lock's input-imports",
+			wantCode: 1,
+		},
+		{
+			name: "prune options changed",
+			files: map[string]string{
+				"Gopkg.toml": "[prune]\n  go-tests = true\n",
+			},
+			strategy: depplugin.VerifyStrategyAll,
+			want: "Gopkg.lock is out of sync with imports and Gopkg.toml:\n" +
+				"  github.com/org/dependency: prune options changed (UT -> T)",
+			wantCode: 1,
+		},
+		{
+			name: "prune parameters changed",
+			files: map[string]string{
+				"Gopkg.toml": "[prune]\n  go-tests = true\n  unused-packages = true\n\n" +
+					"  [[prune.project]]\n    name = \"github.com/org/dependency\"\n    keep = [\"*.txt\"]\n",
+			},
+			strategy: depplugin.VerifyStrategyAll,
+			want: "Gopkg.lock is out of sync with imports and Gopkg.toml:\n" +
+				"  github.com/org/dependency: prune parameters changed ((none) -> keep=*.txt)",
+			wantCode: 1,
+		},
+		{
+			name: "modified vendored tree",
+			files: map[string]string{
+				"vendor/github.com/org/dependency/dependency.go": "package dependency\n\nvar Modified = true\n",
+			},
+			strategy: depplugin.VerifyStrategyAll,
+			want: "vendor is out of sync with Gopkg.lock:\n" +
+				"  github.com/org/dependency: hash of vendored tree not equal to digest in Gopkg.lock",
+			wantCode: 1,
+		},
+		{
+			name: "modified vendored tree with noverify",
+			files: map[string]string{
+				"vendor/github.com/org/dependency/dependency.go": "package dependency\n\nvar Modified = true\n",
+			},
+			strategy:  depplugin.VerifyStrategyAll,
+			noVerify:  []string{"github.com/org/dependency"},
+			checkArgs: []string{"-noverify", "github.com/org/dependency"},
+		},
+		{
+			name: "missing vendored project",
+			files: map[string]string{
+				"vendor/github.com/org/dependency/dependency.go": "",
+			},
+			strategy: depplugin.VerifyStrategyAll,
+			want: "vendor is out of sync with Gopkg.lock:\n" +
+				"  github.com/org/dependency: missing from vendor",
+			wantCode: 1,
+		},
+		{
+			name: "extra vendored project",
+			files: map[string]string{
+				"vendor/github.com/org/extra/extra.go": "package extra\n",
+			},
+			strategy: depplugin.VerifyStrategyAll,
+			want: "vendor is out of sync with Gopkg.lock:\n" +
+				"  github.com/org/extra: in vendor, but not in Gopkg.lock",
+			wantCode: 1,
+		},
+		{
+			name: "lock and vendor out of sync",
+			files: map[string]string{
+				"main.go": verifyMainGo + "import _ \"github.com/org/other\"\n",
+				"vendor/github.com/org/dependency/dependency.go": "package dependency\n\nvar Modified = true\n",
+			},
+			strategy: depplugin.VerifyStrategyAll,
+			want: "Gopkg.lock is out of sync with imports and Gopkg.toml:\n" +
+				"  github.com/org/other: imported or required, but missing from Gopkg.lock's input-imports\n" +
+				"vendor is out of sync with Gopkg.lock:\n" +
+				"  github.com/org/dependency: hash of vendored tree not equal to digest in Gopkg.lock",
+			wantCode: 1,
+		},
+		{
+			name: "lock only",
+			files: map[string]string{
+				"vendor/github.com/org/dependency/dependency.go": "package dependency\n\nvar Modified = true\n",
+			},
+			strategy:  depplugin.VerifyStrategyLockOnly,
+			checkArgs: []string{"-skip-vendor"},
+		},
+		{
+			name: "vendor only",
+			files: map[string]string{
+				"main.go": verifyMainGo + "import _ \"github.com/org/other\"\n",
+			},
+			strategy:  depplugin.VerifyStrategyVendorOnly,
+			checkArgs: []string{"-skip-lock"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			gopath, cleanup, err := dirs.TempDir("", "")
+			require.NoError(t, err)
+			defer cleanup()
+
+			cacheDir := path.Join(gopath, "pkg", "dep")
+			err = os.MkdirAll(cacheDir, 0755)
+			require.NoError(t, err)
+
+			projectDir := path.Join(gopath, "src", "github.com", "org", "project")
+			files := map[string]string{
+				"main.go":    verifyMainGo,
+				"Gopkg.toml": "[prune]\n  go-tests = true\n  unused-packages = true\n",
+				"Gopkg.lock": verifyLock,
+				"vendor/github.com/org/dependency/dependency.go": "package dependency\n",
+			}
+			for name, content := range tc.files {
+				files[name] = content
+			}
+			for name, content := range files {
+				if content == "" {
+					continue
+				}
+				err = os.MkdirAll(path.Dir(path.Join(projectDir, name)), 0755)
+				require.NoError(t, err)
+				err = ioutil.WriteFile(path.Join(projectDir, name), []byte(content), 0644)
+				require.NoError(t, err)
+			}
+
+			// Verify runs in the working directory and environment of the process
+			wd, err := os.Getwd()
+			require.NoError(t, err)
+			err = os.Chdir(projectDir)
+			require.NoError(t, err)
+			defer os.Chdir(wd)
+			for k, v := range map[string]string{"GOPATH": gopath, "DEPCACHEDIR": cacheDir, "DEPOFFLINE": "1"} {
+				old, ok := os.LookupEnv(k)
+				err = os.Setenv(k, v)
+				require.NoError(t, err)
+				if ok {
+					defer os.Setenv(k, old)
+				} else {
+					defer os.Unsetenv(k)
+				}
+			}
+
+			outputBuf := &bytes.Buffer{}
+			err = depplugin.Verify(depplugin.Param{
+				VerifyStrategy: tc.strategy,
+				NoVerify:       tc.noVerify,
+			}, outputBuf, outputBuf)
+			if tc.want == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Equal(t, tc.want, err.Error())
+			}
+			assert.Equal(t, "", outputBuf.String())
+
+			// dep check agrees, with the corresponding exit code
+			err = depplugin.Exec(append([]string{"check"}, tc.checkArgs...), depplugin.ExecOptions{
+				WorkingDir: projectDir,
+				Env: []string{
+					"GOPATH=" + gopath,
+					"DEPCACHEDIR=" + cacheDir,
+					"DEPOFFLINE=1",
+				},
+				Stdout: outputBuf,
+				Stderr: outputBuf,
+			})
+			assert.Equal(t, tc.wantCode, depplugin.ExitCode(err), "Output: %s", outputBuf.String())
+		})
+	}
+}
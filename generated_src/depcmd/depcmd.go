@@ -0,0 +1,61 @@
+// Copyright (c) 2018 Palantir Technologies Inc. All rights reserved.
+// Use of this source code is governed by the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// Package depcmd exposes the parts of the amalgomated dep that are used as a library by the plugin. The amalgomated
+// packages are internal to generated_src, so they cannot be imported directly by the rest of the project. This package is
+// written by hand: amalgomate only writes the internal directory and the amalgomateddep package.
+package depcmd
+
+import (
+	"github.com/palantir/godel-dep-plugin/generated_src/internal/github.com/golang/dep"
+	cmddep "github.com/palantir/godel-dep-plugin/generated_src/internal/github.com/golang/dep/cmd/dep"
+	"github.com/palantir/godel-dep-plugin/generated_src/internal/github.com/golang/dep/gps"
+	"github.com/palantir/godel-dep-plugin/generated_src/internal/github.com/golang/dep/gps/verify"
+)
+
+// Config specifies a full configuration for an in-process dep execution.
+type Config = cmddep.Config
+
+// CheckResult is the result of Config.Check.
+type CheckResult = dep.CheckResult
+
+// DetailStatus is the status of a single dependency returned by Config.Status.
+type DetailStatus = cmddep.DetailStatus
+
+// MissingStatus is a project that is imported but missing from Gopkg.lock returned by Config.Status.
+type MissingStatus = cmddep.MissingStatus
+
+// Lock is the in-memory representation of Gopkg.lock.
+type Lock = dep.Lock
+
+// VerifiableProject is a locked project along with its prune options and vendor digest.
+type VerifiableProject = verify.VerifiableProject
+
+// VendorStatus is the status of a single project root in vendor relative to Gopkg.lock.
+type VendorStatus = verify.VendorStatus
+
+var (
+	// ReadLock reads a lock in the Gopkg.lock format.
+	ReadLock = dep.ReadLock
+	// VersionComponentStrings returns the revision, branch and version of a version as strings.
+	VersionComponentStrings = gps.VersionComponentStrings
+)
+
+const (
+	// LockName is the name of the lock file.
+	LockName = dep.LockName
+
+	NotInLock            = verify.NotInLock
+	NotInTree            = verify.NotInTree
+	NoMismatch           = verify.NoMismatch
+	EmptyDigestInLock    = verify.EmptyDigestInLock
+	DigestMismatchInLock = verify.DigestMismatchInLock
+	HashVersionMismatch  = verify.HashVersionMismatch
+
+	// PruneNestedVendorDirs is the prune option that is always applied by dep, so it is not reported as a change.
+	PruneNestedVendorDirs = gps.PruneNestedVendorDirs
+
+	// HashVersion is the version of the hashing algorithm used for vendor digests.
+	HashVersion = verify.HashVersion
+)
@@ -0,0 +1,220 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/palantir/godel-dep-plugin/generated_src/internal/github.com/golang/dep/gps"
+	"github.com/palantir/godel-dep-plugin/generated_src/internal/github.com/golang/dep/gps/verify"
+	"github.com/pkg/errors"
+)
+
+// CheckResult holds the result of checking whether a project's Gopkg.lock is
+// in sync with its imports and Gopkg.toml, and whether its vendor directory is
+// in sync with Gopkg.lock.
+type CheckResult struct {
+	// LockChecked is true if Gopkg.lock was checked against the inputs.
+	LockChecked	bool
+	// LockSatisfaction reports the imports and constraints that are not
+	// satisfied by Gopkg.lock.
+	LockSatisfaction	verify.LockSatisfaction
+	// LockDelta is the difference between Gopkg.lock and the lock implied by
+	// the current inputs that can be known without solving (prune options and
+	// hash versions).
+	LockDelta	verify.LockDelta
+
+	// VendorChecked is true if vendor was checked against Gopkg.lock.
+	VendorChecked	bool
+	// VendorStatus is the status of each project root in vendor or Gopkg.lock.
+	VendorStatus	map[string]verify.VendorStatus
+	// NoVerify is the set of project roots for which digest mismatches are
+	// ignored.
+	NoVerify	map[string]bool
+}
+
+// LockInSync returns true if Gopkg.lock was not checked, or if it was checked
+// and is in sync with the inputs.
+func (r CheckResult) LockInSync() bool {
+	if !r.LockChecked {
+		return true
+	}
+	return r.LockSatisfaction.Satisfied() && !r.LockDelta.Changed(verify.PruneOptsChanged|verify.HashVersionChanged)
+}
+
+// VendorInSync returns true if vendor was not checked, or if it was checked
+// and is in sync with Gopkg.lock.
+func (r CheckResult) VendorInSync() bool {
+	for pr := range r.VendorStatus {
+		if r.VendorFailed(pr) {
+			return false
+		}
+	}
+	return true
+}
+
+// InSync returns true if all of the checked properties are in sync.
+func (r CheckResult) InSync() bool {
+	return r.LockInSync() && r.VendorInSync()
+}
+
+// VendorFailed returns true if the vendor status of the named project root
+// indicates that vendor is out of sync. Digest mismatches for project roots
+// marked as noverify are not failures.
+func (r CheckResult) VendorFailed(pr string) bool {
+	switch r.VendorStatus[pr] {
+	case verify.DigestMismatchInLock, verify.HashVersionMismatch, verify.EmptyDigestInLock:
+		// NoVerify applies only to these three cases.
+		return !r.NoVerify[pr]
+	case verify.NotInTree, verify.NotInLock:
+		return true
+	}
+	return false
+}
+
+// Check determines whether the project's Gopkg.lock is in sync with its
+
+// directory is in sync with Gopkg.lock (unless skipVendor is true). The
+// provided noverify project roots are treated as if they were in the manifest's
+// noverify list.
+//
+// An error is returned only if the checks could not be performed; a project
+// that is out of sync is reported through the returned CheckResult.
+func (p *Project) Check(skipLock, skipVendor bool, noverify []string) (CheckResult, error) {
+	var result CheckResult
+	if !skipLock {
+		if p.Lock == nil {
+			return CheckResult{}, errors.New("Gopkg.lock does not exist, cannot check it against imports and Gopkg.toml")
+		}
+		result.LockChecked = true
+		result.LockSatisfaction = verify.LockSatisfiesInputs(p.Lock, p.Manifest, p.RootPackageTree)
+		result.LockDelta = verify.DiffLocks(p.Lock, p.ChangedLock)
+	}
+
+	if !skipVendor {
+		if p.Lock == nil {
+			return CheckResult{}, errors.New("Gopkg.lock does not exist, cannot check vendor against it")
+		}
+
+		statuses, err := p.VerifyVendor()
+		if err != nil {
+			return CheckResult{}, errors.Wrap(err, "error while verifying vendor")
+		}
+		result.VendorChecked = true
+		result.VendorStatus = statuses
+
+		result.NoVerify = make(map[string]bool)
+		for _, skip := range p.Manifest.NoVerify {
+			result.NoVerify[skip] = true
+		}
+		for _, skip := range noverify {
+			result.NoVerify[skip] = true
+		}
+	}
+	return result, nil
+}
+
+// DigestMigration is the result of migrating the digest of a locked project to
+// the current hash version.
+type DigestMigration struct {
+	ProjectRoot	gps.ProjectRoot
+	// FromVersion is the hash version of the digest in Gopkg.lock.
+	FromVersion	int
+	// Migrated is true if the digest was replaced with one of the current hash
+	// version. Digests are only migrated if the vendored tree of the project
+	// matches them and their hash version is supported.
+	Migrated	bool
+}
+
+// MigrateDigests replaces the digests in Gopkg.lock that use an older hash
+// version with digests of the current hash version, without solving. Each
+// vendored project is first checked against its existing digest, so that
+// migrating never makes a modified vendored tree appear to be in sync.
+//
+// The returned migrations are in the order of the projects in Gopkg.lock and
+// include every project whose digest is of another hash version. Gopkg.lock is
+// only written if at least one digest was migrated, after which the project
+// must be loaded again to observe the new lock.
+func (p *Project) MigrateDigests() ([]DigestMigration, error) {
+	if p.Lock == nil {
+		return nil, errors.New("Gopkg.lock does not exist, there are no digests to migrate")
+	}
+
+	l := p.Lock.dup()
+	var migrations []DigestMigration
+	var changed bool
+	for k, lp := range l.P {
+		vp := lp.(verify.VerifiableProject)
+		if vp.Digest.HashVersion == verify.HashVersion || vp.Digest.IsEmpty() {
+			continue
+		}
+
+		m := DigestMigration{
+			ProjectRoot:	lp.Ident().ProjectRoot,
+			FromVersion:	vp.Digest.HashVersion,
+		}
+		dir := filepath.Join(p.AbsRoot, "vendor", string(m.ProjectRoot))
+		if _, err := os.Stat(dir); err != nil || !verify.IsSupportedHashVersion(m.FromVersion) {
+			migrations = append(migrations, m)
+			continue
+		}
+
+		old, err := verify.DigestFromDirectoryVersion(dir, m.FromVersion)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error while hashing tree of %s in vendor", m.ProjectRoot)
+		}
+		if old.String() == vp.Digest.String() {
+			if vp.Digest, err = verify.DigestFromDirectory(dir); err != nil {
+				return nil, errors.Wrapf(err, "error while hashing tree of %s in vendor", m.ProjectRoot)
+			}
+			l.P[k] = vp
+			m.Migrated, changed = true, true
+		}
+		migrations = append(migrations, m)
+	}
+
+	if changed {
+		if err := writeLockFile(p.AbsRoot, l); err != nil {
+			return nil, err
+		}
+	}
+	return migrations, nil
+}
+
+// writeLockFile atomically replaces the Gopkg.lock of the project at root
+// with l.
+func writeLockFile(root string, l *Lock) error {
+	b, err := l.MarshalTOML()
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal lock to TOML")
+	}
+
+	lpath := filepath.Join(root, LockName)
+	fi, err := os.Stat(lpath)
+	if err != nil {
+		return errors.Wrap(err, "failed to write lock file")
+	}
+	tmp, err := ioutil.TempFile(root, "."+LockName)
+	if err != nil {
+		return errors.Wrap(err, "failed to write lock file")
+	}
+	_, err = tmp.Write(append(lockFileComment, b...))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), fi.Mode().Perm())
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), lpath)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return errors.Wrap(err, "failed to write lock file")
+	}
+	return nil
+}
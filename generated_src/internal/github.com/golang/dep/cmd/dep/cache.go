@@ -0,0 +1,619 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package amalgomated
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/palantir/godel-dep-plugin/generated_src/internal/github.com/golang/dep/amalgomated_flag"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/palantir/godel-dep-plugin/generated_src/internal/github.com/golang/dep"
+	"github.com/palantir/godel-dep-plugin/generated_src/internal/github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+const cacheShortHelp = `Inspect and manage the source cache`
+const cacheLongHelp = `
+Inspect and manage the cache directory of dep ($DEPCACHEDIR, or
+$GOPATH/pkg/dep by default), which holds local copies of the sources of
+dependencies under sources/ and, if DEPCACHEAGE is set, a persistent cache of
+their metadata in bolt-v1.db.
+
+Subcommands:
+
+  ls [-json]
+	List the cached sources, with their VCS, size and last fetch time.
+
+  rm [-network] <source>...
+	Remove cached sources and their metadata. A source is the name of its
+	directory under sources/, its URL, or a project root or source as it
+	appears in Gopkg.lock, in which case every copy of it is removed.
+
+  gc [-older-than <days>] [-dry-run] [-network] [<lock>...]
+	Remove the cached sources that are not referenced by any of the given
+	locks, or that were last fetched more than the given number of days
+	ago, along with their metadata. Each lock is a path to a lock file or a
+	git revision from which Gopkg.lock is read, as with dep diff.
+
+  verify
+	Check the integrity of every cached source with its VCS, for example
+	with "git fsck", and the consistency of the metadata cache.
+
+  stats [-json]
+	Summarize the size and age of the cache.
+
+  export [-o <bundle>] [<lock>]
+	Write a bundle, a gzipped tarball, of the cached sources and metadata
+	that are needed to solve and vendor a lock without network access. The
+	sources are updated first if they do not contain the locked revisions.
+	The lock defaults to the Gopkg.lock of the current project and may be a
+	path or a git revision as with gc. The bundle is written to
+	dep-cache.tar.gz by default, or to stdout if <bundle> is "-".
+
+  import [-keep-timestamps] <bundle>
+	Load a bundle written by export into the cache directory, replacing the
+	cached sources and metadata it contains. Afterwards, "dep ensure
+	-vendor-only" needs no network access. Solving does not either if
+	DEPCACHEAGE is set, because the version lists of the bundle are marked
+	as fetched at the time of the import; use -keep-timestamps to keep the
+	time of the export instead, so that they expire as usual. The bundle is
+	read from stdin if <bundle> is "-".
+
+The copies of a project root or source are found without network access if
+it is on a well-known host, such as github.com, or has a VCS extension. Others,
+such as vanity import paths, need their go-get metadata to be read from the
+network, which rm and gc only do with -network.
+
+Sources are cloned again the next time they are needed, so removing them is
+always safe, if slow.
+`
+
+type cacheCommand struct{}
+
+func (cmd *cacheCommand) Name() string	{ return "cache" }
+func (cmd *cacheCommand) Args() string {
+	return "ls|rm|gc|verify|stats|export|import [flags] [args]"
+}
+func (cmd *cacheCommand) ShortHelp() string		{ return cacheShortHelp }
+func (cmd *cacheCommand) LongHelp() string		{ return cacheLongHelp }
+func (cmd *cacheCommand) Hidden() bool			{ return false }
+func (cmd *cacheCommand) Register(fs *flag.FlagSet)	{}
+
+func (cmd *cacheCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) == 0 {
+		return errors.New("a subcommand is required: ls, rm, gc, verify, stats, export or import")
+	}
+
+	var run func(*dep.Ctx, *gps.SourceMgr, []string) error
+	fs := flag.NewFlagSet("cache "+args[0], flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	switch args[0] {
+	case "ls":
+		c := &cacheLsCommand{}
+		fs.BoolVar(&c.json, "json", false, "output in JSON format")
+		run = c.run
+	case "rm":
+		c := &cacheRmCommand{}
+		fs.BoolVar(&c.network, "network", false, "read the go-get metadata of import paths that are not on a well-known host")
+		run = c.run
+	case "gc":
+		c := &cacheGCCommand{}
+		fs.IntVar(&c.olderThan, "older-than", 0, "remove sources last fetched more than this many days ago")
+		fs.BoolVar(&c.dryRun, "dry-run", false, "only report what would be removed")
+		fs.BoolVar(&c.network, "network", false, "read the go-get metadata of import paths that are not on a well-known host")
+		run = c.run
+	case "verify":
+		run = runCacheVerify
+	case "stats":
+		c := &cacheStatsCommand{}
+		fs.BoolVar(&c.json, "json", false, "output in JSON format")
+		run = c.run
+	case "export":
+		c := &cacheExportCommand{}
+		fs.StringVar(&c.output, "o", "dep-cache.tar.gz", "path of the bundle, or - for stdout")
+		run = c.run
+	case "import":
+		c := &cacheImportCommand{}
+		fs.BoolVar(&c.keepTimestamps, "keep-timestamps", false, "keep the fetch times of the version lists of the bundle")
+		run = c.run
+	default:
+		return errors.Errorf("unknown cache subcommand %q: must be one of ls, rm, gc, verify, stats, export or import", args[0])
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return errors.Wrapf(err, "cache %s", args[0])
+	}
+
+	sm, err := ctx.SourceManager()
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	return run(ctx, sm, fs.Args())
+}
+
+// cachedSourceJSON is the JSON form of a gps.CachedSource.
+type cachedSourceJSON struct {
+	Name		string
+	Path		string
+	Type		string	`json:",omitempty"`
+	URL		string	`json:",omitempty"`
+	Size		int64
+	LastFetch	time.Time
+}
+
+type cacheLsCommand struct {
+	json bool
+}
+
+func (c *cacheLsCommand) run(ctx *dep.Ctx, sm *gps.SourceMgr, args []string) error {
+	if len(args) > 0 {
+		return errors.Errorf("too many args (%d)", len(args))
+	}
+	css, err := sm.CachedSources()
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if c.json {
+		out := []cachedSourceJSON{}
+		for _, cs := range css {
+			out = append(out, cachedSourceJSON(cs))
+		}
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(out); err != nil {
+			return err
+		}
+	} else {
+		w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "SOURCE\tVCS\tSIZE\tLAST FETCH")
+		for _, cs := range css {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", cachedSourceLabel(cs), orUnknown(cs.Type), formatSize(cs.Size), formatFetchTime(cs.LastFetch))
+		}
+		w.Flush()
+	}
+	ctx.Out.Print(buf.String())
+	return nil
+}
+
+type cacheRmCommand struct {
+	network bool
+}
+
+func (c *cacheRmCommand) run(ctx *dep.Ctx, sm *gps.SourceMgr, args []string) error {
+	if len(args) == 0 {
+		return errors.New("must provide at least one source to remove")
+	}
+	css, err := sm.CachedSources()
+	if err != nil {
+		return err
+	}
+
+	for _, arg := range args {
+		var matched []gps.CachedSource
+		for _, cs := range css {
+			if cs.Name == arg || (cs.URL != "" && cs.URL == arg) {
+				matched = append(matched, cs)
+			}
+		}
+		// Metadata is keyed by project root or source, so the argument may
+		// name some even if it does not name a cached source.
+		metadata := []string{arg}
+		var unknownErr error
+		if len(matched) == 0 {
+			matched, err = sm.CachedSourcesFor(gps.ProjectIdentifier{ProjectRoot: gps.ProjectRoot(arg)}, c.network)
+			if _, ok := err.(*gps.UnknownSourcesError); ok {
+				unknownErr = err
+			} else if err != nil {
+				return errors.Wrapf(err, "%s is not a cached source", arg)
+			}
+		}
+		for _, cs := range matched {
+			metadata = append(metadata, metadataNames(cs)...)
+		}
+
+		info, err := sm.MetadataCache()
+		if err != nil {
+			return err
+		}
+		metadata = intersectSorted(metadata, info.Sources)
+		if len(matched) == 0 && len(metadata) == 0 {
+			if unknownErr != nil {
+				return errors.Errorf("%s is not a cached source, or %s; use -network to allow it", arg, unknownErr)
+			}
+			return errors.Errorf("%s is not a cached source", arg)
+		}
+
+		for _, cs := range matched {
+			if err := sm.RemoveCachedSource(cs); err != nil {
+				return err
+			}
+			ctx.Err.Printf("Removed %s\n", cs.Path)
+		}
+		if err := sm.RemoveMetadata(metadata); err != nil {
+			return err
+		}
+		for _, name := range metadata {
+			ctx.Err.Printf("Removed the cached metadata of %s\n", name)
+		}
+	}
+	return nil
+}
+
+type cacheGCCommand struct {
+	olderThan	int
+	dryRun		bool
+	network		bool
+}
+
+func (c *cacheGCCommand) run(ctx *dep.Ctx, sm *gps.SourceMgr, args []string) error {
+	if c.olderThan < 0 {
+		return errors.New("-older-than must not be negative")
+	}
+	if c.olderThan == 0 && len(args) == 0 {
+		return errors.New("must provide -older-than, at least one lock, or both")
+	}
+
+	css, err := sm.CachedSources()
+	if err != nil {
+		return err
+	}
+	info, err := sm.MetadataCache()
+	if err != nil {
+		return err
+	}
+
+	// Collect the sources and metadata that are referenced by the locks.
+	keep := make(map[string]bool)
+	keepMetadata := make(map[string]bool)
+	project := projectLoader(ctx)
+	for _, arg := range args {
+		l, _, err := loadLockArg(ctx, project, arg)
+		if err != nil {
+			return err
+		}
+		for _, lp := range l.Projects() {
+			id := lp.Ident()
+			if id.Source != "" {
+				keepMetadata[id.Source] = true
+			} else {
+				keepMetadata[string(id.ProjectRoot)] = true
+			}
+			refd, err := sm.CachedSourcesFor(id, c.network)
+			if _, ok := err.(*gps.UnknownSourcesError); ok {
+				return errors.Errorf("%s; use -network to allow it", err)
+			} else if err != nil {
+				return errors.Wrapf(err, "failed to determine the sources of %s", id)
+			}
+			for _, cs := range refd {
+				keep[cs.Name] = true
+			}
+		}
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -c.olderThan)
+	var removed []gps.CachedSource
+	var metadata []string
+	for _, cs := range css {
+		unreferenced := len(args) > 0 && !keep[cs.Name]
+		old := c.olderThan > 0 && cs.LastFetch.Before(cutoff)
+		if unreferenced || old {
+			removed = append(removed, cs)
+			metadata = append(metadata, metadataNames(cs)...)
+		}
+	}
+	if len(args) > 0 {
+		for _, name := range info.Sources {
+			if !keepMetadata[name] {
+				metadata = append(metadata, name)
+			}
+		}
+	}
+	metadata = intersectSorted(metadata, info.Sources)
+
+	verb := "Removed"
+	if c.dryRun {
+		verb = "Would remove"
+	}
+	var freed int64
+	for _, cs := range removed {
+		if !c.dryRun {
+			if err := sm.RemoveCachedSource(cs); err != nil {
+				return err
+			}
+		}
+		freed += cs.Size
+		ctx.Err.Printf("%s %s (%s)\n", verb, cachedSourceLabel(cs), formatSize(cs.Size))
+	}
+	if !c.dryRun {
+		if err := sm.RemoveMetadata(metadata); err != nil {
+			return err
+		}
+	}
+	for _, name := range metadata {
+		ctx.Err.Printf("%s the cached metadata of %s\n", verb, name)
+	}
+
+	ctx.Out.Printf("%s %d of %d cached sources, freeing %s.\n", verb, len(removed), len(css), formatSize(freed))
+	return nil
+}
+
+func runCacheVerify(ctx *dep.Ctx, sm *gps.SourceMgr, args []string) error {
+	if len(args) > 0 {
+		return errors.Errorf("too many args (%d)", len(args))
+	}
+	css, err := sm.CachedSources()
+	if err != nil {
+		return err
+	}
+
+	var failed int
+	for _, cs := range css {
+		if err := sm.VerifyCachedSource(context.TODO(), cs); err != nil {
+			failed++
+			ctx.Err.Printf("%s: %s\n", cachedSourceLabel(cs), err)
+		} else if ctx.Verbose {
+			ctx.Err.Printf("%s: ok\n", cachedSourceLabel(cs))
+		}
+	}
+	if err := sm.VerifyMetadataCache(); err != nil {
+		failed++
+		ctx.Err.Println(err)
+	}
+
+	if failed > 0 {
+		return errors.Errorf("%d problems found in the cache; remove the affected sources with \"dep cache rm\"", failed)
+	}
+	ctx.Out.Printf("Verified %d cached sources and the metadata cache.\n", len(css))
+	return nil
+}
+
+// cacheStats summarizes the cache directory.
+type cacheStats struct {
+	Cachedir	string
+	Sources		int
+	Size		int64
+	// ByType maps each VCS to the number of sources that use it.
+	ByType	map[string]int
+	// OldestFetch and NewestFetch are the oldest and newest last fetch times
+	// of the sources.
+	OldestFetch	*time.Time	`json:",omitempty"`
+	NewestFetch	*time.Time	`json:",omitempty"`
+	// MetadataPath is blank if there is no persistent metadata cache.
+	MetadataPath	string	`json:",omitempty"`
+	MetadataSize	int64
+	MetadataSources	int
+}
+
+type cacheStatsCommand struct {
+	json bool
+}
+
+func (c *cacheStatsCommand) run(ctx *dep.Ctx, sm *gps.SourceMgr, args []string) error {
+	if len(args) > 0 {
+		return errors.Errorf("too many args (%d)", len(args))
+	}
+	css, err := sm.CachedSources()
+	if err != nil {
+		return err
+	}
+	info, err := sm.MetadataCache()
+	if err != nil {
+		return err
+	}
+
+	stats := cacheStats{
+		Cachedir:		sm.Cachedir(),
+		Sources:		len(css),
+		ByType:			make(map[string]int),
+		MetadataPath:		info.Path,
+		MetadataSize:		info.Size,
+		MetadataSources:	len(info.Sources),
+	}
+	for i, cs := range css {
+		stats.Size += cs.Size
+		stats.ByType[orUnknown(cs.Type)]++
+		if stats.OldestFetch == nil || cs.LastFetch.Before(*stats.OldestFetch) {
+			stats.OldestFetch = &css[i].LastFetch
+		}
+		if stats.NewestFetch == nil || cs.LastFetch.After(*stats.NewestFetch) {
+			stats.NewestFetch = &css[i].LastFetch
+		}
+	}
+
+	var buf bytes.Buffer
+	if c.json {
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(stats); err != nil {
+			return err
+		}
+		ctx.Out.Print(buf.String())
+		return nil
+	}
+
+	w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Cache directory:\t%s\n", stats.Cachedir)
+	fmt.Fprintf(w, "Sources:\t%d (%s)\n", stats.Sources, formatSize(stats.Size))
+	var types []string
+	for typ := range stats.ByType {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	for _, typ := range types {
+		fmt.Fprintf(w, "  %s:\t%d\n", typ, stats.ByType[typ])
+	}
+	if stats.OldestFetch != nil {
+		fmt.Fprintf(w, "Oldest fetch:\t%s\n", formatFetchTime(*stats.OldestFetch))
+		fmt.Fprintf(w, "Newest fetch:\t%s\n", formatFetchTime(*stats.NewestFetch))
+	}
+	if stats.MetadataPath != "" {
+		fmt.Fprintf(w, "Metadata cache:\t%s (%s, %d sources)\n", stats.MetadataPath, formatSize(stats.MetadataSize), stats.MetadataSources)
+	} else {
+		fmt.Fprintf(w, "Metadata cache:\tnone\n")
+	}
+	w.Flush()
+	ctx.Out.Print(buf.String())
+	return nil
+}
+
+type cacheExportCommand struct {
+	output string
+}
+
+func (c *cacheExportCommand) run(ctx *dep.Ctx, sm *gps.SourceMgr, args []string) error {
+	if len(args) > 1 {
+		return errors.Errorf("too many args (%d)", len(args))
+	}
+
+	l, err := loadLockArgs(ctx, args)
+	if err != nil {
+		return err
+	}
+
+	w, output := ctx.Stdout, c.output
+	if output != "-" {
+		if !filepath.IsAbs(output) {
+			output = filepath.Join(ctx.WorkingDir, output)
+		}
+		f, err := os.Create(output)
+		if err != nil {
+			return errors.Wrap(err, "failed to create bundle")
+		}
+		defer f.Close()
+		w = f
+	} else if w == nil {
+		return errors.New("no standard output to write the bundle to")
+	}
+	info, err := sm.ExportCacheBundle(w, l.Projects(), dep.Analyzer{})
+	if err != nil {
+		if output != "-" {
+			os.Remove(output)
+		}
+		return errors.Wrap(err, "failed to export cache bundle")
+	}
+
+	for _, bp := range info.Projects {
+		ctx.Err.Printf("Exported %s@%s\n", bp.ProjectRoot, shortRevision(string(bp.Revision)))
+	}
+	if c.output != "-" {
+		ctx.Err.Printf("Wrote %d projects to %s\n", len(info.Projects), c.output)
+	}
+	return nil
+}
+
+type cacheImportCommand struct {
+	keepTimestamps bool
+}
+
+func (c *cacheImportCommand) run(ctx *dep.Ctx, sm *gps.SourceMgr, args []string) error {
+	if len(args) != 1 {
+		return errors.New("must provide exactly one bundle to import")
+	}
+
+	r := ctx.Stdin
+	if path := args[0]; path != "-" {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(ctx.WorkingDir, path)
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return errors.Wrap(err, "failed to open bundle")
+		}
+		defer f.Close()
+		r = f
+	} else if r == nil {
+		return errors.New("no standard input to read the bundle from")
+	}
+	info, err := sm.ImportCacheBundle(r, c.keepTimestamps)
+	if err != nil {
+		return errors.Wrapf(err, "failed to import cache bundle %s", args[0])
+	}
+
+	for _, bp := range info.Projects {
+		ctx.Err.Printf("Imported %s@%s\n", bp.ProjectRoot, shortRevision(string(bp.Revision)))
+	}
+	ctx.Out.Printf("Imported %d projects exported on %s into %s.\n", len(info.Projects), info.Created.Format("2006-01-02 15:04"), sm.Cachedir())
+	return nil
+}
+
+// metadataNames returns the names under which the metadata of a cached source
+// may be stored: its URL and its URL without scheme, user and ".git" suffix,
+// which is how project roots and sources usually appear in locks.
+func metadataNames(cs gps.CachedSource) []string {
+	if cs.URL == "" {
+		return nil
+	}
+	names := []string{cs.URL}
+	if u, err := url.Parse(cs.URL); err == nil && u.Host != "" {
+		names = append(names, u.Host+strings.TrimSuffix(u.Path, ".git"))
+	}
+	return names
+}
+
+// intersectSorted returns the sorted, deduplicated elements of a that are also
+// in b.
+func intersectSorted(a, b []string) []string {
+	inB := make(map[string]bool, len(b))
+	for _, s := range b {
+		inB[s] = true
+	}
+	var out []string
+	for _, s := range a {
+		if inB[s] {
+			out = append(out, s)
+			delete(inB, s)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func cachedSourceLabel(cs gps.CachedSource) string {
+	if cs.URL != "" {
+		return cs.URL
+	}
+	return cs.Name
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
+
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func formatFetchTime(t time.Time) string {
+	if t.IsZero() {
+		return "unknown"
+	}
+	return t.Format("2006-01-02 15:04")
+}
@@ -0,0 +1,312 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package amalgomated
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/palantir/godel-dep-plugin/generated_src/internal/github.com/golang/dep/amalgomated_flag"
+	"fmt"
+	"strings"
+
+	"github.com/palantir/godel-dep-plugin/generated_src/internal/github.com/golang/dep"
+	"github.com/palantir/godel-dep-plugin/generated_src/internal/github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+const changelogShortHelp = `List the upstream commits between two versions of Gopkg.lock`
+const changelogLongHelp = `
+Compare two versions of Gopkg.lock, as dep diff does, and list the subjects of
+the upstream commits that changed between the old and the new revision of each
+project whose revision changed. The commits are read from the repositories in
+the local source cache, which are updated if they do not contain a revision.
+
+If the old revision is not an ancestor of the new one, the change is not a
+fast-forward: the commits that are only reachable from the old revision are
+listed as dropped, prefixed with "-", in addition to the commits that were
+added, prefixed with "+".
+
+If the source of a project changed, the commits are read from the new source.
+If they cannot be listed from it, for example because it does not contain the
+old revision, they are read from the old source instead, which is noted in the
+output. The project is reported with an error only if neither source can list
+the commits.
+
+The arguments are the same as those of dep diff. -format json prints the
+changelog as a JSON document, and -format markdown prints it in a form
+suitable for pull request comments.
+`
+
+type changelogCommand struct {
+	format string
+}
+
+func (cmd *changelogCommand) Name() string	{ return "changelog" }
+func (cmd *changelogCommand) Args() string {
+	return "[-format text|json|markdown] [old-lock] [new-lock]"
+}
+func (cmd *changelogCommand) ShortHelp() string	{ return changelogShortHelp }
+func (cmd *changelogCommand) LongHelp() string	{ return changelogLongHelp }
+func (cmd *changelogCommand) Hidden() bool	{ return false }
+
+func (cmd *changelogCommand) Register(fs *flag.FlagSet) {
+	fs.StringVar(&cmd.format, "format", diffFormatText, "output format: text, json or markdown")
+}
+
+// changelogProject lists the commits between the old and the new revision of
+// a project.
+type changelogProject struct {
+	ProjectRoot	string
+	// Source is set if the source of the project changed.
+	Source	*lockDiffValue	`json:",omitempty"`
+	// Version is set if the version of the project changed.
+	Version		*lockDiffValue	`json:",omitempty"`
+	Revision	lockDiffValue
+	// FastForward is true if the old revision is an ancestor of the new one.
+	FastForward	bool
+	// Commits are the commits that are reachable from the new revision but
+	// not from the old one, newest first.
+	Commits	[]changelogCommit
+	// Dropped are the commits that are reachable from the old revision but not
+	// from the new one, newest first. It is empty for fast-forward changes.
+	Dropped	[]changelogCommit
+	// Note is set if the commits were listed from the old source because the
+	// new source could not list them.
+	Note	string	`json:",omitempty"`
+	// Error is set if the commits could not be listed.
+	Error	string	`json:",omitempty"`
+}
+
+type changelogCommit struct {
+	Revision	string
+	Subject		string
+}
+
+func (cmd *changelogCommand) Run(ctx *dep.Ctx, args []string) error {
+	switch cmd.format {
+	case diffFormatText, diffFormatJSON, diffFormatMarkdown:
+	default:
+		return errors.Errorf("invalid format %q: must be one of %s, %s or %s", cmd.format, diffFormatText, diffFormatJSON, diffFormatMarkdown)
+	}
+
+	ll, err := loadLockPair(ctx, args)
+	if err != nil {
+		return err
+	}
+	diff := newLockDiff(ll)
+
+	sm, err := ctx.SourceManager()
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	projects := []changelogProject{}
+	var failed bool
+	for _, ldc := range diff.Changed {
+		if ldc.Revision == nil && ldc.Source == nil {
+			continue
+		}
+		cp := newChangelogProject(sm, ll.new, ldc)
+		if cp.Error != "" {
+			failed = true
+		}
+		projects = append(projects, cp)
+	}
+
+	var buf bytes.Buffer
+	switch cmd.format {
+	case diffFormatJSON:
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(projects); err != nil {
+			return err
+		}
+	case diffFormatMarkdown:
+		writeChangelogMarkdown(&buf, projects)
+	default:
+		writeChangelogText(&buf, projects)
+	}
+	ctx.Out.Print(buf.String())
+
+	if failed {
+		return errors.New("failed to list the commits of some projects")
+	}
+	return nil
+}
+
+func newChangelogProject(sm *gps.SourceMgr, newLock *dep.Lock, ldc lockDiffChange) changelogProject {
+	lp := findLockedProject(newLock, gps.ProjectRoot(ldc.ProjectRoot))
+	rev, _, _ := gps.VersionComponentStrings(lp.Version())
+	cp := changelogProject{
+		ProjectRoot:	ldc.ProjectRoot,
+		Source:		ldc.Source,
+		Version:	ldc.Version,
+		Revision:	lockDiffValue{Before: rev, After: rev},
+		FastForward:	true,
+		Commits:	[]changelogCommit{},
+		Dropped:	[]changelogCommit{},
+	}
+	if ldc.Revision != nil {
+		cp.Revision = *ldc.Revision
+	}
+	if cp.Revision.Before == cp.Revision.After {
+		// Only the source changed.
+		return cp
+	}
+
+	// The commits are read from the new source, which has to contain both
+	// revisions. The old source, which is usually in the cache, is the
+	// fallback if the source changed.
+	id := lp.Ident()
+	from, to := gps.Revision(cp.Revision.Before), gps.Revision(cp.Revision.After)
+	added, dropped, err := listChangelogCommits(sm, id, from, to)
+	if err != nil && cp.Source != nil {
+		if _, ok := errors.Cause(err).(*gps.UnsupportedCommitsError); !ok {
+			oldID := gps.ProjectIdentifier{ProjectRoot: id.ProjectRoot, Source: cp.Source.Before}
+			var oldErr error
+			added, dropped, oldErr = listChangelogCommits(sm, oldID, from, to)
+			if oldErr == nil {
+				cp.Note = fmt.Sprintf("the source changed from %s to %s, and the commits were listed from the old source because the new source could not list them",
+					orNoneValue(cp.Source.Before), orNoneValue(cp.Source.After))
+				err = nil
+			} else {
+				err = errors.Errorf("the source changed from %s to %s, and the commits could not be listed from either source: %s; %s",
+					orNoneValue(cp.Source.Before), orNoneValue(cp.Source.After), err, oldErr)
+			}
+		}
+	}
+	if err != nil {
+		cp.Error = err.Error()
+		if _, ok := errors.Cause(err).(*gps.UnsupportedCommitsError); ok {
+			cp.Error = fmt.Sprintf("cannot list the commits between %s and %s: %s", shortRevision(cp.Revision.Before), shortRevision(cp.Revision.After), err)
+		}
+		cp.FastForward = false
+		return cp
+	}
+	cp.Commits = newChangelogCommits(added)
+	cp.Dropped = newChangelogCommits(dropped)
+	cp.FastForward = len(cp.Dropped) == 0
+	return cp
+}
+
+// listChangelogCommits lists the commits that were added and dropped by
+// changing the revision of the project with the provided identifier from from
+// to to.
+func listChangelogCommits(sm *gps.SourceMgr, id gps.ProjectIdentifier, from, to gps.Revision) (added, dropped []gps.Commit, err error) {
+	added, err = sm.ListCommits(id, from, to)
+	if err != nil {
+		return nil, nil, err
+	}
+	dropped, err = sm.ListCommits(id, to, from)
+	if err != nil {
+		return nil, nil, err
+	}
+	return added, dropped, nil
+}
+
+func newChangelogCommits(commits []gps.Commit) []changelogCommit {
+	ccs := make([]changelogCommit, 0, len(commits))
+	for _, c := range commits {
+		ccs = append(ccs, changelogCommit{Revision: string(c.Revision), Subject: c.Subject})
+	}
+	return ccs
+}
+
+// summary describes the change to the project on a single line.
+func (cp changelogProject) summary() string {
+	var buf bytes.Buffer
+	if cp.Version != nil {
+		fmt.Fprintf(&buf, "%s (%s) -> %s (%s)", orNoneValue(cp.Version.Before), shortRevision(cp.Revision.Before),
+			orNoneValue(cp.Version.After), shortRevision(cp.Revision.After))
+	} else {
+		fmt.Fprintf(&buf, "%s -> %s", shortRevision(cp.Revision.Before), shortRevision(cp.Revision.After))
+	}
+	if cp.Source != nil {
+		fmt.Fprintf(&buf, ", source %s -> %s", orNoneValue(cp.Source.Before), orNoneValue(cp.Source.After))
+	}
+	switch {
+	case cp.Error != "":
+	case cp.Revision.Before == cp.Revision.After:
+		buf.WriteString(", same revision")
+	case cp.FastForward:
+		fmt.Fprintf(&buf, ", %s", pluralCommits(len(cp.Commits)))
+	default:
+		fmt.Fprintf(&buf, ", not a fast-forward: %s added, %s dropped", pluralCommits(len(cp.Commits)), pluralCommits(len(cp.Dropped)))
+	}
+	return buf.String()
+}
+
+func pluralCommits(n int) string {
+	if n == 1 {
+		return "1 commit"
+	}
+	return fmt.Sprintf("%d commits", n)
+}
+
+func writeChangelogText(buf *bytes.Buffer, projects []changelogProject) {
+	if len(projects) == 0 {
+		buf.WriteString("No revisions changed.\n")
+		return
+	}
+	for i, cp := range projects {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		fmt.Fprintf(buf, "%s %s\n", cp.ProjectRoot, cp.summary())
+		if cp.Note != "" {
+			fmt.Fprintf(buf, "  note: %s\n", cp.Note)
+		}
+		if cp.Error != "" {
+			fmt.Fprintf(buf, "  error: %s\n", cp.Error)
+		}
+		for _, c := range cp.Commits {
+			fmt.Fprintf(buf, "  + %s %s\n", shortRevision(c.Revision), c.Subject)
+		}
+		for _, c := range cp.Dropped {
+			fmt.Fprintf(buf, "  - %s %s\n", shortRevision(c.Revision), c.Subject)
+		}
+	}
+}
+
+func writeChangelogMarkdown(buf *bytes.Buffer, projects []changelogProject) {
+	if len(projects) == 0 {
+		buf.WriteString("No revisions changed.\n")
+		return
+	}
+	for i, cp := range projects {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		fmt.Fprintf(buf, "#### `%s`\n\n%s\n", cp.ProjectRoot, escapeMarkdown(cp.summary()))
+		if cp.Note != "" {
+			fmt.Fprintf(buf, "\n> **Note:** %s\n", escapeMarkdown(cp.Note))
+		}
+		if cp.Error != "" {
+			fmt.Fprintf(buf, "\n> **Error:** %s\n", escapeMarkdown(cp.Error))
+		}
+		if len(cp.Commits) > 0 || len(cp.Dropped) > 0 {
+			buf.WriteString("\n")
+		}
+		for _, c := range cp.Commits {
+			fmt.Fprintf(buf, "- `%s` %s\n", shortRevision(c.Revision), escapeMarkdown(c.Subject))
+		}
+		for _, c := range cp.Dropped {
+			fmt.Fprintf(buf, "- ~~`%s` %s~~ (dropped)\n", shortRevision(c.Revision), escapeMarkdown(c.Subject))
+		}
+	}
+}
+
+// markdownEscaper escapes the characters that start inline Markdown or HTML,
+// so that commit subjects and errors are shown as they were written.
+var markdownEscaper = strings.NewReplacer(
+	"\\", "\\\\", "`", "\\`", "*", "\\*", "_", "\\_", "~", "\\~",
+	"[", "\\[", "]", "\\]", "<", "\\<", ">", "\\>", "|", "\\|", "&", "\\&",
+)
+
+func escapeMarkdown(s string) string {
+	return markdownEscaper.Replace(s)
+}
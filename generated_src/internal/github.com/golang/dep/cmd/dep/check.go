@@ -36,17 +36,28 @@ more information on what it means to be "in sync.")
 
 If your workflow necessitates that you modify the contents of vendor, you can
 force check to ignore hash mismatches on a per-project basis by naming
-project roots in Gopkg.toml's "noverify" list.
+project roots in Gopkg.toml's "noverify" list. Additional project roots can be
+ignored for a single run by providing them as a comma-separated list to
+-noverify.
+
+Digests in Gopkg.lock that use an older, still supported hash version are
+checked with that version, and are only replaced when their project is written
+to vendor again. Passing -migrate-hash rewrites them with the current hash
+version, without solving or writing vendor, before the checks are run. A digest
+is only migrated if the vendored tree of its project matches it, so that a
+modified tree is never made to appear to be in sync.
 `
 
 type checkCommand struct {
 	quiet			bool
 	skiplock, skipvendor	bool
+	noverify		string
+	migrateHash		bool
 }
 
 func (cmd *checkCommand) Name() string	{ return "check" }
 func (cmd *checkCommand) Args() string {
-	return "[-q] [-skip-lock] [-skip-vendor]"
+	return "[-q] [-skip-lock] [-skip-vendor] [-noverify <project roots>] [-migrate-hash]"
 }
 func (cmd *checkCommand) ShortHelp() string	{ return checkShortHelp }
 func (cmd *checkCommand) LongHelp() string	{ return checkLongHelp }
@@ -56,6 +67,8 @@ func (cmd *checkCommand) Register(fs *flag.FlagSet) {
 	fs.BoolVar(&cmd.skiplock, "skip-lock", false, "Skip checking that imports and Gopkg.toml are in sync with Gopkg.lock")
 	fs.BoolVar(&cmd.skipvendor, "skip-vendor", false, "Skip checking that vendor is in sync with Gopkg.lock")
 	fs.BoolVar(&cmd.quiet, "q", false, "Suppress non-error output")
+	fs.StringVar(&cmd.noverify, "noverify", "", "Comma-separated project roots to treat as noverify in addition to those in Gopkg.toml")
+	fs.BoolVar(&cmd.migrateHash, "migrate-hash", false, "Rewrite digests in Gopkg.lock that use an older hash version before checking")
 }
 
 func (cmd *checkCommand) Run(ctx *dep.Ctx, args []string) error {
@@ -77,102 +90,83 @@ func (cmd *checkCommand) Run(ctx *dep.Ctx, args []string) error {
 	sm.UseDefaultSignalHandling()
 	defer sm.Release()
 
-	var fail bool
-	if !cmd.skiplock {
-		if p.Lock == nil {
-			return errors.New("Gopkg.lock does not exist, cannot check it against imports and Gopkg.toml")
+	if cmd.migrateHash {
+		if p, err = migrateDigests(ctx, p, logger); err != nil {
+			return err
 		}
+	}
+
+	var noverify []string
+	for _, skip := range strings.Split(cmd.noverify, ",") {
+		if skip = strings.TrimSpace(skip); skip != "" {
+			noverify = append(noverify, skip)
+		}
+	}
 
-		lsat := verify.LockSatisfiesInputs(p.Lock, p.Manifest, p.RootPackageTree)
-		delta := verify.DiffLocks(p.Lock, p.ChangedLock)
-		sat, changed := lsat.Satisfied(), delta.Changed(verify.PruneOptsChanged|verify.HashVersionChanged)
+	result, err := p.Check(cmd.skiplock, cmd.skipvendor, noverify)
+	if err != nil {
+		return err
+	}
 
-		if changed || !sat {
-			fail = true
-			logger.Println("# Gopkg.lock is out of sync:")
-			if !sat {
-				logger.Printf("%s\n", sprintLockUnsat(lsat))
+	if !result.LockInSync() {
+		logger.Println("# Gopkg.lock is out of sync:")
+		if lsat := result.LockSatisfaction; !lsat.Satisfied() {
+			logger.Printf("%s\n", sprintLockUnsat(lsat))
+		}
+		if delta := result.LockDelta; delta.Changed(verify.PruneOptsChanged | verify.HashVersionChanged) {
+			// Sort, for deterministic output.
+			var ordered []string
+			for pr := range delta.ProjectDeltas {
+				ordered = append(ordered, string(pr))
 			}
-			if changed {
-				// Sort, for deterministic output.
-				var ordered []string
-				for pr := range delta.ProjectDeltas {
-					ordered = append(ordered, string(pr))
+			sort.Strings(ordered)
+
+			for _, pr := range ordered {
+				lpd := delta.ProjectDeltas[gps.ProjectRoot(pr)]
+				// Only possible changes right now are prune opts or their
+				// parameters changing or a missing hash digest (for old
+				// Gopkg.lock files)
+				if lpd.PruneOptsBefore != lpd.PruneOptsAfter {
+					// Override what's on the lockdiff with the extra info we have;
+					// this lets us excise PruneNestedVendorDirs and get the real
+					// value from the input param in place.
+					old := lpd.PruneOptsBefore & ^gps.PruneNestedVendorDirs
+					new := lpd.PruneOptsAfter & ^gps.PruneNestedVendorDirs
+					logger.Printf("%s: prune options changed (%s -> %s)\n", pr, old, new)
+				}
+				if lpd.PruneParamsChanged() {
+					logger.Printf("%s: prune parameters changed (%s -> %s)\n", pr, orNoneValue(lpd.PruneParamsBefore.String()), orNoneValue(lpd.PruneParamsAfter.String()))
 				}
-				sort.Strings(ordered)
-
-				for _, pr := range ordered {
-					lpd := delta.ProjectDeltas[gps.ProjectRoot(pr)]
-					// Only two possible changes right now are prune opts
-					// changing or a missing hash digest (for old Gopkg.lock
-					// files)
-					if lpd.PruneOptsChanged() {
-						// Override what's on the lockdiff with the extra info we have;
-						// this lets us excise PruneNestedVendorDirs and get the real
-						// value from the input param in place.
-						old := lpd.PruneOptsBefore & ^gps.PruneNestedVendorDirs
-						new := lpd.PruneOptsAfter & ^gps.PruneNestedVendorDirs
-						logger.Printf("%s: prune options changed (%s -> %s)\n", pr, old, new)
-					}
-					if lpd.HashVersionWasZero() {
-						logger.Printf("%s: no hash digest in lock\n", pr)
-					}
+				if lpd.HashVersionWasZero() {
+					logger.Printf("%s: no hash digest in lock\n", pr)
 				}
 			}
 		}
 	}
 
-	if !cmd.skipvendor {
-		if p.Lock == nil {
-			return errors.New("Gopkg.lock does not exist, cannot check vendor against it")
-		}
-
-		statuses, err := p.VerifyVendor()
-		if err != nil {
-			return errors.Wrap(err, "error while verifying vendor")
-		}
-
-		if fail {
+	if result.VendorChecked {
+		if !result.LockInSync() {
 			logger.Println()
 		}
 
-		noverify := make(map[string]bool)
-		for _, skip := range p.Manifest.NoVerify {
-			noverify[skip] = true
-		}
-
-		var vendorfail bool
-		// One full pass through, to see if we need to print the header, and to
-		// create an array of names to sort for deterministic output.
+		// Sort, for deterministic output.
 		var ordered []string
-		for path, status := range statuses {
+		for path := range result.VendorStatus {
 			ordered = append(ordered, path)
-
-			switch status {
-			case verify.DigestMismatchInLock, verify.HashVersionMismatch, verify.EmptyDigestInLock:
-				// NoVerify applies only to these three cases.
-				if noverify[path] {
-					continue
-				}
-				fallthrough
-			case verify.NotInTree, verify.NotInLock:
-				fail = true
-				if !vendorfail {
-					vendorfail = true
-					logger.Println("# vendor is out of sync:")
-				}
-
-			}
 		}
 		sort.Strings(ordered)
 
+		if !result.VendorInSync() {
+			logger.Println("# vendor is out of sync:")
+		}
+
 		for _, pr := range ordered {
 			var nvSuffix string
-			if noverify[pr] {
-				nvSuffix = "  (CHECK IGNORED: marked noverify in Gopkg.toml)"
+			if result.NoVerify[pr] {
+				nvSuffix = "  (CHECK IGNORED: marked noverify)"
 			}
 
-			status := statuses[pr]
+			status := result.VendorStatus[pr]
 			switch status {
 			case verify.NotInTree:
 				logger.Printf("%s: missing from vendor\n", pr)
@@ -199,12 +193,63 @@ func (cmd *checkCommand) Run(ctx *dep.Ctx, args []string) error {
 		}
 	}
 
-	if fail {
+	if !result.InSync() {
 		return silentfail{}
 	}
 	return nil
 }
 
+// Check performs the same checks as "dep check" for the configuration's
+// working directory and returns the result rather than printing it. The
+// configuration's Args are ignored.
+func (c *Config) Check(skipLock, skipVendor bool, noverify []string) (dep.CheckResult, error) {
+	ctx, err := c.newContext(log.New(c.Stdout, "", 0), log.New(c.Stderr, "", 0), false)
+	if err != nil {
+		return dep.CheckResult{}, err
+	}
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return dep.CheckResult{}, err
+	}
+
+	sm, err := ctx.SourceManager()
+	if err != nil {
+		return dep.CheckResult{}, err
+	}
+	defer sm.Release()
+
+	return p.Check(skipLock, skipVendor, noverify)
+}
+
+// migrateDigests migrates the digests in the lock of p to the current hash
+// version, reports the result of each migration and returns the project loaded
+// again with the new lock.
+func migrateDigests(ctx *dep.Ctx, p *dep.Project, logger *log.Logger) (*dep.Project, error) {
+	migrations, err := p.MigrateDigests()
+	if err != nil {
+		return nil, err
+	}
+
+	var migrated bool
+	for _, m := range migrations {
+		switch {
+		case m.Migrated:
+			logger.Printf("%s: migrated digest from hash version %d to %d\n", m.ProjectRoot, m.FromVersion, verify.HashVersion)
+			migrated = true
+		case !verify.IsSupportedHashVersion(m.FromVersion):
+			ctx.Err.Printf("%s: cannot migrate digest of unsupported hash version %d\n", m.ProjectRoot, m.FromVersion)
+		default:
+			ctx.Err.Printf("%s: cannot migrate digest, vendored tree is missing or does not match it\n", m.ProjectRoot)
+		}
+	}
+	if !migrated {
+		return p, nil
+	}
+	logger.Println()
+	return ctx.LoadProject()
+}
+
 func sprintLockUnsat(lsat verify.LockSatisfaction) string {
 	var buf bytes.Buffer
 	sort.Strings(lsat.MissingImports)
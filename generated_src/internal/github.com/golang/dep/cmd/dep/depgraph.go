@@ -0,0 +1,293 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package amalgomated
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"sort"
+	"strings"
+)
+
+const (
+	projectGraphView	= "projects"
+	packageGraphView	= "packages"
+)
+
+// depGraph is a format-independent dependency graph. In the project-level
+// view, the nodes are projects. In the package-level view, the nodes are
+// packages, and the packages of projects with more than one package are
+// grouped into clusters. The root package of a clustered project is not a node
+// of its own: edges from or to it use the ID of the cluster instead.
+type depGraph struct {
+	View		string			`json:"view"`
+	Nodes		[]depGraphNode		`json:"nodes"`
+	Clusters	[]depGraphCluster	`json:"clusters,omitempty"`
+	Edges		[]depGraphEdge		`json:"edges"`
+}
+
+// depGraphNode is a project or package in a depGraph.
+type depGraphNode struct {
+	// ID is the project root or import path.
+	ID	string	`json:"id"`
+	// Version is the locked version of a project.
+	Version	string	`json:"version,omitempty"`
+	// Cluster is the ID of the cluster that contains the package, if any.
+	Cluster	string	`json:"cluster,omitempty"`
+}
+
+// depGraphCluster is a group of packages of a single project.
+type depGraphCluster struct {
+	// ID is the project root.
+	ID		string		`json:"id"`
+	Packages	[]string	`json:"packages"`
+}
+
+// depGraphEdge is a dependency of From on To. Either end may be the ID of a
+// node or of a cluster.
+type depGraphEdge struct {
+	From	string	`json:"from"`
+	To	string	`json:"to"`
+}
+
+// graphEmitter writes a depGraph in a specific format.
+type graphEmitter func(w io.Writer, g *depGraph) error
+
+// graphEmitters are the supported graph formats.
+var graphEmitters = map[string]graphEmitter{
+	"dot":		emitDOT,
+	"mermaid":	emitMermaid,
+	"graphml":	emitGraphML,
+	"json":		emitJSON,
+}
+
+// graphFormats returns the names of the supported graph formats in sorted
+// order.
+func graphFormats() []string {
+	var formats []string
+	for format := range graphEmitters {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// emitDOT writes the graph in the GraphViz DOT format. Nodes are identified by
+// the hash of their ID, and clusters are rendered as subgraphs whose edges are
+// attached to the first package of the cluster.
+func emitDOT(w io.Writer, g *depGraph) error {
+	var b strings.Builder
+	if g.View == packageGraphView {
+		b.WriteString("digraph {\n\tnode [shape=box];\n\tcompound=true;\n\tedge [minlen=2];")
+	} else {
+		b.WriteString("digraph {\n\tnode [shape=box];")
+	}
+
+	for _, n := range g.Nodes {
+		label := n.ID
+		if n.Version != "" {
+			label += "\\n" + n.Version
+		}
+		b.WriteString(fmt.Sprintf("\n\t%d [label=\"%s\"];", dotID(n.ID), label))
+	}
+
+	for i, c := range g.Clusters {
+		b.WriteString(fmt.Sprintf("\n\tsubgraph cluster_%d {", i))
+		b.WriteString(fmt.Sprintf("\n\t\tlabel = \"%s\";", c.ID))
+
+		nhashes := []string{}
+		for _, pkg := range c.Packages {
+			nhashes = append(nhashes, fmt.Sprint(dotID(pkg)))
+		}
+
+		b.WriteString(fmt.Sprintf("\n\t\t%s;", strings.Join(nhashes, " ")))
+		b.WriteString("\n\t}")
+	}
+
+	for _, e := range g.Edges {
+		from, to := dotID(e.From), dotID(e.To)
+		meta := []string{}
+		for i, c := range g.Clusters {
+			if c.ID == e.From {
+				// When the tail is a cluster, use the first node in the
+				// cluster as from.
+				meta = append(meta, fmt.Sprintf("ltail=cluster_%d", i))
+				from = dotID(c.Packages[0])
+			}
+		}
+		for i, c := range g.Clusters {
+			if c.ID == e.To {
+				// When the head is a cluster, use the first node in the
+				// cluster as to.
+				meta = append(meta, fmt.Sprintf("lhead=cluster_%d", i))
+				to = dotID(c.Packages[0])
+			}
+		}
+
+		if len(meta) > 0 {
+			b.WriteString(fmt.Sprintf("\n\t%d -> %d [%s];", from, to, strings.Join(meta, " ")))
+		} else {
+			b.WriteString(fmt.Sprintf("\n\t%d -> %d;", from, to))
+		}
+	}
+
+	b.WriteString("\n}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func dotID(id string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return h.Sum32()
+}
+
+// emitMermaid writes the graph as a Mermaid flowchart. Clusters are rendered as
+// subgraphs.
+func emitMermaid(w io.Writer, g *depGraph) error {
+	ids := make(map[string]string)
+	for i, n := range g.Nodes {
+		ids[n.ID] = fmt.Sprintf("n%d", i)
+	}
+	for i, c := range g.Clusters {
+		ids[c.ID] = fmt.Sprintf("c%d", i)
+	}
+
+	var b strings.Builder
+	b.WriteString("graph TD\n")
+	writeNode := func(indent string, n depGraphNode) {
+		label := mermaidEscape(n.ID)
+		if n.Version != "" {
+			label += "<br/>" + mermaidEscape(n.Version)
+		}
+		b.WriteString(fmt.Sprintf("%s%s[\"%s\"]\n", indent, ids[n.ID], label))
+	}
+	for _, n := range g.Nodes {
+		if n.Cluster == "" {
+			writeNode("    ", n)
+		}
+	}
+	for _, c := range g.Clusters {
+		b.WriteString(fmt.Sprintf("    subgraph %s [\"%s\"]\n", ids[c.ID], mermaidEscape(c.ID)))
+		for _, n := range g.Nodes {
+			if n.Cluster == c.ID {
+				writeNode("        ", n)
+			}
+		}
+		b.WriteString("    end\n")
+	}
+	for _, e := range g.Edges {
+		b.WriteString(fmt.Sprintf("    %s --> %s\n", ids[e.From], ids[e.To]))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func mermaidEscape(s string) string {
+	return strings.Replace(s, `"`, "#quot;", -1)
+}
+
+type graphMLDocument struct {
+	XMLName	xml.Name	`xml:"http://graphml.graphdrawing.org/xmlns graphml"`
+	Keys	[]graphMLKey	`xml:"key"`
+	Graph	graphMLGraph	`xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID	string	`xml:"id,attr"`
+	For	string	`xml:"for,attr"`
+	Name	string	`xml:"attr.name,attr"`
+	Type	string	`xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID		string		`xml:"id,attr"`
+	EdgeDefault	string		`xml:"edgedefault,attr"`
+	Nodes		[]graphMLNode	`xml:"node"`
+	Edges		[]graphMLEdge	`xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID	string		`xml:"id,attr"`
+	Data	[]graphMLData	`xml:"data"`
+	Graph	*graphMLGraph	`xml:"graph,omitempty"`
+}
+
+type graphMLData struct {
+	Key	string	`xml:"key,attr"`
+	Value	string	`xml:",chardata"`
+}
+
+type graphMLEdge struct {
+	Source	string	`xml:"source,attr"`
+	Target	string	`xml:"target,attr"`
+}
+
+// emitGraphML writes the graph in the GraphML format. Clusters are rendered as
+// nodes that contain a nested graph of their packages.
+func emitGraphML(w io.Writer, g *depGraph) error {
+	graphMLNodeFor := func(n depGraphNode) graphMLNode {
+		gn := graphMLNode{ID: n.ID}
+		if n.Version != "" {
+			gn.Data = append(gn.Data, graphMLData{Key: "version", Value: n.Version})
+		}
+		return gn
+	}
+
+	doc := graphMLDocument{
+		Keys: []graphMLKey{
+			{ID: "version", For: "node", Name: "version", Type: "string"},
+		},
+		Graph: graphMLGraph{
+			ID:		"G",
+			EdgeDefault:	"directed",
+		},
+	}
+	for _, n := range g.Nodes {
+		if n.Cluster == "" {
+			doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNodeFor(n))
+		}
+	}
+	for _, c := range g.Clusters {
+		cn := graphMLNode{
+			ID:	c.ID,
+			Graph: &graphMLGraph{
+				ID:		c.ID + ":",
+				EdgeDefault:	"directed",
+			},
+		}
+		for _, n := range g.Nodes {
+			if n.Cluster == c.ID {
+				cn.Graph.Nodes = append(cn.Graph.Nodes, graphMLNodeFor(n))
+			}
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, cn)
+	}
+	for _, e := range g.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{Source: e.From, Target: e.To})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// emitJSON writes the graph as a JSON document of nodes, clusters and edges.
+func emitJSON(w io.Writer, g *depGraph) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(g)
+}
@@ -0,0 +1,499 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package amalgomated
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/palantir/godel-dep-plugin/generated_src/internal/github.com/golang/dep/amalgomated_flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/palantir/godel-dep-plugin/generated_src/internal/github.com/golang/dep"
+	"github.com/palantir/godel-dep-plugin/generated_src/internal/github.com/golang/dep/gps"
+	"github.com/palantir/godel-dep-plugin/generated_src/internal/github.com/golang/dep/gps/verify"
+	"github.com/pkg/errors"
+)
+
+const diffShortHelp = `Show the differences between two versions of Gopkg.lock`
+const diffLongHelp = `
+Compare two versions of Gopkg.lock and report the projects that were added,
+removed or changed. For changed projects, the changes to the version, revision,
+source, packages and prune options are reported, as well as whether the digest
+of the project changed.
+
+Each argument is either the path to a lock file or a git revision, in which
+case Gopkg.lock is read from that revision of the git repository that contains
+the current project. The old lock defaults to HEAD and the new lock defaults to
+the Gopkg.lock of the current project, so that running "dep diff" with no
+arguments shows the uncommitted changes to Gopkg.lock.
+
+The report is printed as text by default. -format json prints it as a JSON
+document, and -format markdown prints it as a Markdown table suitable for pull
+request comments.
+`
+
+const diffExamples = `
+dep diff
+	Show the uncommitted changes to Gopkg.lock
+
+dep diff origin/master
+	Show the changes to Gopkg.lock since origin/master
+
+dep diff -format markdown old/Gopkg.lock Gopkg.lock
+	Compare two lock files and print the result as a Markdown table
+`
+
+const (
+	diffFormatText		= "text"
+	diffFormatJSON		= "json"
+	diffFormatMarkdown	= "markdown"
+)
+
+type diffCommand struct {
+	format string
+}
+
+func (cmd *diffCommand) Name() string		{ return "diff" }
+func (cmd *diffCommand) Args() string		{ return "[-format text|json|markdown] [old-lock] [new-lock]" }
+func (cmd *diffCommand) ShortHelp() string	{ return diffShortHelp }
+func (cmd *diffCommand) LongHelp() string	{ return diffLongHelp + diffExamples }
+func (cmd *diffCommand) Hidden() bool		{ return false }
+
+func (cmd *diffCommand) Register(fs *flag.FlagSet) {
+	fs.StringVar(&cmd.format, "format", diffFormatText, "output format: text, json or markdown")
+}
+
+// lockDiff is the report of the differences between two locks.
+type lockDiff struct {
+	Old, New		string
+	AddedInputImports	[]string	`json:",omitempty"`
+	RemovedInputImports	[]string	`json:",omitempty"`
+	Added			[]lockDiffProject
+	Removed			[]lockDiffProject
+	Changed			[]lockDiffChange
+}
+
+// lockDiffProject is a project that exists in only one of the locks.
+type lockDiffProject struct {
+	ProjectRoot	string
+	Source		string	`json:",omitempty"`
+	Version		string	`json:",omitempty"`
+	Branch		string	`json:",omitempty"`
+	Revision	string
+	Packages	[]string
+	PruneOpts	string
+}
+
+// lockDiffChange is a project that exists in both locks with different
+// properties. The properties that did not change are nil.
+type lockDiffChange struct {
+	ProjectRoot	string
+	Source		*lockDiffValue	`json:",omitempty"`
+	Version		*lockDiffValue	`json:",omitempty"`
+	Revision	*lockDiffValue	`json:",omitempty"`
+	PackagesAdded	[]string	`json:",omitempty"`
+	PackagesRemoved	[]string	`json:",omitempty"`
+	PruneOpts	*lockDiffValue	`json:",omitempty"`
+	DigestChanged	bool
+}
+
+type lockDiffValue struct {
+	Before, After string
+}
+
+func (cmd *diffCommand) Run(ctx *dep.Ctx, args []string) error {
+	switch cmd.format {
+	case diffFormatText, diffFormatJSON, diffFormatMarkdown:
+	default:
+		return errors.Errorf("invalid format %q: must be one of %s, %s or %s", cmd.format, diffFormatText, diffFormatJSON, diffFormatMarkdown)
+	}
+
+	ll, err := loadLockPair(ctx, args)
+	if err != nil {
+		return err
+	}
+	diff := newLockDiff(ll)
+
+	var buf bytes.Buffer
+	switch cmd.format {
+	case diffFormatJSON:
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(diff); err != nil {
+			return err
+		}
+	case diffFormatMarkdown:
+		writeLockDiffMarkdown(&buf, diff)
+	default:
+		writeLockDiffText(&buf, diff)
+	}
+	ctx.Out.Print(buf.String())
+	return nil
+}
+
+// lockPair is a pair of locks to compare, along with descriptions of where
+// they were read from.
+type lockPair struct {
+	old, new		*dep.Lock
+	oldName, newName	string
+}
+
+// loadLockPair reads the locks designated by the [old-lock] [new-lock]
+// arguments of a command.
+func loadLockPair(ctx *dep.Ctx, args []string) (lockPair, error) {
+	if len(args) > 2 {
+		return lockPair{}, errors.Errorf("too many args (%d)", len(args))
+	}
+
+	var ll lockPair
+	// Two lock files can be compared outside of any project.
+	project := projectLoader(ctx)
+
+	oldArg := "HEAD"
+	if len(args) > 0 {
+		oldArg = args[0]
+	}
+	var err error
+	ll.old, ll.oldName, err = loadLockArg(ctx, project, oldArg)
+	if err != nil {
+		return lockPair{}, err
+	}
+
+	if len(args) == 2 {
+		ll.new, ll.newName, err = loadLockArg(ctx, project, args[1])
+		if err != nil {
+			return lockPair{}, err
+		}
+		return ll, nil
+	}
+
+	p, err := project()
+	if err != nil {
+		return lockPair{}, err
+	}
+	if p.Lock == nil {
+		return lockPair{}, errors.Errorf("no %s found in %s", dep.LockName, p.AbsRoot)
+	}
+	ll.new, ll.newName = p.Lock, filepath.Join(p.AbsRoot, dep.LockName)
+	return ll, nil
+}
+
+// projectLoader returns a function that loads the current project the first
+// time it is called.
+func projectLoader(ctx *dep.Ctx) func() (*dep.Project, error) {
+	var p *dep.Project
+	return func() (*dep.Project, error) {
+		if p != nil {
+			return p, nil
+		}
+		var err error
+		p, err = ctx.LoadProject()
+		return p, err
+	}
+}
+
+// loadLockArgs reads the lock designated by the single element of args as
+// loadLockArg does, or the lock of the current project if args is empty.
+func loadLockArgs(ctx *dep.Ctx, args []string) (*dep.Lock, error) {
+	project := projectLoader(ctx)
+	if len(args) == 1 {
+		l, _, err := loadLockArg(ctx, project, args[0])
+		return l, err
+	}
+
+	p, err := project()
+	if err != nil {
+		return nil, err
+	}
+	if p.Lock == nil {
+		return nil, errors.Errorf("no %s found in %s", dep.LockName, p.AbsRoot)
+	}
+	return p.Lock, nil
+}
+
+// loadLockArg reads the lock designated by arg, which is either a path to a
+// lock file or a git revision.
+func loadLockArg(ctx *dep.Ctx, project func() (*dep.Project, error), arg string) (*dep.Lock, string, error) {
+	path := arg
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(ctx.WorkingDir, path)
+	}
+	if fi, err := os.Stat(path); err == nil && !fi.IsDir() {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, "", err
+		}
+		defer f.Close()
+		l, err := dep.ReadLock(f)
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "failed to read %s", path)
+		}
+		return l, path, nil
+	}
+
+	// git would take an argument starting with a dash for an option.
+	if strings.HasPrefix(arg, "-") {
+		return nil, "", errors.Errorf("%s is neither a lock file nor a git revision", arg)
+	}
+	p, err := project()
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "%s is not a lock file, and reading it as a git revision requires a project", arg)
+	}
+	// Paths starting with ./ or ../ in a revision are relative to the
+	// directory git runs in, which need not be the project root.
+	rel, err := filepath.Rel(ctx.WorkingDir, p.AbsRoot)
+	if err != nil {
+		return nil, "", err
+	}
+	lpath := filepath.ToSlash(filepath.Join(rel, dep.LockName))
+	if !strings.HasPrefix(lpath, "../") {
+		lpath = "./" + lpath
+	}
+	cmd := exec.Command("git", "show", arg+":"+lpath)
+	cmd.Dir = ctx.WorkingDir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, "", errors.Errorf("%s is neither a lock file nor a git revision that contains %s: %s", arg, dep.LockName, strings.TrimSpace(stderr.String()))
+	}
+	l, err := dep.ReadLock(bytes.NewReader(out))
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "failed to read %s at %s", dep.LockName, arg)
+	}
+	return l, arg + ":" + dep.LockName, nil
+}
+
+func newLockDiff(ll lockPair) lockDiff {
+	delta := verify.DiffLocks(ll.old, ll.new)
+	diff := lockDiff{
+		Old:			ll.oldName,
+		New:			ll.newName,
+		AddedInputImports:	delta.AddedImportInputs,
+		RemovedInputImports:	delta.RemovedImportInputs,
+		Added:			[]lockDiffProject{},
+		Removed:		[]lockDiffProject{},
+		Changed:		[]lockDiffChange{},
+	}
+
+	names := make([]string, 0, len(delta.ProjectDeltas))
+	for pr := range delta.ProjectDeltas {
+		names = append(names, string(pr))
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		pd := delta.ProjectDeltas[gps.ProjectRoot(name)]
+		switch {
+		case pd.WasAdded():
+			diff.Added = append(diff.Added, newLockDiffProject(findLockedProject(ll.new, pd.Name)))
+		case pd.WasRemoved():
+			diff.Removed = append(diff.Removed, newLockDiffProject(findLockedProject(ll.old, pd.Name)))
+		case pd.Changes() != 0:
+			diff.Changed = append(diff.Changed, newLockDiffChange(pd))
+		}
+	}
+	return diff
+}
+
+func findLockedProject(l *dep.Lock, pr gps.ProjectRoot) gps.LockedProject {
+	for _, lp := range l.Projects() {
+		if lp.Ident().ProjectRoot == pr {
+			return lp
+		}
+	}
+	return nil
+}
+
+func newLockDiffProject(lp gps.LockedProject) lockDiffProject {
+	ldp := lockDiffProject{
+		ProjectRoot:	string(lp.Ident().ProjectRoot),
+		Source:		lp.Ident().Source,
+		Packages:	lp.Packages(),
+	}
+	ldp.Revision, ldp.Branch, ldp.Version = gps.VersionComponentStrings(lp.Version())
+	if vp, ok := lp.(verify.VerifiableProject); ok {
+		ldp.PruneOpts = formatPruneOpts(vp.PruneOpts, vp.PruneParams)
+	}
+	return ldp
+}
+
+func newLockDiffChange(pd verify.LockedProjectDelta) lockDiffChange {
+	ldc := lockDiffChange{
+		ProjectRoot:		string(pd.Name),
+		PackagesAdded:		pd.PackagesAdded,
+		PackagesRemoved:	pd.PackagesRemoved,
+		DigestChanged:		pd.HashChanged || pd.HashVersionChanged(),
+	}
+	if pd.SourceChanged() {
+		ldc.Source = &lockDiffValue{Before: pd.SourceBefore, After: pd.SourceAfter}
+	}
+	if pd.VersionChanged() {
+		ldc.Version = &lockDiffValue{Before: formatVersion(pd.VersionBefore), After: formatVersion(pd.VersionAfter)}
+	}
+	if pd.RevisionChanged() {
+		ldc.Revision = &lockDiffValue{Before: string(pd.RevisionBefore), After: string(pd.RevisionAfter)}
+	}
+	if pd.PruneOptsChanged() {
+		ldc.PruneOpts = &lockDiffValue{Before: formatPruneOpts(pd.PruneOptsBefore, pd.PruneParamsBefore), After: formatPruneOpts(pd.PruneOptsAfter, pd.PruneParamsAfter)}
+	}
+	return ldc
+}
+
+// formatPruneOpts formats prune options as they appear in Gopkg.lock, followed
+// by their parameters, if any.
+func formatPruneOpts(po gps.PruneOptions, pp gps.PruneParams) string {
+	s := (po & ^gps.PruneNestedVendorDirs).String()
+	if params := pp.String(); params != "" {
+		s += " (" + params + ")"
+	}
+	return s
+}
+
+// shortRevision abbreviates a revision for display.
+func shortRevision(r string) string {
+	return formatVersion(gps.Revision(r))
+}
+
+func (ldp lockDiffProject) version() string {
+	switch {
+	case ldp.Version != "":
+		return ldp.Version
+	case ldp.Branch != "":
+		return "branch " + ldp.Branch
+	}
+	return ""
+}
+
+func orNoneValue(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
+
+func (diff lockDiff) empty() bool {
+	return len(diff.AddedInputImports) == 0 && len(diff.RemovedInputImports) == 0 &&
+		len(diff.Added) == 0 && len(diff.Removed) == 0 && len(diff.Changed) == 0
+}
+
+func writeLockDiffText(buf *bytes.Buffer, diff lockDiff) {
+	fmt.Fprintf(buf, "--- %s\n+++ %s\n", diff.Old, diff.New)
+	if diff.empty() {
+		buf.WriteString("\nThe locks are identical.\n")
+		return
+	}
+
+	if len(diff.AddedInputImports) > 0 || len(diff.RemovedInputImports) > 0 {
+		buf.WriteString("\nInput imports:\n")
+		for _, imp := range diff.AddedInputImports {
+			fmt.Fprintf(buf, "  + %s\n", imp)
+		}
+		for _, imp := range diff.RemovedInputImports {
+			fmt.Fprintf(buf, "  - %s\n", imp)
+		}
+	}
+
+	writeProjects := func(title string, ldps []lockDiffProject) {
+		if len(ldps) == 0 {
+			return
+		}
+		fmt.Fprintf(buf, "\n%s:\n", title)
+		for _, ldp := range ldps {
+			fmt.Fprintf(buf, "  %s", ldp.ProjectRoot)
+			if v := ldp.version(); v != "" {
+				fmt.Fprintf(buf, " %s", v)
+			}
+			fmt.Fprintf(buf, " (%s)", shortRevision(ldp.Revision))
+			if ldp.Source != "" {
+				fmt.Fprintf(buf, " from %s", ldp.Source)
+			}
+			buf.WriteString("\n")
+		}
+	}
+	writeProjects("Added", diff.Added)
+	writeProjects("Removed", diff.Removed)
+
+	if len(diff.Changed) > 0 {
+		buf.WriteString("\nChanged:\n")
+		for _, ldc := range diff.Changed {
+			fmt.Fprintf(buf, "  %s\n", ldc.ProjectRoot)
+			writeValue := func(name string, v *lockDiffValue, format func(string) string) {
+				if v != nil {
+					fmt.Fprintf(buf, "    %-10s %s -> %s\n", name, format(v.Before), format(v.After))
+				}
+			}
+			writeValue("source", ldc.Source, orNoneValue)
+			writeValue("version", ldc.Version, orNoneValue)
+			writeValue("revision", ldc.Revision, shortRevision)
+			for _, pkg := range ldc.PackagesAdded {
+				fmt.Fprintf(buf, "    %-10s + %s\n", "package", pkg)
+			}
+			for _, pkg := range ldc.PackagesRemoved {
+				fmt.Fprintf(buf, "    %-10s - %s\n", "package", pkg)
+			}
+			writeValue("pruneopts", ldc.PruneOpts, orNoneValue)
+			if ldc.DigestChanged {
+				fmt.Fprintf(buf, "    %-10s changed\n", "digest")
+			}
+		}
+	}
+}
+
+func writeLockDiffMarkdown(buf *bytes.Buffer, diff lockDiff) {
+	if len(diff.Added) == 0 && len(diff.Removed) == 0 && len(diff.Changed) == 0 {
+		buf.WriteString("No dependencies changed.\n")
+		return
+	}
+
+	code := func(s string) string {
+		if s == "" {
+			return ""
+		}
+		return "`" + s + "`"
+	}
+	change := func(v *lockDiffValue, format func(string) string) string {
+		if v == nil {
+			return ""
+		}
+		return format(v.Before) + " → " + format(v.After)
+	}
+	shortCode := func(r string) string { return code(shortRevision(r)) }
+
+	buf.WriteString("| Project | Change | Version | Revision | Source | Packages | Prune |\n")
+	buf.WriteString("| --- | --- | --- | --- | --- | --- | --- |\n")
+	row := func(cells ...string) {
+		for i, c := range cells {
+			cells[i] = strings.Replace(c, "|", "\\|", -1)
+		}
+		fmt.Fprintf(buf, "| %s |\n", strings.Join(cells, " | "))
+	}
+	for _, ldp := range diff.Added {
+		row(code(ldp.ProjectRoot), "added", ldp.version(), shortCode(ldp.Revision), ldp.Source, strings.Join(ldp.Packages, ", "), ldp.PruneOpts)
+	}
+	for _, ldp := range diff.Removed {
+		row(code(ldp.ProjectRoot), "removed", ldp.version(), shortCode(ldp.Revision), ldp.Source, strings.Join(ldp.Packages, ", "), ldp.PruneOpts)
+	}
+	for _, ldc := range diff.Changed {
+		var pkgs []string
+		for _, pkg := range ldc.PackagesAdded {
+			pkgs = append(pkgs, "+"+pkg)
+		}
+		for _, pkg := range ldc.PackagesRemoved {
+			pkgs = append(pkgs, "-"+pkg)
+		}
+		kind := "changed"
+		if ldc.Source == nil && ldc.Version == nil && ldc.Revision == nil && len(pkgs) == 0 && ldc.PruneOpts == nil {
+			kind = "digest changed"
+		}
+		row(code(ldc.ProjectRoot), kind, change(ldc.Version, orNoneValue), change(ldc.Revision, shortCode),
+			change(ldc.Source, orNoneValue), strings.Join(pkgs, ", "), change(ldc.PruneOpts, orNoneValue))
+	}
+}
@@ -5,6 +5,7 @@
 package amalgomated
 
 import (
+	"bytes"
 	"context"
 	"github.com/palantir/godel-dep-plugin/generated_src/internal/github.com/golang/dep/amalgomated_flag"
 	"fmt"
@@ -15,6 +16,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"text/tabwriter"
 
 	"github.com/palantir/godel-dep-plugin/generated_src/internal/github.com/golang/dep"
 	"github.com/palantir/godel-dep-plugin/generated_src/internal/github.com/golang/dep/gps"
@@ -110,6 +112,26 @@ dep ensure -update -no-vendor
 
     As above, but only modify Gopkg.lock; leave vendor/ unchanged.
 
+dep ensure -update -patch github.com/pkg/foo
+
+    Update a dependency to the newest version allowed by Gopkg.toml that has
+    the same major and minor version as the version in Gopkg.lock. With
+    -minor, the major version stays the same instead. Dependencies that are
+    not locked to a semantic version are not limited.
+
+dep ensure -update -minimal
+
+    Update all dependencies to the oldest versions allowed by Gopkg.toml,
+    rather than the newest. This can be used to check that the lower bounds of
+    the constraints in Gopkg.toml actually build. Setting "minimal-versions =
+    true" in Gopkg.toml makes every solve prefer the oldest versions.
+
+dep ensure -update -minimal-diff
+
+    Solve twice, once preferring the oldest and once preferring the newest
+    versions allowed by Gopkg.toml, and report the dependencies whose versions
+    differ between the two solutions. Nothing is written.
+
 dep ensure -no-vendor -dry-run
 
     This fails with a non zero exit code if Gopkg.lock is not up to date with
@@ -125,7 +147,7 @@ var (
 
 func (cmd *ensureCommand) Name() string	{ return "ensure" }
 func (cmd *ensureCommand) Args() string {
-	return "[-update | -add] [-no-vendor | -vendor-only] [-dry-run] [-v] [<spec>...]"
+	return "[-update [-patch | -minor] [-minimal | -minimal-diff] | -add] [-no-vendor | -vendor-only] [-dry-run] [-v] [<spec>...]"
 }
 func (cmd *ensureCommand) ShortHelp() string	{ return ensureShortHelp }
 func (cmd *ensureCommand) LongHelp() string	{ return ensureLongHelp }
@@ -134,19 +156,31 @@ func (cmd *ensureCommand) Hidden() bool		{ return false }
 func (cmd *ensureCommand) Register(fs *flag.FlagSet) {
 	fs.BoolVar(&cmd.examples, "examples", false, "print detailed usage examples")
 	fs.BoolVar(&cmd.update, "update", false, "update the named dependencies (or all, if none are named) in Gopkg.lock to the latest allowed by Gopkg.toml")
+	fs.BoolVar(&cmd.patch, "patch", false, "with -update, only allow versions with the same major and minor version as the locked version")
+	fs.BoolVar(&cmd.minor, "minor", false, "with -update, only allow versions with the same major version as the locked version")
+	fs.BoolVar(&cmd.minimal, "minimal", false, "with -update, update to the oldest versions allowed by Gopkg.toml instead of the newest")
+	fs.BoolVar(&cmd.minimalDiff, "minimal-diff", false, "with -update, report the differences between the oldest and newest versions allowed by Gopkg.toml")
 	fs.BoolVar(&cmd.add, "add", false, "add new dependencies, or populate Gopkg.toml with constraints for existing dependencies")
 	fs.BoolVar(&cmd.vendorOnly, "vendor-only", false, "populate vendor/ from Gopkg.lock without updating it first")
 	fs.BoolVar(&cmd.noVendor, "no-vendor", false, "update Gopkg.lock (if needed), but do not update vendor/")
 	fs.BoolVar(&cmd.dryRun, "dry-run", false, "only report the changes that would be made")
+	fs.StringVar(&cmd.traceJSON, "trace-json", "", "write a JSON Lines trace of the solver's progress to the given file")
+	fs.BoolVar(&cmd.jsonFailure, "json-failure", false, "if solving fails, print a JSON description of the failure with suggested remedies")
 }
 
 type ensureCommand struct {
 	examples	bool
 	update		bool
+	patch		bool
+	minor		bool
+	minimal		bool
+	minimalDiff	bool
 	add		bool
 	noVendor	bool
 	vendorOnly	bool
 	dryRun		bool
+	traceJSON	string
+	jsonFailure	bool
 }
 
 func (cmd *ensureCommand) Run(ctx *dep.Ctx, args []string) error {
@@ -179,6 +213,18 @@ func (cmd *ensureCommand) Run(ctx *dep.Ctx, args []string) error {
 	if ctx.Verbose {
 		params.TraceLogger = ctx.Err
 	}
+	if cmd.traceJSON != "" {
+		path := cmd.traceJSON
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(ctx.WorkingDir, path)
+		}
+		f, err := os.Create(path)
+		if err != nil {
+			return errors.Wrap(err, "failed to create solver trace file")
+		}
+		defer f.Close()
+		params.Tracer = gps.NewJSONLinesTracer(f)
+	}
 
 	if cmd.vendorOnly {
 		return cmd.runVendorOnly(ctx, args, p, sm, params)
@@ -223,6 +269,20 @@ func (cmd *ensureCommand) validateFlags() error {
 		return errors.New("cannot pass both -add and -update")
 	}
 
+	if (cmd.minimal || cmd.minimalDiff) && !cmd.update {
+		return errors.New("-minimal and -minimal-diff can only be passed with -update")
+	}
+	if cmd.minimal && cmd.minimalDiff {
+		return errors.New("cannot pass both -minimal and -minimal-diff")
+	}
+
+	if (cmd.patch || cmd.minor) && !cmd.update {
+		return errors.New("-patch and -minor can only be passed with -update")
+	}
+	if cmd.patch && cmd.minor {
+		return errors.New("cannot pass both -patch and -minor")
+	}
+
 	if cmd.vendorOnly {
 		if cmd.update {
 			return errors.New("-vendor-only makes -update a no-op; cannot pass them together")
@@ -238,6 +298,22 @@ func (cmd *ensureCommand) validateFlags() error {
 	return nil
 }
 
+// handleSolveFailure handles a failure to solve. With -json-failure, the
+// failure is also printed as JSON.
+func (cmd *ensureCommand) handleSolveFailure(ctx *dep.Ctx, err error) error {
+	err = handleAllTheFailuresOfTheWorld(err)
+	if err == nil || !cmd.jsonFailure {
+		return err
+	}
+
+	b, jerr := failureJSON(err)
+	if jerr != nil {
+		return jerr
+	}
+	ctx.Out.Println(string(b))
+	return err
+}
+
 func (cmd *ensureCommand) vendorBehavior() dep.VendorBehavior {
 	if cmd.noVendor {
 		return dep.VendorNever
@@ -280,7 +356,7 @@ func (cmd *ensureCommand) runDefault(ctx *dep.Ctx, args []string, p *dep.Project
 
 		solution, err := solver.Solve(context.TODO())
 		if err != nil {
-			return handleAllTheFailuresOfTheWorld(err)
+			return cmd.handleSolveFailure(ctx, err)
 		}
 		lock = dep.LockFromSolution(solution, p.Manifest.PruneOptions)
 	}
@@ -348,6 +424,19 @@ func (cmd *ensureCommand) runUpdate(ctx *dep.Ctx, args []string, p *dep.Project,
 		return err
 	}
 
+	if cmd.patch {
+		params.UpdateScope = gps.UpdatePatch
+	} else if cmd.minor {
+		params.UpdateScope = gps.UpdateMinor
+	}
+
+	if cmd.minimalDiff {
+		return cmd.runMinimalDiff(ctx, sm, params)
+	}
+	if cmd.minimal {
+		params.Downgrade = true
+	}
+
 	// Re-prepare a solver now that our params are complete.
 	solver, err := gps.Prepare(params, sm)
 	if err != nil {
@@ -358,7 +447,7 @@ func (cmd *ensureCommand) runUpdate(ctx *dep.Ctx, args []string, p *dep.Project,
 		// TODO(sdboyer) special handling for warning cases as described in spec
 		// - e.g., named projects did not upgrade even though newer versions
 		// were available.
-		return handleAllTheFailuresOfTheWorld(err)
+		return cmd.handleSolveFailure(ctx, err)
 	}
 
 	dw, err := dep.NewDeltaWriter(p, dep.LockFromSolution(solution, p.Manifest.PruneOptions), cmd.vendorBehavior())
@@ -376,6 +465,62 @@ func (cmd *ensureCommand) runUpdate(ctx *dep.Ctx, args []string, p *dep.Project,
 	return errors.Wrap(dw.Write(p.AbsRoot, sm, false, logger), "grouped write of manifest, lock and vendor")
 }
 
+// runMinimalDiff solves with a preference for the oldest and for the newest
+// versions, and prints the projects whose versions differ.
+func (cmd *ensureCommand) runMinimalDiff(ctx *dep.Ctx, sm gps.SourceManager, params gps.SolveParameters) error {
+	versions := make(map[gps.ProjectRoot][2]string)
+	for i, downgrade := range []bool{true, false} {
+		params.Downgrade = downgrade
+		solver, err := gps.Prepare(params, sm)
+		if err != nil {
+			return errors.Wrap(err, "prepare solver")
+		}
+		solution, err := solver.Solve(context.TODO())
+		if err != nil {
+			return cmd.handleSolveFailure(ctx, err)
+		}
+		for _, lp := range solution.Projects() {
+			pr := lp.Ident().ProjectRoot
+			v := versions[pr]
+			v[i] = formatVersion(lp.Version())
+			versions[pr] = v
+		}
+	}
+
+	var roots []string
+	for pr, v := range versions {
+		if v[0] != v[1] {
+			roots = append(roots, string(pr))
+		}
+	}
+	if len(roots) == 0 {
+		ctx.Out.Println("The minimal and maximal solutions are identical.")
+		return nil
+	}
+	sort.Strings(roots)
+
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PROJECT\tMINIMAL\tMAXIMAL")
+	for _, pr := range roots {
+		v := versions[gps.ProjectRoot(pr)]
+		fmt.Fprintf(w, "%s\t%s\t%s\n", pr, orNone(v[0]), orNone(v[1]))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	ctx.Out.Print(buf.String())
+	return nil
+}
+
+// orNone returns the provided version, or "(none)" if it is empty.
+func orNone(v string) string {
+	if v == "" {
+		return "(none)"
+	}
+	return v
+}
+
 func (cmd *ensureCommand) runAdd(ctx *dep.Ctx, args []string, p *dep.Project, sm gps.SourceManager, params gps.SolveParameters) error {
 	if len(args) == 0 {
 		return errors.New("must specify at least one project or package to -add")
@@ -587,7 +732,7 @@ func (cmd *ensureCommand) runAdd(ctx *dep.Ctx, args []string, p *dep.Project, sm
 	solution, err := solver.Solve(context.TODO())
 	if err != nil {
 		// TODO(sdboyer) detect if the failure was specifically about some of the -add arguments
-		return handleAllTheFailuresOfTheWorld(err)
+		return cmd.handleSolveFailure(ctx, err)
 	}
 
 	// Prep post-actions and feedback from adds.
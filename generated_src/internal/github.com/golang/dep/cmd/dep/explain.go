@@ -0,0 +1,245 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package amalgomated
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/palantir/godel-dep-plugin/generated_src/internal/github.com/golang/dep/amalgomated_flag"
+	"fmt"
+	"strings"
+
+	"github.com/palantir/godel-dep-plugin/generated_src/internal/github.com/golang/dep"
+	"github.com/palantir/godel-dep-plugin/generated_src/internal/github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+const explainShortHelp = `Explain how the version of a dependency was chosen`
+const explainLongHelp = `
+Solve the dependencies of the current project, as dep ensure would, and report
+how the solver chose the version of the given project:
+
+  * every constraint on the project and the project that imposed it
+  * the versions that were tried and rejected, and why they were rejected
+  * the version that was selected, and why it won over the others
+
+With -update, the solve is the one that "dep ensure -update <project>" would
+perform, which ignores the version of the project in Gopkg.lock. This is useful
+to find out why a dependency does not upgrade.
+
+Nothing is written to disk. With -json, the explanation is printed as a JSON
+document.
+`
+
+type explainCommand struct {
+	update	bool
+	json	bool
+}
+
+func (cmd *explainCommand) Name() string	{ return "explain" }
+func (cmd *explainCommand) Args() string	{ return "[-update] [-json] <project root>" }
+func (cmd *explainCommand) ShortHelp() string	{ return explainShortHelp }
+func (cmd *explainCommand) LongHelp() string	{ return explainLongHelp }
+func (cmd *explainCommand) Hidden() bool	{ return false }
+
+func (cmd *explainCommand) Register(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.update, "update", false, "explain the solve that updating the project would perform")
+	fs.BoolVar(&cmd.json, "json", false, "output in JSON format")
+}
+
+// explainResult is the JSON form of a gps.Explanation.
+type explainResult struct {
+	Project		string
+	Constraints	[]explainConstraint
+	LockedVersion	string	`json:",omitempty"`
+	ChangeRequested	bool
+	Downgrade	bool
+	Rejected	[]explainRejected
+	Selected	string	`json:",omitempty"`
+	Reason		string	`json:",omitempty"`
+	// SolveError is the error of the solve, if it failed.
+	SolveError	string	`json:",omitempty"`
+}
+
+type explainConstraint struct {
+	Depender	string
+	DependerVersion	string	`json:",omitempty"`
+	Constraint	string
+	Source		string	`json:",omitempty"`
+	Override	bool
+	Packages	[]string
+}
+
+type explainRejected struct {
+	Version	string
+	Reason	string
+}
+
+func (cmd *explainCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) != 1 {
+		return errors.Errorf("must provide exactly one project root")
+	}
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+
+	sm, err := ctx.SourceManager()
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	pr, err := sm.DeduceProjectRoot(args[0])
+	if err != nil {
+		return errors.Wrapf(err, "could not deduce the project root of %s", args[0])
+	}
+	if pr == p.ImportRoot {
+		return errors.Errorf("%s is the current project", pr)
+	}
+
+	params := p.MakeParams()
+	if ctx.Verbose {
+		params.TraceLogger = ctx.Err
+	}
+	if cmd.update {
+		params.ToChange = []gps.ProjectRoot{pr}
+	}
+	params.Explanation = &gps.Explanation{Project: pr}
+
+	if err := ctx.ValidateParams(sm, params); err != nil {
+		return err
+	}
+
+	solver, err := gps.Prepare(params, sm)
+	if err != nil {
+		return errors.Wrap(err, "prepare solver")
+	}
+	_, solveErr := solver.Solve(context.TODO())
+	if solveErr != nil {
+		solveErr = handleAllTheFailuresOfTheWorld(solveErr)
+	}
+
+	result := newExplainResult(params.Explanation, solveErr)
+	var buf bytes.Buffer
+	if cmd.json {
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(result); err != nil {
+			return err
+		}
+	} else {
+		writeExplainText(&buf, result, string(p.ImportRoot))
+	}
+	ctx.Out.Print(buf.String())
+
+	if solveErr != nil {
+		return solveErr
+	}
+	return nil
+}
+
+func newExplainResult(ex *gps.Explanation, solveErr error) explainResult {
+	result := explainResult{
+		Project:		string(ex.Project),
+		Constraints:		[]explainConstraint{},
+		LockedVersion:		formatVersion(ex.LockedVersion),
+		ChangeRequested:	ex.ChangeRequested,
+		Downgrade:		ex.Downgrade,
+		Rejected:		[]explainRejected{},
+		Selected:		formatVersion(ex.Selected),
+		Reason:			string(ex.Reason),
+	}
+	if solveErr != nil {
+		result.SolveError = solveErr.Error()
+	}
+	for _, c := range ex.Constraints {
+		result.Constraints = append(result.Constraints, explainConstraint{
+			Depender:		string(c.Depender),
+			DependerVersion:	formatVersion(c.DependerVersion),
+			Constraint:		c.Constraint.String(),
+			Source:			c.Source,
+			Override:		c.Override,
+			Packages:		c.Packages,
+		})
+	}
+	for _, r := range ex.Rejected {
+		result.Rejected = append(result.Rejected, explainRejected{
+			Version:	formatVersion(r.Version),
+			Reason:		r.Reason,
+		})
+	}
+	return result
+}
+
+// explainReasons describe each gps.ExplainReason for the text output.
+var explainReasons = map[gps.ExplainReason]string{
+	gps.ExplainLocked:	"the version in " + dep.LockName + " satisfies all constraints",
+	gps.ExplainPreferred:	"it is the version in the lock of a dependency on the project",
+	gps.ExplainRevision:	"the project is constrained to a single revision",
+	gps.ExplainNewest:	"it is the newest version that satisfies all constraints",
+	gps.ExplainOldest:	"it is the oldest version that satisfies all constraints",
+}
+
+func writeExplainText(buf *bytes.Buffer, result explainResult, root string) {
+	if len(result.Constraints) == 0 && len(result.Rejected) == 0 && result.Selected == "" {
+		fmt.Fprintf(buf, "(%s is not a dependency of %s)\n", result.Project, root)
+		return
+	}
+
+	fmt.Fprintf(buf, "# %s\n", result.Project)
+
+	buf.WriteString("\nConstraints:\n")
+	for _, c := range result.Constraints {
+		depender := c.Depender
+		if c.DependerVersion != "" {
+			depender += "@" + c.DependerVersion
+		} else {
+			depender += " (root)"
+		}
+		fmt.Fprintf(buf, "  %s imposed by %s", c.Constraint, depender)
+		if c.Override {
+			buf.WriteString(", overridden by root")
+		}
+		if c.Source != "" {
+			fmt.Fprintf(buf, ", source %s", c.Source)
+		}
+		fmt.Fprintf(buf, "\n    imports %s\n", strings.Join(c.Packages, ", "))
+	}
+
+	if result.LockedVersion != "" {
+		fmt.Fprintf(buf, "\nLocked version: %s", result.LockedVersion)
+		if result.ChangeRequested {
+			buf.WriteString(" (ignored because the project was allowed to change)")
+		}
+		buf.WriteString("\n")
+	}
+
+	if len(result.Rejected) > 0 {
+		buf.WriteString("\nRejected versions:\n")
+		for _, r := range result.Rejected {
+			fmt.Fprintf(buf, "  %s\n", r.Version)
+			for _, line := range strings.Split(strings.TrimSpace(r.Reason), "\n") {
+				fmt.Fprintf(buf, "    %s\n", line)
+			}
+		}
+	}
+
+	if result.Selected != "" {
+		reason := explainReasons[gps.ExplainReason(result.Reason)]
+		if result.Reason == string(gps.ExplainChanged) {
+			reason = "the project was allowed to change, and " + explainReasons[gps.ExplainNewest]
+			if result.Downgrade {
+				reason = "the project was allowed to change, and " + explainReasons[gps.ExplainOldest]
+			}
+		}
+		fmt.Fprintf(buf, "\nSelected %s because %s.\n", result.Selected, reason)
+	} else {
+		buf.WriteString("\nNo version was selected.\n")
+	}
+}
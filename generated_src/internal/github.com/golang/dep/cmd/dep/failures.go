@@ -5,7 +5,10 @@
 package amalgomated
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
 
 	"github.com/palantir/godel-dep-plugin/generated_src/internal/github.com/golang/dep/gps"
 	"github.com/pkg/errors"
@@ -19,5 +22,40 @@ func handleAllTheFailuresOfTheWorld(err error) error {
 		return nil
 	}
 
-	return errors.Wrap(err, "Solving failure")
+	return &solveFailure{err: err}
+}
+
+// solveFailure is a solving failure. Its message includes the remedies that
+// are suggested for the failure, if any.
+type solveFailure struct {
+	err error
+}
+
+func (e *solveFailure) Error() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Solving failure: %s", e.err)
+	if d, ok := gps.Diagnose(e.err); ok && len(d.Remedies) > 0 {
+		buf.WriteString("\n\nPossible remedies:")
+		for _, r := range d.Remedies {
+			fmt.Fprintf(&buf, "\n  * %s", r)
+		}
+	}
+	return buf.String()
+}
+
+func (e *solveFailure) Cause() error {
+	return errors.Cause(e.err)
+}
+
+// failureJSON returns the JSON form of the gps.Diagnostic of a failure. Errors
+// that are not solve failures are described with the gps.FailureOther code.
+func failureJSON(err error) ([]byte, error) {
+	d, ok := gps.Diagnose(err)
+	if !ok {
+		d = gps.Diagnostic{
+			Code:		gps.FailureOther,
+			Message:	err.Error(),
+		}
+	}
+	return json.MarshalIndent(d, "", "  ")
 }
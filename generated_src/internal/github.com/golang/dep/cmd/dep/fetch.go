@@ -0,0 +1,138 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package amalgomated
+
+import (
+	"github.com/palantir/godel-dep-plugin/generated_src/internal/github.com/golang/dep/amalgomated_flag"
+	"sort"
+	"sync"
+
+	"github.com/palantir/godel-dep-plugin/generated_src/internal/github.com/golang/dep"
+	"github.com/palantir/godel-dep-plugin/generated_src/internal/github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+const fetchShortHelp = `Download the sources of the locked projects into the cache`
+const fetchLongHelp = `
+Download or update the sources of all of the projects in Gopkg.lock, so that
+later commands do not have to. Sources are fetched concurrently, up to
+-parallel at a time.
+
+If DEPCACHEAGE is set, the version list of each source and the manifest, lock
+and package tree of each locked revision are also written to the persistent
+cache.
+
+Progress is reported as each project completes. A failure to fetch a project
+does not stop the others; all failures are reported at the end, and the
+command fails if there were any.
+
+The lock defaults to the Gopkg.lock of the current project, and may be a path
+to a lock file or a git revision from which Gopkg.lock is read, as with dep
+diff.
+`
+
+const defaultFetchParallelism = 8
+
+type fetchCommand struct {
+	parallel int
+}
+
+func (cmd *fetchCommand) Name() string		{ return "fetch" }
+func (cmd *fetchCommand) Args() string		{ return "[-parallel n] [lock]" }
+func (cmd *fetchCommand) ShortHelp() string	{ return fetchShortHelp }
+func (cmd *fetchCommand) LongHelp() string	{ return fetchLongHelp }
+func (cmd *fetchCommand) Hidden() bool		{ return false }
+
+func (cmd *fetchCommand) Register(fs *flag.FlagSet) {
+	fs.IntVar(&cmd.parallel, "parallel", defaultFetchParallelism, "maximum number of sources to fetch at once")
+}
+
+// fetchFailure records the failure to fetch a project.
+type fetchFailure struct {
+	lp	gps.LockedProject
+	err	error
+}
+
+func (cmd *fetchCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) > 1 {
+		return errors.Errorf("too many args (%d)", len(args))
+	}
+	if cmd.parallel < 1 {
+		return errors.New("-parallel must be at least 1")
+	}
+
+	l, err := loadLockArgs(ctx, args)
+	if err != nil {
+		return err
+	}
+
+	sm, err := ctx.SourceManager()
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	if ctx.CacheAge <= 0 {
+		ctx.Err.Println("Warning: DEPCACHEAGE is not set, so only sources are fetched and their metadata is not cached.")
+	}
+
+	lps := l.Projects()
+	sem := make(chan struct{}, cmd.parallel)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var done int
+	var failures []fetchFailure
+	for _, lp := range lps {
+		wg.Add(1)
+		go func(lp gps.LockedProject) {
+			defer wg.Done()
+			sem <- struct{}{}
+			err := fetchLockedProject(sm, lp)
+			<-sem
+
+			mu.Lock()
+			defer mu.Unlock()
+			done++
+			if err != nil {
+				failures = append(failures, fetchFailure{lp: lp, err: err})
+				ctx.Err.Printf("(%d/%d) Failed to fetch %s@%s\n", done, len(lps), lp.Ident(), lp.Version())
+			} else {
+				ctx.Err.Printf("(%d/%d) Fetched %s@%s\n", done, len(lps), lp.Ident(), lp.Version())
+			}
+		}(lp)
+	}
+	wg.Wait()
+
+	if len(failures) == 0 {
+		return nil
+	}
+	sort.Slice(failures, func(i, j int) bool {
+		return failures[i].lp.Ident().Less(failures[j].lp.Ident())
+	})
+	ctx.Err.Printf("\nFailed to fetch %d of %d projects:\n", len(failures), len(lps))
+	for _, f := range failures {
+		ctx.Err.Printf("  %s: %s\n", f.lp.Ident(), f.err)
+	}
+	return errors.New("failed to fetch some projects")
+}
+
+// fetchLockedProject syncs the source of lp and loads its version list and the
+// manifest, lock and package tree of its locked revision, which the source
+// manager caches.
+func fetchLockedProject(sm gps.SourceManager, lp gps.LockedProject) error {
+	id := lp.Ident()
+	if err := sm.SyncSourceFor(id); err != nil {
+		return err
+	}
+	if _, err := sm.ListVersions(id); err != nil {
+		return err
+	}
+	if _, _, err := sm.GetManifestAndLock(id, lp.Version(), dep.Analyzer{}); err != nil {
+		return err
+	}
+	_, err := sm.ListPackages(id, lp.Version())
+	return err
+}
@@ -0,0 +1,267 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package amalgomated
+
+import (
+	"bytes"
+	"github.com/palantir/godel-dep-plugin/generated_src/internal/github.com/golang/dep/amalgomated_flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/palantir/godel-dep-plugin/generated_src/internal/github.com/golang/dep"
+	"github.com/palantir/godel-dep-plugin/generated_src/internal/github.com/golang/dep/gps"
+	"github.com/palantir/godel-dep-plugin/generated_src/internal/github.com/golang/dep/gps/paths"
+	"github.com/pkg/errors"
+)
+
+const graphShortHelp = `Export the dependency graph of the project`
+const graphLongHelp = `
+Export the dependency graph of the current project and the projects in
+Gopkg.lock.
+
+By default, the nodes of the graph are projects, and there is an edge from each
+project to the projects whose packages it imports. With -packages, the nodes of
+the graph are packages, and there is an edge from each package to the packages
+it imports. The packages of projects with more than one package are grouped into
+clusters.
+
+With -root, only the part of the graph that is reachable from the given package
+of the current project is exported.
+
+The graph can be exported in the following formats, selected with -format:
+
+  dot      GraphViz DOT
+  mermaid  Mermaid flowchart
+  graphml  GraphML
+  json     JSON document with "nodes", "clusters" and "edges" lists
+
+Imports from tests are not part of the graph.
+`
+
+type graphCommand struct {
+	format		string
+	packages	bool
+	root		string
+}
+
+func (cmd *graphCommand) Name() string	{ return "graph" }
+func (cmd *graphCommand) Args() string {
+	return "[-format dot|mermaid|graphml|json] [-packages] [-root <import path>]"
+}
+func (cmd *graphCommand) ShortHelp() string	{ return graphShortHelp }
+func (cmd *graphCommand) LongHelp() string	{ return graphLongHelp }
+func (cmd *graphCommand) Hidden() bool		{ return false }
+
+func (cmd *graphCommand) Register(fs *flag.FlagSet) {
+	fs.StringVar(&cmd.format, "format", "dot", fmt.Sprintf("output format: one of %s", strings.Join(graphFormats(), ", ")))
+	fs.BoolVar(&cmd.packages, "packages", false, "output the package-level graph instead of the project-level graph")
+	fs.StringVar(&cmd.root, "root", "", "only output the part of the graph reachable from this package of the current project")
+}
+
+func (cmd *graphCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) > 0 {
+		return errors.Errorf("too many args (%d)", len(args))
+	}
+
+	emit, ok := graphEmitters[cmd.format]
+	if !ok {
+		return errors.Errorf("invalid format %q: must be one of %s", cmd.format, strings.Join(graphFormats(), ", "))
+	}
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+
+	if p.Lock == nil {
+		return errors.Errorf("no Gopkg.lock found. Run `dep ensure` to generate lock file")
+	}
+
+	sm, err := ctx.SourceManager()
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	pg, err := newImportGraph(p, sm)
+	if err != nil {
+		return err
+	}
+
+	if cmd.root != "" {
+		if _, ok := pg.imports[cmd.root]; !ok || pg.projectOf(cmd.root) != string(p.ImportRoot) {
+			return errors.Errorf("%s is not a package of the current project", cmd.root)
+		}
+		pg = pg.reachableFrom(cmd.root)
+	}
+
+	var g *depGraph
+	if cmd.packages {
+		g = pg.graphviz(true).packageGraph("")
+	} else {
+		g = pg.graphviz(false).projectGraph()
+	}
+
+	var buf bytes.Buffer
+	if err := emit(&buf, g); err != nil {
+		return err
+	}
+	ctx.Out.Print(buf.String())
+	return nil
+}
+
+// of the packages of the projects in the lock that are used.
+type importGraph struct {
+	// projects are the root of the current project followed by the project
+	// roots in the lock, in sorted order.
+	projects	[]string
+	// versions maps the project roots in the lock to their locked versions.
+	versions	map[string]string
+
+	imports	map[string][]string
+	// testImports maps each package of the current project to the
+	// non-standard-library packages imported by its tests.
+	testImports	map[string][]string
+}
+
+func newImportGraph(p *dep.Project, sm gps.SourceManager) (*importGraph, error) {
+	pg := &importGraph{
+		projects:	[]string{string(p.ImportRoot)},
+		versions:	make(map[string]string),
+		imports:	make(map[string][]string),
+		testImports:	make(map[string][]string),
+	}
+
+	// The reach maps omit the packages that are ignored or that have errors.
+	rm, _ := p.RootPackageTree.ToReachMap(true, true, false, p.Manifest.IgnoredPackages())
+	for ip := range rm {
+		pkg := p.RootPackageTree.Packages[ip].P
+		pg.imports[ip] = nonStandardImports(pkg.Imports)
+		pg.testImports[ip] = nonStandardImports(pkg.TestImports)
+	}
+
+	slp := p.Lock.Projects()
+	sort.Slice(slp, func(i, j int) bool {
+		return slp[i].Ident().Less(slp[j].Ident())
+	})
+	for _, lp := range slp {
+		pr := string(lp.Ident().ProjectRoot)
+		pg.projects = append(pg.projects, pr)
+		pg.versions[pr] = formatVersion(lp.Version())
+
+		ptree, err := sm.ListPackages(lp.Ident(), lp.Version())
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list packages of %s", pr)
+		}
+		drm, _ := ptree.ToReachMap(true, false, false, nil)
+		for _, pkg := range lp.Packages() {
+			ip := pr
+			if pkg != "." {
+				ip = pr + "/" + pkg
+			}
+			if _, ok := drm[ip]; ok {
+				pg.imports[ip] = nonStandardImports(ptree.Packages[ip].P.Imports)
+			}
+		}
+	}
+	return pg, nil
+}
+
+// nonStandardImports returns the provided imports that are not in the standard
+// library in sorted order.
+func nonStandardImports(imports []string) []string {
+	children := []string{}
+	for _, imp := range imports {
+		if !paths.IsStandardImportPath(imp) {
+			children = append(children, imp)
+		}
+	}
+	sort.Strings(children)
+	return children
+}
+
+// projectOf returns the project that contains the provided package, or the
+// empty string if the package is not in any of the projects of the graph. If
+// more than one project root is a prefix of the package, the longest one is
+// used.
+func (pg *importGraph) projectOf(ip string) string {
+	var project string
+	for _, pr := range pg.projects {
+		if isPathPrefix(ip, pr) && len(pr) > len(project) {
+			project = pr
+		}
+	}
+	return project
+}
+
+// reachableFrom returns the subgraph of the packages that are reachable from
+// the provided package. Test imports are not followed.
+func (pg *importGraph) reachableFrom(root string) *importGraph {
+	reached := &importGraph{
+		projects:	pg.projects,
+		versions:	pg.versions,
+		imports:	make(map[string][]string),
+	}
+
+	queue := []string{root}
+	for len(queue) > 0 {
+		ip := queue[0]
+		queue = queue[1:]
+		if _, ok := reached.imports[ip]; ok {
+			continue
+		}
+		imports, ok := pg.imports[ip]
+		if !ok {
+			continue
+		}
+		reached.imports[ip] = imports
+		queue = append(queue, imports...)
+	}
+	return reached
+}
+
+// graphviz returns the graph of the projects (and, if packages is true, of
+// their packages) that have at least one package in the importGraph.
+func (pg *importGraph) graphviz(packages bool) *graphviz {
+	byProject := make(map[string]map[string][]string)
+	for ip, imports := range pg.imports {
+		pr := pg.projectOf(ip)
+		if byProject[pr] == nil {
+			byProject[pr] = make(map[string][]string)
+		}
+		byProject[pr][ip] = imports
+	}
+
+	g := new(graphviz).New()
+	for _, pr := range pg.projects {
+		pkgs, ok := byProject[pr]
+		if !ok {
+			continue
+		}
+
+		if packages {
+			g.createSubgraph(pr, pkgs)
+			continue
+		}
+
+		// The children of a project are the packages of other projects that
+		// its packages import.
+		seen := make(map[string]bool)
+		children := []string{}
+		for _, imports := range pkgs {
+			for _, imp := range imports {
+				if !seen[imp] && pg.projectOf(imp) != pr {
+					seen[imp] = true
+					children = append(children, imp)
+				}
+			}
+		}
+		sort.Strings(children)
+		g.createNode(pr, pg.versions[pr], children)
+	}
+	return g
+}
@@ -6,7 +6,6 @@ package amalgomated
 
 import (
 	"bytes"
-	"fmt"
 	"hash/fnv"
 	"sort"
 	"strings"
@@ -14,7 +13,6 @@ import (
 
 type graphviz struct {
 	ps	[]*gvnode
-	b	bytes.Buffer
 	h	map[string]uint32
 	// clusters is a map of project name and subgraph object. This can be used
 	// to refer the subgraph by project name.
@@ -43,63 +41,48 @@ func (g graphviz) New() *graphviz {
 	return ga
 }
 
+// output writes the project relations graph if project is empty. Otherwise,
+// it writes the project-package relations graph for the provided project.
 func (g *graphviz) output(project string) bytes.Buffer {
+	var b bytes.Buffer
 	if project == "" {
-		// Project relations graph.
-		g.b.WriteString("digraph {\n\tnode [shape=box];")
-
-		for _, gvp := range g.ps {
-			// Create node string
-			g.b.WriteString(fmt.Sprintf("\n\t%d [label=\"%s\"];", gvp.hash(), gvp.label()))
-		}
-
-		g.createProjectRelations()
+		emitDOT(&b, g.projectGraph())
 	} else {
-		// Project-Package relations graph.
-		g.b.WriteString("digraph {\n\tnode [shape=box];\n\tcompound=true;\n\tedge [minlen=2];")
-
-		// Declare all the nodes with labels.
-		for _, gvp := range g.ps {
-			g.b.WriteString(fmt.Sprintf("\n\t%d [label=\"%s\"];", gvp.hash(), gvp.label()))
-		}
-
-		// Sort the clusters for a consistent output.
-		clusters := sortClusters(g.clusters)
-
-		// Declare all the subgraphs with labels.
-		for _, gsg := range clusters {
-			g.b.WriteString(fmt.Sprintf("\n\tsubgraph cluster_%d {", gsg.index))
-			g.b.WriteString(fmt.Sprintf("\n\t\tlabel = \"%s\";", gsg.project))
-
-			nhashes := []string{}
-			for _, pkg := range gsg.packages {
-				nhashes = append(nhashes, fmt.Sprint(g.h[pkg]))
-			}
-
-			g.b.WriteString(fmt.Sprintf("\n\t\t%s;", strings.Join(nhashes, " ")))
-			g.b.WriteString("\n\t}")
-		}
+		emitDOT(&b, g.packageGraph(project))
+	}
+	return b
+}
 
-		g.createProjectPackageRelations(project, clusters)
+// projectGraph returns the project-level view of the graph. There is an edge
+// from each node to each of the nodes that contain its children.
+func (g *graphviz) projectGraph() *depGraph {
+	dg := &depGraph{
+		View: projectGraphView,
+	}
+	for _, gvp := range g.ps {
+		dg.Nodes = append(dg.Nodes, depGraphNode{ID: gvp.project, Version: gvp.version})
 	}
 
-	g.b.WriteString("\n}\n")
-	return g.b
-}
+	// Sort the node names so that the relations are created in a consistent
+	// order.
+	names := []string{}
+	for pr := range g.h {
+		names = append(names, pr)
+	}
+	sort.Strings(names)
 
-func (g *graphviz) createProjectRelations() {
 	// Store relations to avoid duplication
-	rels := make(map[string]bool)
+	rels := make(map[depGraphEdge]bool)
 
 	// Create relations
 	for _, dp := range g.ps {
 		for _, bsc := range dp.children {
-			for pr, hsh := range g.h {
+			for _, pr := range names {
 				if isPathPrefix(bsc, pr) {
-					r := fmt.Sprintf("\n\t%d -> %d", g.h[dp.project], hsh)
+					r := depGraphEdge{From: dp.project, To: pr}
 
 					if _, ex := rels[r]; !ex {
-						g.b.WriteString(r + ";")
+						dg.Edges = append(dg.Edges, r)
 						rels[r] = true
 					}
 
@@ -107,60 +90,58 @@ func (g *graphviz) createProjectRelations() {
 			}
 		}
 	}
+	return dg
 }
 
-func (g *graphviz) createProjectPackageRelations(project string, clusters []*gvsubgraph) {
-	// This function takes a child package/project, target project, subgraph meta, from
-	// and to of the edge and write a relation.
-	linkRelation := func(child, project string, meta []string, from, to uint32) {
-		if child == project {
-			// Check if it's a cluster.
-			target, ok := g.clusters[project]
-			if ok {
-				// It's a cluster. Point to the Project Root. Use lhead.
-				meta = append(meta, fmt.Sprintf("lhead=cluster_%d", target.index))
-				// When the head points to a cluster root, use the first
-				// node in the cluster as to.
-				to = g.h[target.packages[0]]
-			}
+// packageGraph returns the package-level view of the graph. If project is
+// non-empty, only the relations that point to the packages of that project
+// are included.
+func (g *graphviz) packageGraph(project string) *depGraph {
+	dg := &depGraph{
+		View: packageGraphView,
+	}
+
+	// Sort the clusters for a consistent output.
+	clusters := sortClusters(g.clusters)
+
+	clustered := make(map[string]string)
+	for _, gsg := range clusters {
+		dg.Clusters = append(dg.Clusters, depGraphCluster{ID: gsg.project, Packages: gsg.packages})
+		for _, pkg := range gsg.packages {
+			clustered[pkg] = gsg.project
 		}
+	}
+	for _, gvp := range g.ps {
+		dg.Nodes = append(dg.Nodes, depGraphNode{ID: gvp.project, Version: gvp.version, Cluster: clustered[gvp.project]})
+	}
 
-		if len(meta) > 0 {
-			g.b.WriteString(fmt.Sprintf("\n\t%d -> %d [%s];", from, to, strings.Join(meta, " ")))
-		} else {
-			g.b.WriteString(fmt.Sprintf("\n\t%d -> %d;", from, to))
+	// This function takes a child package/project and the from of the edge
+	// and adds a relation if the child is a node or cluster of the graph.
+	linkRelation := func(child, from string) {
+		if project != "" && !isPathPrefix(child, project) {
+			// Only if it points to the target project, proceed further.
+			return
+		}
+		if _, ok := g.h[child]; !ok {
+			return
 		}
+		dg.Edges = append(dg.Edges, depGraphEdge{From: from, To: child})
 	}
 
 	// Create relations from nodes.
 	for _, node := range g.ps {
 		for _, child := range node.children {
-			// Only if it points to the target project, proceed further.
-			if isPathPrefix(child, project) {
-				meta := []string{}
-				from := g.h[node.project]
-				to := g.h[child]
-
-				linkRelation(child, project, meta, from, to)
-			}
+			linkRelation(child, node.project)
 		}
 	}
 
 	// Create relations from clusters.
 	for _, cluster := range clusters {
 		for _, child := range cluster.children {
-			// Only if it points to the target project, proceed further.
-			if isPathPrefix(child, project) {
-				meta := []string{fmt.Sprintf("ltail=cluster_%d", cluster.index)}
-				// When the tail is from a cluster, use the first node in the
-				// cluster as from.
-				from := g.h[cluster.packages[0]]
-				to := g.h[child]
-
-				linkRelation(child, project, meta, from, to)
-			}
+			linkRelation(child, cluster.project)
 		}
 	}
+	return dg
 }
 
 func (g *graphviz) createNode(project, version string, children []string) {
@@ -180,16 +161,6 @@ func (dp gvnode) hash() uint32 {
 	return h.Sum32()
 }
 
-func (dp gvnode) label() string {
-	label := []string{dp.project}
-
-	if dp.version != "" {
-		label = append(label, dp.version)
-	}
-
-	return strings.Join(label, "\\n")
-}
-
 // isPathPrefix ensures that the literal string prefix is a path tree match and
 // guards against possibilities like this:
 //
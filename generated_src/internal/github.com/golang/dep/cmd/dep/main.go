@@ -22,11 +22,15 @@ import (
 
 	"github.com/palantir/godel-dep-plugin/generated_src/internal/github.com/golang/dep"
 	"github.com/palantir/godel-dep-plugin/generated_src/internal/github.com/golang/dep/internal/fs"
+	"github.com/pkg/errors"
 )
 
 var (
 	successExitCode	= 0
 	errorExitCode	= 1
+	// usageExitCode is returned when the command or its flags are invalid,
+	// so that callers can distinguish usage errors from failed operations.
+	usageExitCode	= 2
 )
 
 type command interface {
@@ -73,6 +77,7 @@ func AmalgomatedMain() {
 		Args:		args,
 		Stdout:		os.Stdout,
 		Stderr:		os.Stderr,
+		Stdin:		os.Stdin,
 		WorkingDir:	wd,
 		Env:		os.Environ(),
 	}
@@ -95,6 +100,7 @@ type Config struct {
 	Args		[]string	// Command-line arguments, starting with the program name.
 	Env		[]string	// Environment variables
 	Stdout, Stderr	io.Writer	// Log output
+	Stdin		io.Reader	// Input, read by commands given "-" as an input file
 }
 
 // Run executes a configuration and returns an exit code.
@@ -104,7 +110,11 @@ func (c *Config) Run() int {
 	cmdName, printCommandHelp, exit := parseArgs(c.Args)
 	if exit {
 		fprintUsage(c.Stderr)
-		return errorExitCode
+		// Help that was asked for is not a usage error.
+		if len(c.Args) > 1 {
+			return successExitCode
+		}
+		return usageExitCode
 	}
 
 	// 'dep help documentation' generates doc.go.
@@ -162,52 +172,24 @@ func (c *Config) Run() int {
 
 			if printCommandHelp {
 				flags.Usage()
-				return errorExitCode
+				return successExitCode
 			}
 
 			// Parse the flags the user gave us.
 			// flag package automatically prints usage and error message in err != nil
 			// or if '-h' flag provided
-			if err := flags.Parse(c.Args[2:]); err != nil {
-				return errorExitCode
-			}
-
-			// Cachedir is loaded from env if present. `$GOPATH/pkg/dep` is used as the
-			// default cache location.
-			cachedir := getEnv(c.Env, "DEPCACHEDIR")
-			if cachedir != "" {
-				if err := fs.EnsureDir(cachedir, 0777); err != nil {
-					errLogger.Printf(
-						"dep: $DEPCACHEDIR set to an invalid or inaccessible path: %q\n", cachedir,
-					)
-					errLogger.Printf("dep: failed to ensure cache directory: %v\n", err)
-					return errorExitCode
-				}
-			}
-
-			var cacheAge time.Duration
-			if env := getEnv(c.Env, "DEPCACHEAGE"); env != "" {
-				var err error
-				cacheAge, err = time.ParseDuration(env)
-				if err != nil {
-					errLogger.Printf("dep: failed to parse $DEPCACHEAGE duration %q: %v\n", env, err)
-					return errorExitCode
-				}
+			if err := flags.Parse(c.Args[2:]); err == flag.ErrHelp {
+				return successExitCode
+			} else if err != nil {
+				return usageExitCode
 			}
 
-			// Set up dep context.
-			ctx := &dep.Ctx{
-				Out:		outLogger,
-				Err:		errLogger,
-				Verbose:	verbose,
-				DisableLocking:	getEnv(c.Env, "DEPNOLOCK") != "",
-				Cachedir:	cachedir,
-				CacheAge:	cacheAge,
+			ctx, err := c.newContext(outLogger, errLogger, verbose)
+			if err != nil {
+				errLogger.Printf("%v\n", err)
+				return errorExitCode
 			}
 
-			GOPATHS := filepath.SplitList(getEnv(c.Env, "GOPATH"))
-			ctx.SetPaths(c.WorkingDir, GOPATHS...)
-
 			// Run the command with the post-flag-processing args.
 			if err := cmd.Run(ctx, flags.Args()); err != nil {
 				if _, ok := err.(silentfail); !ok {
@@ -223,7 +205,47 @@ func (c *Config) Run() int {
 
 	errLogger.Printf("dep: %s: no such command\n", cmdName)
 	fprintUsage(c.Stderr)
-	return errorExitCode
+	return usageExitCode
+}
+
+// newContext creates the dep context for the configuration. Cachedir is
+// loaded from env if present. `$GOPATH/pkg/dep` is used as the default cache
+// location.
+func (c *Config) newContext(outLogger, errLogger *log.Logger, verbose bool) (*dep.Ctx, error) {
+	cachedir := getEnv(c.Env, "DEPCACHEDIR")
+	if cachedir != "" {
+		if err := fs.EnsureDir(cachedir, 0777); err != nil {
+			return nil, errors.Errorf("dep: $DEPCACHEDIR set to an invalid or inaccessible path: %q\ndep: failed to ensure cache directory: %v", cachedir, err)
+		}
+	}
+
+	var cacheAge time.Duration
+	if env := getEnv(c.Env, "DEPCACHEAGE"); env != "" {
+		var err error
+		cacheAge, err = time.ParseDuration(env)
+		if err != nil {
+			return nil, errors.Errorf("dep: failed to parse $DEPCACHEAGE duration %q: %v", env, err)
+		}
+	}
+
+	// Set up dep context.
+	ctx := &dep.Ctx{
+		Out:		outLogger,
+		Err:		errLogger,
+		Verbose:	verbose,
+		DisableLocking:	getEnv(c.Env, "DEPNOLOCK") != "",
+		Cachedir:	cachedir,
+		CacheAge:	cacheAge,
+		Offline:	getEnv(c.Env, "DEPOFFLINE") != "",
+		SharedCachedir:	getEnv(c.Env, "DEPSHAREDCACHEDIR"),
+		DigestCache:	getEnv(c.Env, "DEPDIGESTCACHE") != "",
+		Stdin:		c.Stdin,
+		Stdout:		c.Stdout,
+	}
+
+	GOPATHS := filepath.SplitList(getEnv(c.Env, "GOPATH"))
+	ctx.SetPaths(c.WorkingDir, GOPATHS...)
+	return ctx, nil
 }
 
 // Build the list of available commands.
@@ -238,6 +260,14 @@ func commandList() []command {
 		&pruneCommand{},
 		&versionCommand{},
 		&checkCommand{},
+		&graphCommand{},
+		&whyCommand{},
+		&explainCommand{},
+		&outdatedCommand{},
+		&diffCommand{},
+		&changelogCommand{},
+		&cacheCommand{},
+		&fetchCommand{},
 	}
 }
 
@@ -0,0 +1,397 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package amalgomated
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/palantir/godel-dep-plugin/generated_src/internal/github.com/golang/dep/amalgomated_flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/Masterminds/semver"
+	"github.com/palantir/godel-dep-plugin/generated_src/internal/github.com/golang/dep"
+	"github.com/palantir/godel-dep-plugin/generated_src/internal/github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+const outdatedShortHelp = `Report available updates and how risky they are`
+const outdatedLongHelp = `
+Report the versions that the projects in Gopkg.lock could be updated to. For
+each project, two updates are considered:
+
+  WANTED  the newest version allowed by the constraint on the project in
+          Gopkg.toml. Projects without a constraint are unconstrained,
+          unless they are locked to a branch, in which case the newest
+          revision of that branch is wanted.
+  LATEST  the newest version overall, regardless of constraints.
+
+Each update is classified by its impact relative to the locked version:
+
+  none      the locked revision is already the newest one
+  patch     a semver patch release
+  minor     a semver minor release
+  major     a semver major release
+  revision  a newer revision of the same branch or version, or of a project
+            that is locked to a plain revision
+  branch    a move to a different branch or non-semver version
+
+BEHIND is the number of commits that the update adds to the locked revision,
+counted from the repository in the local source cache. It is unknown for
+sources that do not support listing commits. Updates that are not fast-forward
+changes also drop commits; those are not counted.
+
+Only projects with at least one update are reported, unless -all is given.
+Gopkg.lock is read as is; no solving is performed, so the updates are not
+guaranteed to be compatible with the rest of the dependencies. Projects are
+checked concurrently, up to -parallel at a time.
+`
+
+const (
+	outdatedFormatTable	= "table"
+	outdatedFormatJSON	= "json"
+	outdatedFormatMarkdown	= "markdown"
+)
+
+// defaultOutdatedParallelism is the default number of projects that are
+// checked at once. Checking a project mostly waits on the network to list the
+// versions of its source.
+const defaultOutdatedParallelism = 8
+
+// Impacts of an update, from the least to the most risky.
+const (
+	impactNone	= "none"
+	impactRevision	= "revision"
+	impactPatch	= "patch"
+	impactMinor	= "minor"
+	impactMajor	= "major"
+	impactBranch	= "branch"
+)
+
+type outdatedCommand struct {
+	format		string
+	all		bool
+	parallel	int
+}
+
+func (cmd *outdatedCommand) Name() string	{ return "outdated" }
+func (cmd *outdatedCommand) Args() string {
+	return "[-format table|json|markdown] [-all] [-parallel n]"
+}
+func (cmd *outdatedCommand) ShortHelp() string	{ return outdatedShortHelp }
+func (cmd *outdatedCommand) LongHelp() string	{ return outdatedLongHelp }
+func (cmd *outdatedCommand) Hidden() bool	{ return false }
+
+func (cmd *outdatedCommand) Register(fs *flag.FlagSet) {
+	fs.StringVar(&cmd.format, "format", outdatedFormatTable, "output format: table, json or markdown")
+	fs.BoolVar(&cmd.all, "all", false, "also report projects that are up to date")
+	fs.IntVar(&cmd.parallel, "parallel", defaultOutdatedParallelism, "maximum number of projects to check at once")
+}
+
+// outdatedProject is the report for a single locked project.
+type outdatedProject struct {
+	ProjectRoot	string
+	Source		string	`json:",omitempty"`
+	Constraint	string
+	Locked		outdatedVersion
+	// Wanted and Latest are nil if no version of the project qualifies.
+	Wanted	*outdatedUpdate
+	Latest	*outdatedUpdate
+	// Error is set if the versions of the project could not be listed.
+	Error	string	`json:",omitempty"`
+}
+
+type outdatedVersion struct {
+	Version		string	`json:",omitempty"`
+	Branch		string	`json:",omitempty"`
+	Revision	string
+}
+
+type outdatedUpdate struct {
+	outdatedVersion
+	Impact	string
+	// CommitsBehind is nil if the commits could not be counted.
+	CommitsBehind	*int
+}
+
+func (p outdatedProject) upToDate() bool {
+	return p.Error == "" &&
+		(p.Wanted == nil || p.Wanted.Impact == impactNone) &&
+		(p.Latest == nil || p.Latest.Impact == impactNone)
+}
+
+func (cmd *outdatedCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) > 0 {
+		return errors.Errorf("too many args (%d)", len(args))
+	}
+	switch cmd.format {
+	case outdatedFormatTable, outdatedFormatJSON, outdatedFormatMarkdown:
+	default:
+		return errors.Errorf("invalid format %q: must be one of %s, %s or %s", cmd.format, outdatedFormatTable, outdatedFormatJSON, outdatedFormatMarkdown)
+	}
+	if cmd.parallel < 1 {
+		return errors.New("-parallel must be at least 1")
+	}
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+	if p.Lock == nil {
+		return errors.Errorf("no %s found in %s", dep.LockName, p.AbsRoot)
+	}
+
+	sm, err := ctx.SourceManager()
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	lps := p.Lock.Projects()
+	reports := make([]outdatedProject, len(lps))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < cmd.parallel && w < len(lps); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				reports[i] = newOutdatedProject(ctx, sm, p.Manifest, lps[i])
+			}
+		}()
+	}
+	for i := range lps {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	var projects []outdatedProject
+	for _, r := range reports {
+		if cmd.all || !r.upToDate() {
+			projects = append(projects, r)
+		}
+	}
+
+	var buf bytes.Buffer
+	switch cmd.format {
+	case outdatedFormatJSON:
+		if projects == nil {
+			projects = []outdatedProject{}
+		}
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(projects); err != nil {
+			return err
+		}
+	case outdatedFormatMarkdown:
+		writeOutdatedMarkdown(&buf, projects)
+	default:
+		writeOutdatedTable(&buf, projects)
+	}
+	ctx.Out.Print(buf.String())
+
+	for _, r := range reports {
+		if r.Error != "" {
+			return errors.New("failed to list the versions of some projects")
+		}
+	}
+	return nil
+}
+
+func newOutdatedProject(ctx *dep.Ctx, sm *gps.SourceMgr, m *dep.Manifest, lp gps.LockedProject) outdatedProject {
+	id := lp.Ident()
+	locked, lockedRev := splitLockedVersion(lp.Version())
+	op := outdatedProject{
+		ProjectRoot:	string(id.ProjectRoot),
+		Source:		id.Source,
+		Locked:		newOutdatedVersion(locked, lockedRev),
+	}
+
+	c := outdatedConstraint(m, id.ProjectRoot, locked)
+	op.Constraint = c.String()
+
+	vl, err := sm.ListVersions(id)
+	if err != nil {
+		op.Error = err.Error()
+		ctx.Err.Printf("Unable to list the versions of %s: %s\n", id.ProjectRoot, err)
+		return op
+	}
+	gps.SortPairedForUpgrade(vl)
+
+	// Both updates usually share their target, so count the commits once per
+	// revision.
+	behind := make(map[gps.Revision]*int)
+	newUpdate := func(v gps.PairedVersion) *outdatedUpdate {
+		if _, has := behind[v.Revision()]; !has {
+			behind[v.Revision()] = countCommitsBehind(ctx, sm, id, lockedRev, v.Revision())
+		}
+		return &outdatedUpdate{
+			outdatedVersion:	newOutdatedVersion(v.Unpair(), v.Revision()),
+			Impact:			updateImpact(locked, lockedRev, v),
+			CommitsBehind:		behind[v.Revision()],
+		}
+	}
+
+	if len(vl) > 0 {
+		op.Latest = newUpdate(vl[0])
+	}
+	for _, v := range vl {
+		if c.Matches(v) {
+			op.Wanted = newUpdate(v)
+			break
+		}
+	}
+	return op
+}
+
+// splitLockedVersion returns the version and the revision of a locked project.
+// The version is nil if the project is locked to a plain revision.
+func splitLockedVersion(v gps.Version) (gps.UnpairedVersion, gps.Revision) {
+	switch tv := v.(type) {
+	case gps.PairedVersion:
+		return tv.Unpair(), tv.Revision()
+	case gps.Revision:
+		return nil, tv
+	case gps.UnpairedVersion:
+		return tv, ""
+	}
+	return nil, ""
+}
+
+func newOutdatedVersion(v gps.UnpairedVersion, r gps.Revision) outdatedVersion {
+	ov := outdatedVersion{Revision: string(r)}
+	if v != nil {
+		if v.Type() == gps.IsBranch {
+			ov.Branch = v.String()
+		} else {
+			ov.Version = v.String()
+		}
+	}
+	return ov
+}
+
+// outdatedConstraint returns the constraint that determines the wanted version
+// of a project.
+func outdatedConstraint(m *dep.Manifest, pr gps.ProjectRoot, locked gps.UnpairedVersion) gps.Constraint {
+	if pp, has := m.Ovr[pr]; has && pp.Constraint != nil {
+		return pp.Constraint
+	}
+	if pp, has := m.Constraints[pr]; has && pp.Constraint != nil {
+		return pp.Constraint
+	}
+	if locked != nil && locked.Type() == gps.IsBranch {
+		return locked
+	}
+	return gps.Any()
+}
+
+// updateImpact classifies the update of a project from the locked version and
+// revision to v.
+func updateImpact(locked gps.UnpairedVersion, lockedRev gps.Revision, v gps.PairedVersion) string {
+	if v.Revision() == lockedRev {
+		return impactNone
+	}
+	to := v.Unpair()
+	if locked == nil {
+		return impactRevision
+	}
+	if locked.Type() == gps.IsSemver && to.Type() == gps.IsSemver {
+		from, ferr := semver.NewVersion(locked.String())
+		tov, terr := semver.NewVersion(to.String())
+		if ferr == nil && terr == nil {
+			switch {
+			case from.Major() != tov.Major():
+				return impactMajor
+			case from.Minor() != tov.Minor():
+				return impactMinor
+			default:
+				return impactPatch
+			}
+		}
+	}
+	if locked.Type() == to.Type() && locked.String() == to.String() {
+		return impactRevision
+	}
+	return impactBranch
+}
+
+// countCommitsBehind returns the number of commits reachable from to but not
+// from from, or nil if they could not be counted.
+func countCommitsBehind(ctx *dep.Ctx, sm *gps.SourceMgr, id gps.ProjectIdentifier, from, to gps.Revision) *int {
+	if from == to {
+		n := 0
+		return &n
+	}
+	commits, err := sm.ListCommits(id, from, to)
+	if err != nil {
+		if ctx.Verbose {
+			ctx.Err.Printf("Unable to count the commits between %s and %s in %s: %s\n", from, to, id.ProjectRoot, err)
+		}
+		return nil
+	}
+	n := len(commits)
+	return &n
+}
+
+func (v outdatedVersion) String() string {
+	switch {
+	case v.Version != "":
+		return v.Version
+	case v.Branch != "":
+		return "branch " + v.Branch
+	}
+	return formatVersion(gps.Revision(v.Revision))
+}
+
+func (u *outdatedUpdate) cells() []string {
+	if u == nil {
+		return []string{"-", "-", "-"}
+	}
+	behind := "?"
+	if u.CommitsBehind != nil {
+		behind = strconv.Itoa(*u.CommitsBehind)
+	}
+	return []string{u.String(), u.Impact, behind}
+}
+
+func (p outdatedProject) cells() []string {
+	cells := []string{p.ProjectRoot, p.Constraint, p.Locked.String()}
+	if p.Error != "" {
+		return append(cells, "unknown", "-", "-", "unknown", "-", "-")
+	}
+	cells = append(cells, p.Wanted.cells()...)
+	return append(cells, p.Latest.cells()...)
+}
+
+func writeOutdatedTable(buf *bytes.Buffer, projects []outdatedProject) {
+	w := tabwriter.NewWriter(buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PROJECT\tCONSTRAINT\tLOCKED\tWANTED\tIMPACT\tBEHIND\tLATEST\tIMPACT\tBEHIND")
+	for _, p := range projects {
+		fmt.Fprintln(w, strings.Join(p.cells(), "\t"))
+	}
+	w.Flush()
+}
+
+func writeOutdatedMarkdown(buf *bytes.Buffer, projects []outdatedProject) {
+	if len(projects) == 0 {
+		buf.WriteString("All dependencies are up to date.\n")
+		return
+	}
+	buf.WriteString("| Project | Constraint | Locked | Wanted | Impact | Behind | Latest | Impact | Behind |\n")
+	buf.WriteString("| --- | --- | --- | --- | --- | --- | --- | --- | --- |\n")
+	for _, p := range projects {
+		cells := p.cells()
+		for i, c := range cells {
+			cells[i] = strings.Replace(c, "|", "\\|", -1)
+		}
+		cells[0] = "`" + cells[0] + "`"
+		fmt.Fprintf(buf, "| %s |\n", strings.Join(cells, " | "))
+	}
+}
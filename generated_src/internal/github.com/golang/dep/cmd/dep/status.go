@@ -459,8 +459,6 @@ func (cmd *statusCommand) Run(ctx *dep.Ctx, args []string) error {
 	var buf bytes.Buffer
 	var out outputter
 	switch {
-	case cmd.missing:
-		return errors.Errorf("not implemented")
 	case cmd.json:
 		out = &jsonOutput{
 			w: &buf,
@@ -509,6 +507,12 @@ func (cmd *statusCommand) Run(ctx *dep.Ctx, args []string) error {
 		return err
 	}
 
+	if cmd.missing {
+		err = cmd.runMissing(ctx, out, p, sm)
+		ctx.Out.Print(buf.String())
+		return err
+	}
+
 	_, errCount, runerr := cmd.runStatusAll(ctx, out, p, sm)
 	if runerr != nil {
 		switch runerr {
@@ -542,6 +546,86 @@ func (cmd *statusCommand) Run(ctx *dep.Ctx, args []string) error {
 	return runerr
 }
 
+// Status determines the status of the dependencies of the project in the
+// configuration's working directory in the same manner as "dep status -detail"
+// and returns the statuses rather than printing them. The statuses are ordered
+// by project root. The configuration's Args are ignored.
+//
+// If the status of the latest version of some projects could not be
+// determined, the statuses are still returned and HasError reports the
+// affected projects. If Gopkg.lock is out of sync with the imports of the
+// project, the projects that are missing from Gopkg.lock are returned along
+// with an error.
+func (c *Config) Status() ([]*DetailStatus, []*MissingStatus, error) {
+	ctx, err := c.newContext(log.New(c.Stdout, "", 0), log.New(c.Stderr, "", 0), false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return nil, nil, err
+	}
+	if p.Lock == nil {
+		return nil, nil, errors.Errorf("no Gopkg.lock found. Run `dep ensure` to generate lock file")
+	}
+
+	sm, err := ctx.SourceManager()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer sm.Release()
+
+	if err := dep.ValidateProjectRoots(ctx, p.Manifest, sm); err != nil {
+		return nil, nil, err
+	}
+
+	cmd := &statusCommand{detail: true}
+	out := &statusCollector{}
+	if _, _, err := cmd.runStatusAll(ctx, out, p, sm); err != nil {
+		switch err {
+		case errFailedUpdate:
+			// Reported per project through HasError.
+		case errInputDigestMismatch:
+			return nil, out.missing, errors.New("Gopkg.lock is out of sync with imports and/or Gopkg.toml")
+		default:
+			return nil, nil, err
+		}
+	}
+	return out.detail, out.missing, nil
+}
+
+// statusCollector is an outputter that collects detail and missing statuses
+// instead of writing them.
+type statusCollector struct {
+	detail	[]*DetailStatus
+	missing	[]*MissingStatus
+}
+
+func (out *statusCollector) BasicHeader() error	{ return nil }
+
+func (out *statusCollector) BasicLine(*BasicStatus) error	{ return nil }
+
+func (out *statusCollector) BasicFooter() error	{ return nil }
+
+func (out *statusCollector) DetailHeader(*dep.SolveMeta) error	{ return nil }
+
+func (out *statusCollector) DetailLine(ds *DetailStatus) error {
+	out.detail = append(out.detail, ds)
+	return nil
+}
+
+func (out *statusCollector) DetailFooter(*dep.SolveMeta) error	{ return nil }
+
+func (out *statusCollector) MissingHeader() error	{ return nil }
+
+func (out *statusCollector) MissingLine(ms *MissingStatus) error {
+	out.missing = append(out.missing, ms)
+	return nil
+}
+
+func (out *statusCollector) MissingFooter() error	{ return nil }
+
 func (cmd *statusCommand) validateFlags() error {
 	// Operating mode flags.
 	var opModes []string
@@ -848,6 +932,17 @@ func (bs *BasicStatus) getConsolidatedLatest(revSize uint8) string {
 	return latest
 }
 
+// HasOverride returns true if the constraint of the status is an override.
+func (bs *BasicStatus) HasOverride() bool {
+	return bs.hasOverride
+}
+
+// HasError returns true if the latest version of the project could not be
+// determined.
+func (bs *BasicStatus) HasError() bool {
+	return bs.hasError
+}
+
 func (ds *DetailStatus) getPruneOpts() string {
 	return (ds.PruneOpts & ^gps.PruneNestedVendorDirs).String()
 }
@@ -1126,7 +1221,33 @@ func (cmd *statusCommand) runStatusAll(ctx *dep.Ctx, out outputter, p *dep.Proje
 		return false, errCount, err
 	}
 
-	rm, _ := ptree.ToReachMap(true, true, false, p.Manifest.IgnoredPackages())
+	missing, err := missingStatuses(ctx, p, sm, slp)
+	if err != nil {
+		return false, 0, err
+	}
+	if err := missingOutputAll(out, missing); err != nil {
+		return false, 0, err
+	}
+
+	// We are here because of an input-digest mismatch. Return error.
+	return len(missing) > 0, 0, errInputDigestMismatch
+}
+
+// runMissing outputs the projects that are imported by the current project,
+// but are missing from the lock.
+func (cmd *statusCommand) runMissing(ctx *dep.Ctx, out outputter, p *dep.Project, sm gps.SourceManager) error {
+	missing, err := missingStatuses(ctx, p, sm, p.Lock.Projects())
+	if err != nil {
+		return err
+	}
+	return missingOutputAll(out, missing)
+}
+
+// missingStatuses returns the projects that contain packages that are imported
+// by the current project, but that are not in the provided project list. The
+// returned statuses are sorted by project root.
+func missingStatuses(ctx *dep.Ctx, p *dep.Project, sm gps.SourceManager, slp []gps.LockedProject) ([]*MissingStatus, error) {
+	rm, _ := p.RootPackageTree.ToReachMap(true, true, false, p.Manifest.IgnoredPackages())
 
 	external := rm.FlattenFn(paths.IsStandardImportPath)
 	roots := make(map[gps.ProjectRoot][]string, len(external))
@@ -1158,13 +1279,10 @@ func (cmd *statusCommand) runStatusAll(ctx *dep.Ctx, out outputter, p *dep.Proje
 			ctx.Err.Printf("\t%s: %s\n", fail.ex, fail.err.Error())
 		}
 
-		return false, 0, errors.New("address issues with undeducible import paths to get more status information")
-	}
-
-	if err = out.MissingHeader(); err != nil {
-		return false, 0, err
+		return nil, errors.New("address issues with undeducible import paths to get more status information")
 	}
 
+	var missing []*MissingStatus
 outer:
 	for root, pkgs := range roots {
 		// TODO also handle the case where the project is present, but there
@@ -1175,18 +1293,26 @@ outer:
 			}
 		}
 
-		hasMissingPkgs = true
-		err := out.MissingLine(&MissingStatus{ProjectRoot: string(root), MissingPackages: pkgs})
-		if err != nil {
-			return false, 0, err
-		}
-	}
-	if err = out.MissingFooter(); err != nil {
-		return false, 0, err
+		missing = append(missing, &MissingStatus{ProjectRoot: string(root), MissingPackages: pkgs})
 	}
+	sort.Slice(missing, func(i, j int) bool {
+		return missing[i].ProjectRoot < missing[j].ProjectRoot
+	})
+	return missing, nil
+}
 
-	// We are here because of an input-digest mismatch. Return error.
-	return hasMissingPkgs, 0, errInputDigestMismatch
+// missingOutputAll takes an outputter and a list of *MissingStatus and uses
+// the outputter to output the missing header, body lines and footer.
+func missingOutputAll(out outputter, missing []*MissingStatus) error {
+	if err := out.MissingHeader(); err != nil {
+		return err
+	}
+	for _, ms := range missing {
+		if err := out.MissingLine(ms); err != nil {
+			return err
+		}
+	}
+	return out.MissingFooter()
 }
 
 // basicOutputAll takes an outputter, a project list, and a map of ProjectRoot to *BasicStatus and
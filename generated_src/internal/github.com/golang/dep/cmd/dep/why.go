@@ -0,0 +1,211 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package amalgomated
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/palantir/godel-dep-plugin/generated_src/internal/github.com/golang/dep/amalgomated_flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/palantir/godel-dep-plugin/generated_src/internal/github.com/golang/dep"
+	"github.com/pkg/errors"
+)
+
+const whyShortHelp = `Explain why a package is a dependency of the project`
+const whyLongHelp = `
+Print the shortest import chains from the packages of the current project to the
+given package. If the import path is a project root, the chains end at the first
+package of that project.
+
+One chain is printed for every package of the current project that imports the
+target, directly or transitively, without going through another package of the
+current project. Chains that exist only because of the imports of tests of the
+current project are marked as test-only.
+
+With -json, the chains are printed as a JSON document.
+`
+
+type whyCommand struct {
+	json bool
+}
+
+func (cmd *whyCommand) Name() string		{ return "why" }
+func (cmd *whyCommand) Args() string		{ return "[-json] <import path>" }
+func (cmd *whyCommand) ShortHelp() string	{ return whyShortHelp }
+func (cmd *whyCommand) LongHelp() string	{ return whyLongHelp }
+func (cmd *whyCommand) Hidden() bool		{ return false }
+
+func (cmd *whyCommand) Register(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.json, "json", false, "output in JSON format")
+}
+
+// whyResult is the result of the why command.
+type whyResult struct {
+	Target	string
+	Chains	[]importChain
+}
+
+// the target package.
+type importChain struct {
+	// Packages are the packages of the chain, starting with the package of the
+	// current project and ending with the target package.
+	Packages	[]string
+	// TestOnly is true if the first import of the chain is from the tests of
+	// the first package.
+	TestOnly	bool
+}
+
+func (cmd *whyCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) != 1 {
+		return errors.Errorf("must provide exactly one import path")
+	}
+	target := args[0]
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+
+	if p.Lock == nil {
+		return errors.Errorf("no Gopkg.lock found. Run `dep ensure` to generate lock file")
+	}
+
+	sm, err := ctx.SourceManager()
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	pg, err := newImportGraph(p, sm)
+	if err != nil {
+		return err
+	}
+
+	result := whyResult{
+		Target:	target,
+		Chains:	pg.importChains(target),
+	}
+
+	var buf bytes.Buffer
+	if cmd.json {
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(result); err != nil {
+			return err
+		}
+	} else {
+		writeWhyText(&buf, result, string(p.ImportRoot))
+	}
+	ctx.Out.Print(buf.String())
+	return nil
+}
+
+func writeWhyText(buf *bytes.Buffer, result whyResult, root string) {
+	if len(result.Chains) == 0 {
+		fmt.Fprintf(buf, "(%s is not imported by any package of %s)\n", result.Target, root)
+		return
+	}
+	for i, chain := range result.Chains {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		if chain.TestOnly {
+			fmt.Fprintf(buf, "# %s (test imports only)\n", chain.Packages[0])
+		} else {
+			fmt.Fprintf(buf, "# %s\n", chain.Packages[0])
+		}
+		buf.WriteString(strings.Join(chain.Packages, "\n"))
+		buf.WriteString("\n")
+	}
+}
+
+// current project to the target. A chain that goes through another package of
+// the current project is omitted because that package has a shorter chain of
+// its own. The chains that do not depend on test imports are ordered first.
+func (pg *importGraph) importChains(target string) []importChain {
+	isTarget := func(ip string) bool {
+		return isPathPrefix(ip, target)
+	}
+
+	// testImports has an entry for every package of the current project.
+	var roots []string
+	for ip := range pg.testImports {
+		roots = append(roots, ip)
+	}
+	sort.Strings(roots)
+
+	var chains []importChain
+	for _, rp := range roots {
+		chain := importChain{Packages: pg.shortestChain(rp, isTarget, false)}
+		if chain.Packages == nil {
+			chain = importChain{Packages: pg.shortestChain(rp, isTarget, true), TestOnly: true}
+		}
+		if chain.Packages == nil || pg.viaOtherRoot(chain.Packages) {
+			continue
+		}
+		chains = append(chains, chain)
+	}
+
+	sort.SliceStable(chains, func(i, j int) bool {
+		if chains[i].TestOnly != chains[j].TestOnly {
+			return !chains[i].TestOnly
+		}
+		return len(chains[i].Packages) < len(chains[j].Packages)
+	})
+	return chains
+}
+
+// shortestChain returns the shortest chain of imports from the provided
+// package to a package that matches isTarget, or nil if there is no such
+// chain. If tests is true, the test imports of from are followed as well.
+func (pg *importGraph) shortestChain(from string, isTarget func(string) bool, tests bool) []string {
+	prev := map[string]string{from: ""}
+	queue := []string{from}
+	for len(queue) > 0 {
+		ip := queue[0]
+		queue = queue[1:]
+		if ip != from && isTarget(ip) {
+			var chain []string
+			for curr := ip; curr != ""; curr = prev[curr] {
+				chain = append([]string{curr}, chain...)
+			}
+			return chain
+		}
+
+		next := pg.imports[ip]
+		if tests && ip == from {
+			// Only the tests of from are built, not those of the packages
+			// they import.
+			next = append(append([]string{}, next...), pg.testImports[ip]...)
+		}
+		for _, imp := range next {
+			if _, ok := prev[imp]; ok {
+				continue
+			}
+			if _, ok := pg.imports[imp]; !ok && !isTarget(imp) {
+				// Not a package of the graph, so it cannot lead to the target.
+				continue
+			}
+			prev[imp] = ip
+			queue = append(queue, imp)
+		}
+	}
+	return nil
+}
+
+// viaOtherRoot returns true if any package of the chain between the first and
+// the last is a package of the current project.
+func (pg *importGraph) viaOtherRoot(chain []string) bool {
+	for _, ip := range chain[1 : len(chain)-1] {
+		if _, ok := pg.testImports[ip]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -5,6 +5,7 @@
 package dep
 
 import (
+	"io"
 	"log"
 	"os"
 	"path/filepath"
@@ -48,6 +49,11 @@ type Ctx struct {
 	DisableLocking	bool		// When set, no lock file will be created to protect against simultaneous dep processes.
 	Cachedir	string		// Cache directory loaded from environment.
 	CacheAge	time.Duration	// Maximum valid age of cached source data. <=0: Don't cache.
+	Offline		bool		// When set, sources are never fetched from the network.
+	SharedCachedir	string		// Read-only cache directory consulted after Cachedir, loaded from environment.
+	DigestCache	bool		// When set, the digests of vendored projects are cached in the cache directory.
+	Stdin		io.Reader	// Data read by commands given "-" as an input file.
+	Stdout		io.Writer	// Data written by commands given "-" as an output file, which must not go through Out.
 }
 
 // SetPaths sets the WorkingDir and GOPATHs fields. If GOPATHs is empty, then
@@ -97,14 +103,9 @@ func defaultGOPATH() string {
 // SourceManager produces an instance of gps's built-in SourceManager
 // initialized to log to the receiver's logger.
 func (c *Ctx) SourceManager() (*gps.SourceMgr, error) {
-	cachedir := c.Cachedir
-	if cachedir == "" {
-		// When `DEPCACHEDIR` isn't set in the env, use the default - `$GOPATH/pkg/dep`.
-		cachedir = filepath.Join(c.GOPATH, "pkg", "dep")
-		// Create the default cachedir if it does not exist.
-		if err := os.MkdirAll(cachedir, 0777); err != nil {
-			return nil, errors.Wrap(err, "failed to create default cache directory")
-		}
+	cachedir, err := c.cachedir()
+	if err != nil {
+		return nil, err
 	}
 
 	return gps.NewSourceManager(gps.SourceManagerConfig{
@@ -112,9 +113,27 @@ func (c *Ctx) SourceManager() (*gps.SourceMgr, error) {
 		Cachedir:	cachedir,
 		Logger:		c.Out,
 		DisableLocking:	c.DisableLocking,
+		Offline:	c.Offline,
+		SharedCachedir:	c.SharedCachedir,
 	})
 }
 
+// cachedir returns the cache directory, creating the default one if Cachedir
+// is not set.
+func (c *Ctx) cachedir() (string, error) {
+	if c.Cachedir != "" {
+		return c.Cachedir, nil
+	}
+
+	// When `DEPCACHEDIR` isn't set in the env, use the default - `$GOPATH/pkg/dep`.
+	cachedir := filepath.Join(c.GOPATH, "pkg", "dep")
+	// Create the default cachedir if it does not exist.
+	if err := os.MkdirAll(cachedir, 0777); err != nil {
+		return "", errors.Wrap(err, "failed to create default cache directory")
+	}
+	return cachedir, nil
+}
+
 // LoadProject starts from the current working directory and searches up the
 // directory tree for a project root.  The search stops when a file with the name
 // ManifestName (Gopkg.toml, by default) is located.
@@ -200,6 +219,7 @@ func (c *Ctx) LoadProject() (*Project, error) {
 			for k, lp := range p.ChangedLock.Projects() {
 				vp := lp.(verify.VerifiableProject)
 				vp.PruneOpts = p.Manifest.PruneOptions.PruneOptionsFor(lp.Ident().ProjectRoot)
+				vp.PruneParams = p.Manifest.PruneOptions.PruneParamsFor(lp.Ident().ProjectRoot)
 				p.ChangedLock.P[k] = vp
 			}
 		}
@@ -210,6 +230,18 @@ func (c *Ctx) LoadProject() (*Project, error) {
 		return nil, errors.Wrapf(err, "could not open %s", lp)
 	}
 
+	if c.DigestCache {
+		cachedir, err := c.cachedir()
+		if err != nil {
+			return nil, err
+		}
+		p.DigestCache, err = verify.OpenDigestCache(filepath.Join(cachedir, verify.DigestCacheFilename))
+		if err != nil {
+			return nil, err
+		}
+		p.Logger = c.Err
+	}
+
 	return p, nil
 }
 
@@ -0,0 +1,463 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+)
+
+// cacheBundleVersion is the version of the format of cache bundles. It must be
+// incremented whenever incompatible changes are made.
+const cacheBundleVersion = 1
+
+const (
+	cacheBundleInfoName	= "bundle.json"
+	cacheBundleMetadataName	= "metadata.db"
+	cacheBundleSourcesDir	= "sources"
+)
+
+// CacheBundleInfo describes the content of a cache bundle, a gzipped tarball of
+// the local copies of sources and of their cached metadata that are needed to
+// solve and vendor a lock without network access.
+type CacheBundleInfo struct {
+	Version		int
+	Created		time.Time
+	Projects	[]CacheBundleProject
+}
+
+// CacheBundleProject describes a project of a cache bundle.
+type CacheBundleProject struct {
+	ProjectRoot	ProjectRoot
+	Source		string	`json:",omitempty"`
+	Revision	Revision
+	// Sources are the names of the local copies of the source of the project
+	// under Cachedir/sources.
+	Sources	[]string
+}
+
+// ExportCacheBundle writes a cache bundle for the provided locked projects to
+// w. The local copy of the source of each project is updated if it does not
+// contain the locked revision, and the bundle also contains the version list
+// of each source and the manifest, lock and package tree of each locked
+// revision, as computed by an.
+func (sm *SourceMgr) ExportCacheBundle(w io.Writer, lps []LockedProject, an ProjectAnalyzer) (CacheBundleInfo, error) {
+	tmp, err := ioutil.TempDir(sm.cachedir, "export-")
+	if err != nil {
+		return CacheBundleInfo{}, errors.Wrap(err, "failed to create temporary directory")
+	}
+	defer os.RemoveAll(tmp)
+
+	// The bolt cache only logs its errors, so collect them to fail the export
+	// instead of writing an incomplete bundle.
+	var logbuf bytes.Buffer
+	bc, err := newBoltCache(tmp, 0, log.New(&logbuf, "", 0))
+	if err != nil {
+		return CacheBundleInfo{}, err
+	}
+	info := CacheBundleInfo{
+		Version:	cacheBundleVersion,
+		Created:	time.Now().UTC(),
+		Projects:	[]CacheBundleProject{},
+	}
+	var css []CachedSource
+	for _, lp := range lps {
+		bp, pcss, err := sm.exportCacheBundleProject(bc, lp, an)
+		if err != nil {
+			bc.close()
+			return CacheBundleInfo{}, err
+		}
+		info.Projects = append(info.Projects, bp)
+		css = append(css, pcss...)
+	}
+	if err := bc.close(); err != nil {
+		return CacheBundleInfo{}, err
+	}
+	if logbuf.Len() > 0 {
+		return CacheBundleInfo{}, errors.Errorf("failed to collect metadata:\n%s", strings.TrimSpace(logbuf.String()))
+	}
+
+	gzw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gzw)
+	b, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return CacheBundleInfo{}, err
+	}
+	if err := writeTarFile(tw, cacheBundleInfoName, b); err != nil {
+		return CacheBundleInfo{}, err
+	}
+	if err := addTarPath(tw, filepath.Join(tmp, boltCacheFilename), cacheBundleMetadataName); err != nil {
+		return CacheBundleInfo{}, err
+	}
+	seen := make(map[string]bool)
+	for _, cs := range css {
+		if seen[cs.Name] {
+			continue
+		}
+		seen[cs.Name] = true
+		if err := addTarPath(tw, cs.Path, cacheBundleSourcesDir+"/"+cs.Name); err != nil {
+			return CacheBundleInfo{}, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return CacheBundleInfo{}, errors.Wrap(err, "failed to write bundle")
+	}
+	return info, errors.Wrap(gzw.Close(), "failed to write bundle")
+}
+
+func (sm *SourceMgr) exportCacheBundleProject(bc *boltCache, lp LockedProject, an ProjectAnalyzer) (CacheBundleProject, []CachedSource, error) {
+	id := lp.Ident()
+	var rev Revision
+	switch v := lp.Version().(type) {
+	case PairedVersion:
+		rev = v.Revision()
+	case Revision:
+		rev = v
+	default:
+		return CacheBundleProject{}, nil, errors.Errorf("%s is not locked to a revision", id)
+	}
+
+	if present, err := sm.RevisionPresentIn(id, rev); err != nil {
+		return CacheBundleProject{}, nil, err
+	} else if !present {
+		if err := sm.SyncSourceFor(id); err != nil {
+			return CacheBundleProject{}, nil, err
+		}
+	}
+	pvs, err := sm.ListVersions(id)
+	if err != nil {
+		return CacheBundleProject{}, nil, err
+	}
+	m, l, err := sm.GetManifestAndLock(id, rev, an)
+	if err != nil {
+		return CacheBundleProject{}, nil, err
+	}
+	ptree, err := sm.ListPackages(id, rev)
+	if err != nil {
+		return CacheBundleProject{}, nil, err
+	}
+
+	c := bc.newSingleSourceCache(id)
+	c.setVersionMap(pvs)
+	c.setManifestAndLock(rev, an.Info(), m, l)
+	c.setPackageTree(rev, ptree)
+
+	// The sources of the project were determined above, so this needs no
+	// further network access.
+	css, err := sm.CachedSourcesFor(id, true)
+	if err != nil {
+		return CacheBundleProject{}, nil, err
+	}
+	if len(css) == 0 {
+		return CacheBundleProject{}, nil, errors.Errorf("no local copy of the source of %s", id)
+	}
+	bp := CacheBundleProject{
+		ProjectRoot:	id.ProjectRoot,
+		Source:		id.Source,
+		Revision:	rev,
+	}
+	for _, cs := range css {
+		bp.Sources = append(bp.Sources, cs.Name)
+	}
+	return bp, css, nil
+}
+
+// ImportCacheBundle loads a cache bundle written by ExportCacheBundle into the
+// cache directory. The local copies of sources in the bundle replace existing
+// ones, and their metadata is written to the persistent metadata cache, which
+// is created if necessary.
+//
+// Unless keepTimestamps is true, the version lists of the bundle are marked as
+// fetched at the time of the import rather than at the time of the export, so
+// that they are considered fresh for the cache age of the SourceMgr.
+//
+// Sources must not be imported while they are in use by the SourceMgr.
+func (sm *SourceMgr) ImportCacheBundle(r io.Reader, keepTimestamps bool) (CacheBundleInfo, error) {
+	tmp, err := ioutil.TempDir(sm.cachedir, "import-")
+	if err != nil {
+		return CacheBundleInfo{}, errors.Wrap(err, "failed to create temporary directory")
+	}
+	defer os.RemoveAll(tmp)
+
+	if err := extractTar(r, tmp); err != nil {
+		return CacheBundleInfo{}, err
+	}
+	var info CacheBundleInfo
+	b, err := ioutil.ReadFile(filepath.Join(tmp, cacheBundleInfoName))
+	if err != nil {
+		return CacheBundleInfo{}, errors.Wrap(err, "not a cache bundle")
+	}
+	if err := json.Unmarshal(b, &info); err != nil {
+		return CacheBundleInfo{}, errors.Wrap(err, "failed to read bundle info")
+	}
+	if info.Version != cacheBundleVersion {
+		return CacheBundleInfo{}, errors.Errorf("unsupported cache bundle version %d, expected %d", info.Version, cacheBundleVersion)
+	}
+
+	var stamp []byte
+	if !keepTimestamps {
+		stamp = cacheTimestampedKey(cacheVersion, time.Now())
+	}
+	if err := sm.importCacheBundleMetadata(filepath.Join(tmp, cacheBundleMetadataName), stamp); err != nil {
+		return CacheBundleInfo{}, err
+	}
+
+	srcdir := filepath.Join(sm.cachedir, "sources")
+	if err := os.MkdirAll(srcdir, 0777); err != nil {
+		return CacheBundleInfo{}, errors.Wrapf(err, "failed to create source cache directory %s", srcdir)
+	}
+	fis, err := ioutil.ReadDir(filepath.Join(tmp, cacheBundleSourcesDir))
+	if err != nil && !os.IsNotExist(err) {
+		return CacheBundleInfo{}, errors.Wrap(err, "failed to read bundle sources")
+	}
+	for _, fi := range fis {
+		to := filepath.Join(srcdir, fi.Name())
+		if err := os.RemoveAll(to); err != nil {
+			return CacheBundleInfo{}, errors.Wrapf(err, "failed to remove cached source %s", to)
+		}
+		if err := os.Rename(filepath.Join(tmp, cacheBundleSourcesDir, fi.Name()), to); err != nil {
+			return CacheBundleInfo{}, errors.Wrapf(err, "failed to import cached source %s", to)
+		}
+	}
+	return info, nil
+}
+
+// path into the persistent metadata cache, replacing existing ones. Timestamped
+// version lists are renamed to stamp, if it is not nil.
+func (sm *SourceMgr) importCacheBundleMetadata(path string, stamp []byte) error {
+	from, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 1 * time.Second, ReadOnly: true})
+	if err != nil {
+		return errors.Wrap(err, "failed to open bundle metadata")
+	}
+	defer from.Close()
+
+	_, err = sm.withMetadataCache(true, func(db *bolt.DB) error {
+		return from.View(func(ftx *bolt.Tx) error {
+			return db.Update(func(tx *bolt.Tx) error {
+				return ftx.ForEach(func(name []byte, fb *bolt.Bucket) error {
+					if tx.Bucket(name) != nil {
+						if err := tx.DeleteBucket(name); err != nil {
+							return errors.Wrapf(err, "failed to remove the cached metadata of %s", name)
+						}
+					}
+					b, err := tx.CreateBucket(name)
+					if err != nil {
+						return errors.Wrapf(err, "failed to create bucket: %s", name)
+					}
+					return errors.Wrapf(copyBoltBucket(b, fb, stamp), "failed to import the metadata of %s", name)
+				})
+			})
+		})
+	})
+	return err
+}
+
+// copyBoltBucket recursively copies the keys and buckets of from into to. The
+// timestamped version buckets of sources and revisions are renamed to stamp,
+// if it is not nil.
+func copyBoltBucket(to, from *bolt.Bucket, stamp []byte) error {
+	return from.ForEach(func(k, v []byte) error {
+		if v != nil {
+			return to.Put(k, v)
+		}
+		name, sub := k, stamp
+		switch {
+		case len(k) == len(stamp) && k[0] == cacheVersion:
+			name, sub = stamp, nil
+		case len(k) > 0 && k[0] == cacheRevision:
+		default:
+			sub = nil
+		}
+		b, err := to.CreateBucket(name)
+		if err != nil {
+			return err
+		}
+		return copyBoltBucket(b, from.Bucket(k), sub)
+	})
+}
+
+func writeTarFile(tw *tar.Writer, name string, b []byte) error {
+	hdr := &tar.Header{
+		Name:		name,
+		Mode:		0644,
+		Size:		int64(len(b)),
+		ModTime:	time.Now(),
+		Typeflag:	tar.TypeReg,
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return errors.Wrapf(err, "failed to write %s", name)
+	}
+	_, err := tw.Write(b)
+	return errors.Wrapf(err, "failed to write %s", name)
+}
+
+// addTarPath adds the file or directory tree at path to tw under name.
+func addTarPath(tw *tar.Writer, path, name string) error {
+	return filepath.Walk(path, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(path, p)
+		if err != nil {
+			return err
+		}
+		var link string
+		if fi.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(fi, link)
+		if err != nil {
+			return errors.Wrapf(err, "failed to add %s to bundle", p)
+		}
+		hdr.Name = filepath.ToSlash(filepath.Join(name, rel))
+		if fi.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return errors.Wrapf(err, "failed to add %s to bundle", p)
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return errors.Wrapf(err, "failed to add %s to bundle", p)
+	})
+}
+
+// extractTar extracts the gzipped tarball read from r into dir. Entries that
+// would be written outside of dir, either directly or through symlinks of the
+// tarball, are rejected.
+func extractTar(r io.Reader, dir string) error {
+	gzr, err := gzip.NewReader(r)
+	if err != nil {
+		return errors.Wrap(err, "not a cache bundle")
+	}
+	defer gzr.Close()
+
+	// Symlinks are only created once every other entry has been written, so
+	// that no entry can be written through them.
+	links := make(map[string]string)
+	var linkOrder []string
+
+	tr := tar.NewReader(gzr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return errors.Wrap(err, "failed to read bundle")
+		}
+
+		rel := filepath.Clean(filepath.FromSlash(hdr.Name))
+		if filepath.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return errors.Errorf("invalid path in bundle: %s", hdr.Name)
+		}
+		for p := rel; p != "."; p = filepath.Dir(p) {
+			if _, has := links[p]; has {
+				return errors.Errorf("invalid path in bundle: %s is beneath or replaces symlink %s", hdr.Name, filepath.ToSlash(p))
+			}
+		}
+		path := filepath.Join(dir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			return err
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, os.FileMode(hdr.Mode)|0700); err != nil {
+				return err
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.FileMode(hdr.Mode)|0600)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				return errors.Wrapf(err, "failed to extract %s", hdr.Name)
+			}
+		case tar.TypeSymlink:
+			if hdr.Linkname == "" || filepath.IsAbs(filepath.FromSlash(hdr.Linkname)) {
+				return errors.Errorf("invalid symlink in bundle: %s -> %s", hdr.Name, hdr.Linkname)
+			}
+			links[rel] = filepath.FromSlash(hdr.Linkname)
+			linkOrder = append(linkOrder, rel)
+		default:
+			return errors.Errorf("unsupported entry in bundle: %s", hdr.Name)
+		}
+	}
+
+	for _, rel := range linkOrder {
+		if !linkWithinDir(links, rel) {
+			return errors.Errorf("invalid symlink in bundle: %s -> %s", filepath.ToSlash(rel), filepath.ToSlash(links[rel]))
+		}
+	}
+	for _, rel := range linkOrder {
+		if err := os.Symlink(links[rel], filepath.Join(dir, rel)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// linkWithinDir checks if the symlink at the relative path rel resolves to a
+// path within the directory that links are relative to, following the other
+// symlinks in links, which map relative paths to their targets.
+func linkWithinDir(links map[string]string, rel string) bool {
+	// The components still to be resolved, and those of the resolved path.
+	todo := strings.Split(links[rel], string(filepath.Separator))
+	var resolved []string
+	if parent := filepath.Dir(rel); parent != "." {
+		resolved = strings.Split(parent, string(filepath.Separator))
+	}
+
+	for hops := 0; len(todo) > 0; {
+		elem := todo[0]
+		todo = todo[1:]
+		switch elem {
+		case "", ".":
+			continue
+		case "..":
+			if len(resolved) == 0 {
+				return false
+			}
+			resolved = resolved[:len(resolved)-1]
+			continue
+		}
+		resolved = append(resolved, elem)
+		if target, has := links[filepath.Join(resolved...)]; has {
+			// Like the kernel, give up on long or cyclic chains of symlinks.
+			if hops++; hops > 40 {
+				return false
+			}
+			resolved = resolved[:len(resolved)-1]
+			todo = append(strings.Split(target, string(filepath.Separator)), todo...)
+		}
+	}
+	return true
+}
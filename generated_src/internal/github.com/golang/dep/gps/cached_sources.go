@@ -0,0 +1,307 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/vcs"
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+)
+
+// CachedSource is a local copy of a source in the cache directory of a
+// SourceMgr.
+type CachedSource struct {
+	// Name is the name of the directory of the copy under Cachedir/sources.
+	Name	string
+	// Path is the absolute path of the copy.
+	Path	string
+	// Type is the VCS of the copy, or blank if it could not be detected.
+	Type	string
+	// URL is the upstream URL of the copy, or blank if it could not be
+	// determined.
+	URL	string
+	// Size is the total size of the files of the copy, in bytes.
+	Size	int64
+	// LastFetch approximates the last time the copy was updated from
+	// upstream by the modification time of the VCS metadata.
+	LastFetch	time.Time
+}
+
+// lastFetchFiles are the files, relative to the root of a local copy, whose
+// modification time best approximates the last fetch from upstream, in order of
+// preference.
+var lastFetchFiles = map[vcs.Type][]string{
+	vcs.Git:	{".git/FETCH_HEAD", ".git"},
+	vcs.Hg:		{".hg/store/00changelog.i", ".hg"},
+	vcs.Bzr:	{".bzr/branch/last-revision", ".bzr"},
+	vcs.Svn:	{".svn"},
+}
+
+// CachedSources returns the local copies of sources in the cache directory,
+// ordered by name.
+func (sm *SourceMgr) CachedSources() ([]CachedSource, error) {
+	dir := filepath.Join(sm.cachedir, "sources")
+	fis, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read source cache directory %s", dir)
+	}
+
+	var css []CachedSource
+	for _, fi := range fis {
+		// Skip temporary directories, for example of sources being copied.
+		if !fi.IsDir() || strings.HasPrefix(fi.Name(), ".") {
+			continue
+		}
+		cs, err := readCachedSource(filepath.Join(dir, fi.Name()))
+		if err != nil {
+			return nil, err
+		}
+		css = append(css, cs)
+	}
+	return css, nil
+}
+
+func readCachedSource(path string) (CachedSource, error) {
+	cs := CachedSource{
+		Name:	filepath.Base(path),
+		Path:	path,
+	}
+
+	err := filepath.Walk(path, func(_ string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.Mode().IsRegular() {
+			cs.Size += fi.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return CachedSource{}, errors.Wrapf(err, "failed to read cached source %s", path)
+	}
+
+	typ, err := vcs.DetectVcsFromFS(path)
+	if err != nil {
+		// Leftovers of an interrupted clone, most likely.
+		if fi, err := os.Stat(path); err == nil {
+			cs.LastFetch = fi.ModTime()
+		}
+		return cs, nil
+	}
+	cs.Type = string(typ)
+
+	for _, name := range lastFetchFiles[typ] {
+		if fi, err := os.Stat(filepath.Join(path, name)); err == nil {
+			cs.LastFetch = fi.ModTime()
+			break
+		}
+	}
+
+	var repo vcs.Repo
+	switch typ {
+	case vcs.Git:
+		repo, err = vcs.NewGitRepo("", path)
+	case vcs.Hg:
+		repo, err = vcs.NewHgRepo("", path)
+	case vcs.Bzr:
+		repo, err = vcs.NewBzrRepo("", path)
+	case vcs.Svn:
+		repo, err = vcs.NewSvnRepo("", path)
+	}
+	if err == nil && repo != nil {
+		cs.URL = repo.Remote()
+	}
+	return cs, nil
+}
+
+// UnknownSourcesError is returned by CachedSourcesFor when the sources of a
+// project cannot be determined without network access.
+type UnknownSourcesError struct {
+	// Path is the project root or source of the project.
+	Path string
+}
+
+func (e *UnknownSourcesError) Error() string {
+	return fmt.Sprintf("the sources of %s cannot be determined without network access", e.Path)
+}
+
+// CachedSourcesFor returns the local copies of the sources from which the
+// provided project may be retrieved.
+//
+// The sources are determined locally for the import paths of well-known hosts,
+// such as github.com, and for those with a VCS extension. Other import paths,
+// such as vanity import paths, need their go-get metadata to be read from the
+// network, which is done only if network is true; otherwise an
+// *UnknownSourcesError is returned for them.
+func (sm *SourceMgr) CachedSourcesFor(id ProjectIdentifier, network bool) ([]CachedSource, error) {
+	var deduced pathDeduction
+	var err error
+	if network {
+		deduced, err = sm.deduceCoord.deduceRootPath(context.TODO(), id.normalizedSource())
+	} else {
+		deduced, err = sm.deduceCoord.deduceKnownPaths(id.normalizedSource())
+		if err == errNoKnownPathMatch {
+			err = &UnknownSourcesError{Path: id.normalizedSource()}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var css []CachedSource
+	for _, mb := range deduced.mb {
+		path := mb.cachePath(sm.cachedir)
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		cs, err := readCachedSource(path)
+		if err != nil {
+			return nil, err
+		}
+		css = append(css, cs)
+	}
+	return css, nil
+}
+
+// RemoveCachedSource removes the local copy of a source from the cache
+// directory. It is cloned again the next time it is needed.
+//
+// Sources must not be removed while they are in use by the SourceMgr.
+func (sm *SourceMgr) RemoveCachedSource(cs CachedSource) error {
+	dir := filepath.Join(sm.cachedir, "sources")
+	if filepath.Dir(cs.Path) != dir {
+		return errors.Errorf("%s is not in the source cache directory %s", cs.Path, dir)
+	}
+	return errors.Wrapf(os.RemoveAll(cs.Path), "failed to remove cached source %s", cs.Path)
+}
+
+// VerifyCachedSource checks the integrity of the local copy of a source using
+// its VCS, for example with "git fsck".
+func (sm *SourceMgr) VerifyCachedSource(ctx context.Context, cs CachedSource) error {
+	var args []string
+	switch vcs.Type(cs.Type) {
+	case vcs.Git:
+		args = []string{"git", "fsck", "--no-progress"}
+	case vcs.Hg:
+		args = []string{"hg", "verify"}
+	case vcs.Bzr:
+		args = []string{"bzr", "check"}
+	case "":
+		return errors.Errorf("%s is not a VCS repository", cs.Path)
+	default:
+		return errors.Errorf("verifying %s sources is not supported", cs.Type)
+	}
+
+	cmd := commandContext(ctx, args[0], args[1:]...)
+	cmd.SetDir(cs.Path)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return errors.Wrapf(err, "%s failed in %s: %s", strings.Join(args, " "), cs.Path, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// MetadataCacheInfo describes the persistent cache of source metadata of a
+// SourceMgr.
+type MetadataCacheInfo struct {
+	// Path is the path of the cache file, or blank if it does not exist.
+	Path	string
+	// Size is the size of the cache file, in bytes.
+	Size	int64
+	// Sources are the names of the sources for which metadata is cached, in
+	// the form of a project root or of the source of a project.
+	Sources	[]string
+}
+
+// withMetadataCache calls f with the persistent metadata cache of sm, which is
+// opened for the duration of the call if sm does not use it. It returns false
+// without calling f if there is no persistent metadata cache, unless create is
+// true, in which case the cache is created.
+func (sm *SourceMgr) withMetadataCache(create bool, f func(db *bolt.DB) error) (bool, error) {
+	if mc, ok := sm.srcCoord.cache.(*multiCache); ok {
+		if bc, ok := mc.disk.(*boltCache); ok {
+			return true, f(bc.db)
+		}
+	}
+
+	path := filepath.Join(sm.cachedir, boltCacheFilename)
+	if _, err := os.Stat(path); os.IsNotExist(err) && !create {
+		return false, nil
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		return true, errors.Wrapf(err, "failed to open BoltDB cache file %q", path)
+	}
+	defer db.Close()
+	return true, f(db)
+}
+
+// MetadataCache returns information about the persistent metadata cache.
+func (sm *SourceMgr) MetadataCache() (MetadataCacheInfo, error) {
+	var info MetadataCacheInfo
+	exists, err := sm.withMetadataCache(false, func(db *bolt.DB) error {
+		info.Path = db.Path()
+		return db.View(func(tx *bolt.Tx) error {
+			info.Size = tx.Size()
+			return tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+				info.Sources = append(info.Sources, string(name))
+				return nil
+			})
+		})
+	})
+	if !exists {
+		return MetadataCacheInfo{}, nil
+	}
+	sort.Strings(info.Sources)
+	return info, err
+}
+
+// VerifyMetadataCache checks the consistency of the persistent metadata cache.
+// It returns nil if there is no such cache.
+func (sm *SourceMgr) VerifyMetadataCache() error {
+	_, err := sm.withMetadataCache(false, func(db *bolt.DB) error {
+		return db.View(func(tx *bolt.Tx) error {
+			var msgs []string
+			for err := range tx.Check() {
+				msgs = append(msgs, err.Error())
+			}
+			if len(msgs) > 0 {
+				return errors.Errorf("%s is corrupt:\n%s", db.Path(), strings.Join(msgs, "\n"))
+			}
+			return nil
+		})
+	})
+	return err
+}
+
+// RemoveMetadata removes the cached metadata of the named sources, as listed
+// in MetadataCacheInfo.Sources, from the persistent metadata cache.
+func (sm *SourceMgr) RemoveMetadata(sources []string) error {
+	_, err := sm.withMetadataCache(false, func(db *bolt.DB) error {
+		return db.Update(func(tx *bolt.Tx) error {
+			for _, name := range sources {
+				if tx.Bucket([]byte(name)) == nil {
+					continue
+				}
+				if err := tx.DeleteBucket([]byte(name)); err != nil {
+					return errors.Wrapf(err, "failed to remove the cached metadata of %s", name)
+				}
+			}
+			return nil
+		})
+	})
+	return err
+}
@@ -0,0 +1,83 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Commit is a single commit in the history of a source.
+type Commit struct {
+	Revision	Revision
+	// Subject is the first line of the commit message.
+	Subject	string
+}
+
+// UnsupportedCommitsError is returned when listing the commits of a source
+// whose version control system does not support it.
+type UnsupportedCommitsError struct {
+	// SourceType is the version control system of the source, such as svn.
+	SourceType string
+}
+
+func (e *UnsupportedCommitsError) Error() string {
+	return fmt.Sprintf("listing commits is not supported for %s sources", e.SourceType)
+}
+
+// parseCommitLines parses lines made of a revision and a subject separated by
+// a NUL byte.
+func parseCommitLines(out []byte) ([]Commit, error) {
+	var commits []Commit
+	for _, line := range bytes.Split(bytes.TrimSpace(out), []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		idx := bytes.IndexByte(line, 0)
+		if idx == -1 {
+			return nil, errors.Errorf("unexpected line in commit log: %q", line)
+		}
+		commits = append(commits, Commit{
+			Revision:	Revision(line[:idx]),
+			Subject:	string(line[idx+1:]),
+		})
+	}
+	return commits, nil
+}
+
+// bzrLogSeparator separates the revisions in the long format of bzr log.
+const bzrLogSeparator = "------------------------------------------------------------"
+
+// parseBzrLog parses the output of bzr log in the long format with revision
+// ids. The subject of a revision is the first line of its message. Merged
+// revisions are indented, which is ignored.
+func parseBzrLog(out []byte) ([]Commit, error) {
+	var commits []Commit
+	var inMessage bool
+	for _, line := range strings.Split(string(out), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == bzrLogSeparator:
+			inMessage = false
+		case inMessage:
+			if c := &commits[len(commits)-1]; c.Subject == "" {
+				c.Subject = line
+			}
+		case strings.HasPrefix(line, "revision-id:"):
+			commits = append(commits, Commit{
+				Revision: Revision(strings.TrimSpace(strings.TrimPrefix(line, "revision-id:"))),
+			})
+		case line == "message:":
+			if len(commits) == 0 {
+				return nil, errors.Errorf("unexpected message without a revision id in bzr log")
+			}
+			inMessage = true
+		}
+	}
+	return commits, nil
+}
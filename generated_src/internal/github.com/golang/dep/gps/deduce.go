@@ -559,13 +559,15 @@ type deductionCoordinator struct {
 	mut		sync.RWMutex
 	rootxt		*radix.Tree
 	deducext	*deducerTrie
+	offline		bool
 }
 
-func newDeductionCoordinator(superv *supervisor) *deductionCoordinator {
+func newDeductionCoordinator(superv *supervisor, offline bool) *deductionCoordinator {
 	dc := &deductionCoordinator{
 		suprvsr:	superv,
 		rootxt:		radix.New(),
 		deducext:	pathDeducerTrie(),
+		offline:	offline,
 	}
 
 	return dc
@@ -626,6 +628,7 @@ func (dc *deductionCoordinator) deduceRootPath(ctx context.Context, path string)
 	hmd := &httpMetadataDeducer{
 		basePath:	path,
 		suprvsr:	dc.suprvsr,
+		offline:	dc.offline,
 		// The vanity deducer will call this func with a completed
 		// pathDeduction if it succeeds in finding one. We process it
 		// back through the action channel to ensure serialized
@@ -704,6 +707,7 @@ type httpMetadataDeducer struct {
 	basePath	string
 	returnFunc	func(pathDeduction)
 	suprvsr		*supervisor
+	offline		bool
 }
 
 func (hmd *httpMetadataDeducer) deduce(ctx context.Context, path string) (pathDeduction, error) {
@@ -718,6 +722,11 @@ func (hmd *httpMetadataDeducer) deduce(ctx context.Context, path string) (pathDe
 
 		pd := pathDeduction{}
 
+		if hmd.offline {
+			hmd.deduceErr = &OfflineError{Op: "reading the go-get metadata of", Source: opath}
+			return
+		}
+
 		// Make the HTTP call to attempt to retrieve go-get metadata
 		var root, vcs, reporoot string
 		err = hmd.suprvsr.do(ctx, path, ctHTTPMetadata, func(ctx context.Context) error {
@@ -0,0 +1,404 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// FailureCode is a stable identifier for a kind of solve failure.
+type FailureCode string
+
+const (
+	// FailureNoVersion indicates that no version of a project satisfied the
+	// solver. The Causes of the Diagnostic describe why each version was
+	// rejected.
+	FailureNoVersion	FailureCode	= "no-version"
+	// FailureCaseMismatch indicates that a project was imported with a case
+	// variant of a project root that was already selected.
+	FailureCaseMismatch	FailureCode	= "case-mismatch"
+	// FailureWrongCase indicates that a project was imported with a casing
+	// that differs from the one it uses for its own packages.
+	FailureWrongCase	FailureCode	= "wrong-case"
+	// FailureDisjointConstraint indicates that a constraint on a project has
+	// no overlap with the constraints already imposed on it.
+	FailureDisjointConstraint	FailureCode	= "disjoint-constraint"
+	// FailureConstraintNotAllowed indicates that a constraint on a project
+	// does not allow its currently selected version.
+	FailureConstraintNotAllowed	FailureCode	= "constraint-not-allowed"
+	// FailureVersionNotAllowed indicates that a version of a project is not
+	// allowed by the constraints on it.
+	FailureVersionNotAllowed	FailureCode	= "version-not-allowed"
+	// FailureOutOfUpdateScope indicates that a version of a project is
+	// allowed by the constraints on it, but not by the update scope of the
+	// solve.
+	FailureOutOfUpdateScope	FailureCode	= "out-of-update-scope"
+	// FailureMissingSource indicates that no source could be found for a
+	// project.
+	FailureMissingSource	FailureCode	= "missing-source"
+	// FailureBadOptions indicates that the solver was given invalid
+	// parameters.
+	FailureBadOptions	FailureCode	= "bad-options"
+	// FailureSourceMismatch indicates that dependers disagree about the source
+	// of a project.
+	FailureSourceMismatch	FailureCode	= "source-mismatch"
+	// FailureProblemPackages indicates that packages required from a version
+	// of a project are missing or contain errors.
+	FailureProblemPackages	FailureCode	= "problem-packages"
+	// FailureNonexistentRevision indicates that a revision required of a
+	// project does not exist in its source.
+	FailureNonexistentRevision	FailureCode	= "nonexistent-revision"
+	// FailureOther indicates a failure of a version that has no more specific
+	// code, such as an error reading its source.
+	FailureOther	FailureCode	= "other"
+)
+
+// Diagnostic is a structured description of a solve failure. Fields that do
+// not apply to the Code of the Diagnostic are left empty.
+type Diagnostic struct {
+	Code	FailureCode	`json:"code"`
+	// Message is the human-readable description of the failure.
+	Message	string	`json:"message"`
+	// Project and Version identify the project (and, if applicable, the
+	// version of it) that could not be introduced or that is the subject of
+	// the failure.
+	Project	string	`json:"project,omitempty"`
+	Version	string	`json:"version,omitempty"`
+	// Depender is the dependency that introduced the failure, if any.
+	Depender	*DiagnosticDependency	`json:"depender,omitempty"`
+	// Conflicts are the existing dependencies that conflict with Depender or
+	// that rejected Version.
+	Conflicts	[]DiagnosticDependency	`json:"conflicts,omitempty"`
+	// Packages are the problematic packages of Project.
+	Packages	[]DiagnosticPackage	`json:"packages,omitempty"`
+	// Causes are the failures of the individual versions of Project for a
+	// FailureNoVersion Diagnostic.
+	Causes	[]Diagnostic	`json:"causes,omitempty"`
+	// Remedies are suggested changes that may resolve the failure.
+	Remedies	[]string	`json:"remedies,omitempty"`
+}
+
+// DiagnosticDependency is a dependency of a depender on a project.
+type DiagnosticDependency struct {
+	// Depender is the root of the depending project, and DependerVersion is
+	// its selected version. DependerVersion is empty for the root project.
+	Depender	string	`json:"depender"`
+	DependerVersion	string	`json:"dependerVersion,omitempty"`
+	Root		bool	`json:"root,omitempty"`
+	// Project is the root of the project depended on as written by the
+	// depender, and Source is the source it requested, if any.
+	Project	string	`json:"project"`
+	Source	string	`json:"source,omitempty"`
+	// Constraint is the constraint of the depender on Project.
+	Constraint	string	`json:"constraint,omitempty"`
+}
+
+// DiagnosticPackage is a package of a project that is missing or has errors.
+type DiagnosticPackage struct {
+	Path	string	`json:"path"`
+	// Problem is "missing" if the package does not exist, and otherwise
+	// describes the error in the package.
+	Problem	string	`json:"problem"`
+	// RequiredBy are the projects that require the package.
+	RequiredBy	[]string	`json:"requiredBy,omitempty"`
+}
+
+type diagnoser interface {
+	diagnostic() Diagnostic
+}
+
+// Diagnose returns a Diagnostic for the cause of err if it is a solve failure.
+// The boolean return value is false if err is not a solve failure.
+func Diagnose(err error) (Diagnostic, bool) {
+	d, ok := errors.Cause(err).(diagnoser)
+	if !ok {
+		return Diagnostic{}, false
+	}
+	return d.diagnostic(), true
+}
+
+func diagnosticDependency(dep dependency) DiagnosticDependency {
+	dd := DiagnosticDependency{
+		Depender:	string(dep.depender.id.ProjectRoot),
+		Project:	string(dep.dep.Ident.ProjectRoot),
+		Source:		dep.dep.Ident.Source,
+		Constraint:	dep.dep.Constraint.String(),
+	}
+	if dep.depender.v == rootRev || dep.depender.v == nil {
+		dd.Root = true
+	} else {
+		dd.DependerVersion = dep.depender.v.String()
+	}
+	return dd
+}
+
+func diagnosticDependencies(deps []dependency) []DiagnosticDependency {
+	var dds []DiagnosticDependency
+	for _, dep := range deps {
+		dds = append(dds, diagnosticDependency(dep))
+	}
+	return dds
+}
+
+// constraintRemedy suggests how to lift the constraint of a dependency: by
+// changing it if it is imposed by the root project, or by overriding it.
+func constraintRemedy(dep dependency) string {
+	dd := diagnosticDependency(dep)
+	if dd.Root {
+		return fmt.Sprintf("change the constraint %s on %s in Gopkg.toml", dd.Constraint, dd.Project)
+	}
+	return fmt.Sprintf("add an [[override]] for %s to Gopkg.toml to replace the constraint %s from %s@%s", dd.Project, dd.Constraint, dd.Depender, dd.DependerVersion)
+}
+
+func packageProblem(err error) string {
+	if err == nil {
+		return "missing"
+	}
+	return fmt.Sprintf("does not contain usable Go code (%T)", err)
+}
+
+func (e *noVersionError) diagnostic() Diagnostic {
+	d := Diagnostic{
+		Code:		FailureNoVersion,
+		Message:	e.Error(),
+		Project:	string(e.pn.ProjectRoot),
+	}
+	seen := make(map[string]bool)
+	for _, f := range e.fails {
+		var cause Diagnostic
+		if fd, ok := f.f.(diagnoser); ok {
+			cause = fd.diagnostic()
+		} else {
+			cause = Diagnostic{
+				Code:		FailureOther,
+				Message:	f.f.Error(),
+				Project:	string(e.pn.ProjectRoot),
+			}
+		}
+		cause.Version = f.v.String()
+		d.Causes = append(d.Causes, cause)
+
+		for _, r := range cause.Remedies {
+			if !seen[r] {
+				seen[r] = true
+				d.Remedies = append(d.Remedies, r)
+			}
+		}
+	}
+	if len(e.fails) == 0 {
+		d.Remedies = []string{fmt.Sprintf("check that %s has at least one version, or set its source in Gopkg.toml", e.pn.ProjectRoot)}
+	}
+	return d
+}
+
+func (e *caseMismatchFailure) diagnostic() Diagnostic {
+	return Diagnostic{
+		Code:		FailureCaseMismatch,
+		Message:	e.Error(),
+		Project:	string(e.current),
+		Depender:	depPtr(diagnosticDependency(e.goal)),
+		Conflicts:	diagnosticDependencies(e.failsib),
+		Remedies: []string{
+			fmt.Sprintf("change the imports of %s in %s to use %s", e.goal.dep.Ident.ProjectRoot, e.goal.depender.id.ProjectRoot, e.current),
+		},
+	}
+}
+
+func (e *wrongCaseFailure) diagnostic() Diagnostic {
+	d := Diagnostic{
+		Code:		FailureWrongCase,
+		Message:	e.Error(),
+		Project:	string(e.correct),
+		Depender:	depPtr(diagnosticDependency(e.goal)),
+		Conflicts:	diagnosticDependencies(e.badcase),
+	}
+	for _, dep := range e.badcase {
+		d.Remedies = append(d.Remedies, fmt.Sprintf("change the imports of %s in %s to use %s", dep.dep.Ident.ProjectRoot, dep.depender.id.ProjectRoot, e.correct))
+	}
+	return d
+}
+
+func (e *disjointConstraintFailure) diagnostic() Diagnostic {
+	d := Diagnostic{
+		Code:		FailureDisjointConstraint,
+		Message:	e.Error(),
+		Project:	string(e.goal.dep.Ident.ProjectRoot),
+		Depender:	depPtr(diagnosticDependency(e.goal)),
+		Conflicts:	diagnosticDependencies(e.failsib),
+	}
+
+	constraints := []string{fmt.Sprintf("%s from %s", e.goal.dep.Constraint, e.goal.depender.id.ProjectRoot)}
+	for _, dep := range e.failsib {
+		constraints = append(constraints, fmt.Sprintf("%s from %s", dep.dep.Constraint, dep.depender.id.ProjectRoot))
+	}
+	d.Remedies = []string{
+		fmt.Sprintf("add an [[override]] for %s to Gopkg.toml with a version that works for all dependers (%s)", e.goal.dep.Ident.ProjectRoot, strings.Join(constraints, "; ")),
+	}
+	for _, dep := range append([]dependency{e.goal}, e.failsib...) {
+		if dep.depender.v == rootRev {
+			d.Remedies = append(d.Remedies, constraintRemedy(dep))
+		}
+	}
+	return d
+}
+
+func (e *constraintNotAllowedFailure) diagnostic() Diagnostic {
+	return Diagnostic{
+		Code:		FailureConstraintNotAllowed,
+		Message:	e.Error(),
+		Project:	string(e.goal.dep.Ident.ProjectRoot),
+		Version:	e.v.String(),
+		Depender:	depPtr(diagnosticDependency(e.goal)),
+		Remedies:	[]string{constraintRemedy(e.goal)},
+	}
+}
+
+func (e *versionNotAllowedFailure) diagnostic() Diagnostic {
+	d := Diagnostic{
+		Code:		FailureVersionNotAllowed,
+		Message:	e.Error(),
+		Project:	string(e.goal.id.ProjectRoot),
+		Conflicts:	diagnosticDependencies(e.failparent),
+	}
+	if e.goal.v != nil {
+		d.Version = e.goal.v.String()
+	}
+	for _, dep := range e.failparent {
+		d.Remedies = append(d.Remedies, constraintRemedy(dep))
+	}
+	return d
+}
+
+func (e *outOfUpdateScopeFailure) diagnostic() Diagnostic {
+	return Diagnostic{
+		Code:		FailureOutOfUpdateScope,
+		Message:	e.Error(),
+		Project:	string(e.goal.id.ProjectRoot),
+		Version:	e.goal.v.String(),
+		Remedies: []string{
+			fmt.Sprintf("update %s with a wider update scope than %s", e.goal.id.ProjectRoot, e.c),
+		},
+	}
+}
+
+func (e *missingSourceFailure) diagnostic() Diagnostic {
+	return Diagnostic{
+		Code:		FailureMissingSource,
+		Message:	e.Error(),
+		Project:	string(e.goal.ProjectRoot),
+		Remedies: []string{
+			fmt.Sprintf("check that the source of %s is reachable, or set its source in Gopkg.toml", e.goal.ProjectRoot),
+		},
+	}
+}
+
+func (e badOptsFailure) diagnostic() Diagnostic {
+	return Diagnostic{
+		Code:		FailureBadOptions,
+		Message:	e.Error(),
+	}
+}
+
+func (e *sourceMismatchFailure) diagnostic() Diagnostic {
+	current := e.current
+	if current == "" {
+		current = string(e.shared)
+	}
+	mismatch := e.mismatch
+	if mismatch == "" {
+		mismatch = string(e.shared)
+	}
+
+	var dependers []string
+	for _, dep := range e.sel {
+		dependers = append(dependers, string(dep.depender.id.ProjectRoot))
+	}
+	return Diagnostic{
+		Code:		FailureSourceMismatch,
+		Message:	e.Error(),
+		Project:	string(e.shared),
+		Depender: &DiagnosticDependency{
+			Depender:		string(e.prob.id.ProjectRoot),
+			DependerVersion:	e.prob.v.String(),
+			Project:		string(e.shared),
+			Source:			e.mismatch,
+		},
+		Conflicts:	diagnosticDependencies(e.sel),
+		Remedies: []string{
+			fmt.Sprintf("set the source of %s to %s in an [[override]] in Gopkg.toml to match %s", e.shared, current, strings.Join(dependers, ", ")),
+			fmt.Sprintf("set the source of %s to %s in an [[override]] in Gopkg.toml to match %s", e.shared, mismatch, e.prob.id.ProjectRoot),
+		},
+	}
+}
+
+func (e *checkeeHasProblemPackagesFailure) diagnostic() Diagnostic {
+	d := Diagnostic{
+		Code:		FailureProblemPackages,
+		Message:	e.Error(),
+		Project:	string(e.goal.id.ProjectRoot),
+		Version:	e.goal.v.String(),
+	}
+	var pkgs []string
+	for pkg := range e.failpkg {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+	for _, pkg := range pkgs {
+		errdep := e.failpkg[pkg]
+		dp := DiagnosticPackage{
+			Path:		pkg,
+			Problem:	packageProblem(errdep.err),
+		}
+		for _, a := range errdep.deppers {
+			dp.RequiredBy = append(dp.RequiredBy, string(a.id.ProjectRoot))
+		}
+		d.Packages = append(d.Packages, dp)
+		d.Remedies = append(d.Remedies, fmt.Sprintf("constrain %s in Gopkg.toml to a version in which %s is usable, or stop importing it from %s", e.goal.id.ProjectRoot, pkg, strings.Join(dp.RequiredBy, ", ")))
+	}
+	return d
+}
+
+func (e *depHasProblemPackagesFailure) diagnostic() Diagnostic {
+	d := Diagnostic{
+		Code:		FailureProblemPackages,
+		Message:	e.Error(),
+		Project:	string(e.goal.dep.Ident.ProjectRoot),
+		Version:	e.v.String(),
+		Depender:	depPtr(diagnosticDependency(e.goal)),
+	}
+	var pkgs []string
+	for pkg := range e.prob {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+	for _, pkg := range pkgs {
+		d.Packages = append(d.Packages, DiagnosticPackage{
+			Path:		pkg,
+			Problem:	packageProblem(e.prob[pkg]),
+			RequiredBy:	[]string{string(e.goal.depender.id.ProjectRoot)},
+		})
+		d.Remedies = append(d.Remedies, fmt.Sprintf("constrain %s in Gopkg.toml to a version in which %s is usable, or stop importing it from %s", e.goal.dep.Ident.ProjectRoot, pkg, e.goal.depender.id.ProjectRoot))
+	}
+	return d
+}
+
+func (e *nonexistentRevisionFailure) diagnostic() Diagnostic {
+	return Diagnostic{
+		Code:		FailureNonexistentRevision,
+		Message:	e.Error(),
+		Project:	string(e.goal.dep.Ident.ProjectRoot),
+		Version:	string(e.r),
+		Depender:	depPtr(diagnosticDependency(e.goal)),
+		Remedies:	[]string{constraintRemedy(e.goal)},
+	}
+}
+
+func depPtr(dd DiagnosticDependency) *DiagnosticDependency {
+	return &dd
+}
@@ -0,0 +1,195 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+// ExplainReason describes why the solver selected a version of a project.
+type ExplainReason string
+
+const (
+	// ExplainLocked indicates that the version in the root lock was preserved.
+	ExplainLocked	ExplainReason	= "locked"
+	// ExplainPreferred indicates that the version in the lock of a dependency
+	// on the project was preferred.
+	ExplainPreferred	ExplainReason	= "preferred"
+	// ExplainRevision indicates that the project is constrained to a single
+	// revision.
+	ExplainRevision	ExplainReason	= "revision"
+	// ExplainChanged indicates that the project was allowed to change by
+	// ToChange or ChangeAll, so the version in the root lock was ignored and
+	// the first acceptable version in sort order was selected.
+	ExplainChanged	ExplainReason	= "changed"
+	// ExplainNewest indicates that the newest acceptable version was selected
+	// because no locked version applied.
+	ExplainNewest	ExplainReason	= "newest"
+	// ExplainOldest indicates that the oldest acceptable version was selected
+	// because no locked version applied and the solve was a downgrade.
+	ExplainOldest	ExplainReason	= "oldest"
+)
+
+// Explanation records how the solver arrived at the version of a single
+// project. To collect one, set the Project field and pass the Explanation in
+// SolveParameters; it is populated as the solve runs, and describes the state
+// of the solver when Solve returned.
+type Explanation struct {
+	// Project is the root of the project to explain.
+	Project	ProjectRoot
+
+	// Constraints are the constraints on the project at the time of the last
+	// attempt to select it.
+	Constraints	[]ExplainedConstraint
+
+	// LockedVersion is the version of the project in the root lock, if any.
+	LockedVersion	Version
+
+	// ChangeRequested is true if the project was allowed to change by ToChange
+	// or ChangeAll.
+	ChangeRequested	bool
+
+	// Rejected are the versions that were tried and rejected, in the order in
+	// which they were tried. A version may appear more than once if the solver
+	// backtracked to the project.
+	Rejected	[]RejectedVersion
+
+	// Selected is the version that was selected, or nil if the project is not
+	// part of the solution.
+	Selected	Version
+
+	// Reason is the reason Selected won over the other versions. It is empty
+	// if Selected is nil.
+	Reason	ExplainReason
+
+	// Downgrade is true if the solve preferred the oldest acceptable versions
+	// over the newest.
+	Downgrade	bool
+}
+
+// ExplainedConstraint is a constraint on a project and the project that
+// imposed it.
+type ExplainedConstraint struct {
+	// Depender is the project that imposed the constraint.
+	Depender	ProjectRoot
+	// DependerVersion is the selected version of the depender, or nil if the
+	// depender is the root project.
+	DependerVersion	Version
+	// Constraint is the constraint imposed, after applying the overrides of the
+	// root project.
+	Constraint	Constraint
+	// Source is the alternate source of the project requested by the depender,
+	// if any.
+	Source	string
+	// Override is true if Constraint comes from an override of the root
+	// project rather than from the depender's own manifest.
+	Override	bool
+	// Packages are the packages of the project that the depender imports.
+	Packages	[]string
+}
+
+// RejectedVersion is a version of a project that the solver tried and
+// rejected.
+type RejectedVersion struct {
+	Version	Version
+	// Reason is the failure that caused the version to be rejected.
+	Reason	string
+}
+
+// explainFor returns the Explanation to record for the provided project, or nil
+// if the project is not being explained.
+func (s *solver) explainFor(id ProjectIdentifier) *Explanation {
+	if s.ex == nil || s.ex.Project != id.ProjectRoot {
+		return nil
+	}
+	return s.ex
+}
+
+// explainQueue records the constraints on the project of the provided version
+// queue, as well as the lock information that was used to build it.
+func (s *solver) explainQueue(q *versionQueue) {
+	ex := s.explainFor(q.id)
+	if ex == nil {
+		return
+	}
+
+	_, ex.ChangeRequested = s.rd.chng[q.id.ProjectRoot]
+	ex.ChangeRequested = ex.ChangeRequested || s.rd.chngall
+	ex.LockedVersion = nil
+	if lp, has := s.rd.rlm[q.id.ProjectRoot]; has {
+		ex.LockedVersion = lp.Version()
+	}
+	ex.Constraints = s.explainedConstraints(q.id)
+}
+
+func (s *solver) explainedConstraints(id ProjectIdentifier) []ExplainedConstraint {
+	var ecs []ExplainedConstraint
+	for _, dep := range s.sel.getDependenciesOn(id) {
+		ec := ExplainedConstraint{
+			Depender:	dep.depender.id.ProjectRoot,
+			Constraint:	dep.dep.Constraint,
+			Source:		dep.dep.Ident.Source,
+			Override:	dep.dep.overrConstraint,
+			Packages:	append([]string(nil), dep.dep.pl...),
+		}
+		if !s.rd.isRoot(dep.depender.id.ProjectRoot) {
+			ec.DependerVersion = dep.depender.v
+		}
+		ecs = append(ecs, ec)
+	}
+	return ecs
+}
+
+// explainReject records that the provided version of a project was rejected
+// because of err. A nil err means the version was abandoned while
+// backtracking.
+func (s *solver) explainReject(id ProjectIdentifier, v Version, err error) {
+	ex := s.explainFor(id)
+	if ex == nil {
+		return
+	}
+
+	reason := "abandoned while backtracking from a failure in a later project"
+	if err != nil {
+		reason = traceFailure(err)
+	}
+	ex.Rejected = append(ex.Rejected, RejectedVersion{Version: v, Reason: reason})
+}
+
+// explainSelect records that the current version of the provided queue was
+// selected.
+func (s *solver) explainSelect(q *versionQueue) {
+	ex := s.explainFor(q.id)
+	if ex == nil {
+		return
+	}
+
+	v := q.current()
+	ex.Selected = v
+	ex.Constraints = s.explainedConstraints(q.id)
+	switch {
+	case q.lockv != nil && v == q.lockv:
+		ex.Reason = ExplainLocked
+	case q.prefv != nil && v == q.prefv:
+		ex.Reason = ExplainPreferred
+	case isRevisionConstraint(s.sel.getConstraint(q.id)):
+		ex.Reason = ExplainRevision
+	case ex.ChangeRequested:
+		ex.Reason = ExplainChanged
+	case ex.Downgrade:
+		ex.Reason = ExplainOldest
+	default:
+		ex.Reason = ExplainNewest
+	}
+}
+
+// explainUnselect records that the provided project is no longer selected.
+func (s *solver) explainUnselect(id ProjectIdentifier) {
+	if ex := s.explainFor(id); ex != nil {
+		ex.Selected = nil
+		ex.Reason = ""
+	}
+}
+
+func isRevisionConstraint(c Constraint) bool {
+	_, ok := c.(Revision)
+	return ok
+}
@@ -24,6 +24,8 @@ import (
 type maybeSource interface {
 	// try tries to set up a source.
 	try(ctx context.Context, cachedir string) (source, error)
+	// cachePath returns the path of the local clone of the source in cachedir.
+	cachePath(cachedir string) string
 	URL() *url.URL
 	fmt.Stringer
 }
@@ -49,7 +51,7 @@ type maybeGitSource struct {
 
 func (m maybeGitSource) try(ctx context.Context, cachedir string) (source, error) {
 	ustr := m.url.String()
-	path := sourceCachePath(cachedir, ustr)
+	path := m.cachePath(cachedir)
 
 	r, err := vcs.NewGitRepo(ustr, path)
 	if err != nil {
@@ -67,6 +69,10 @@ func (m maybeGitSource) try(ctx context.Context, cachedir string) (source, error
 	}, nil
 }
 
+func (m maybeGitSource) cachePath(cachedir string) string {
+	return sourceCachePath(cachedir, m.url.String())
+}
+
 func (m maybeGitSource) URL() *url.URL {
 	return m.url
 }
@@ -90,11 +96,7 @@ type maybeGopkginSource struct {
 }
 
 func (m maybeGopkginSource) try(ctx context.Context, cachedir string) (source, error) {
-	// We don't actually need a fully consistent transform into the on-disk path
-	// - just something that's unique to the particular gopkg.in domain context.
-	// So, it's OK to just dumb-join the scheme with the path.
-	aliasURL := m.url.Scheme + "://" + m.opath
-	path := sourceCachePath(cachedir, aliasURL)
+	path := m.cachePath(cachedir)
 	ustr := m.url.String()
 
 	r, err := vcs.NewGitRepo(ustr, path)
@@ -114,10 +116,21 @@ func (m maybeGopkginSource) try(ctx context.Context, cachedir string) (source, e
 		},
 		major:		m.major,
 		unstable:	m.unstable,
-		aliasURL:	aliasURL,
+		aliasURL:	m.aliasURL(),
 	}, nil
 }
 
+func (m maybeGopkginSource) cachePath(cachedir string) string {
+	return sourceCachePath(cachedir, m.aliasURL())
+}
+
+func (m maybeGopkginSource) aliasURL() string {
+	// We don't actually need a fully consistent transform into the on-disk path
+	// - just something that's unique to the particular gopkg.in domain context.
+	// So, it's OK to just dumb-join the scheme with the path.
+	return m.url.Scheme + "://" + m.opath
+}
+
 func (m maybeGopkginSource) URL() *url.URL {
 	return &url.URL{
 		Scheme:	m.url.Scheme,
@@ -135,7 +148,7 @@ type maybeBzrSource struct {
 
 func (m maybeBzrSource) try(ctx context.Context, cachedir string) (source, error) {
 	ustr := m.url.String()
-	path := sourceCachePath(cachedir, ustr)
+	path := m.cachePath(cachedir)
 
 	r, err := vcs.NewBzrRepo(ustr, path)
 	if err != nil {
@@ -153,6 +166,10 @@ func (m maybeBzrSource) try(ctx context.Context, cachedir string) (source, error
 	}, nil
 }
 
+func (m maybeBzrSource) cachePath(cachedir string) string {
+	return sourceCachePath(cachedir, m.url.String())
+}
+
 func (m maybeBzrSource) URL() *url.URL {
 	return m.url
 }
@@ -167,7 +184,7 @@ type maybeHgSource struct {
 
 func (m maybeHgSource) try(ctx context.Context, cachedir string) (source, error) {
 	ustr := m.url.String()
-	path := sourceCachePath(cachedir, ustr)
+	path := m.cachePath(cachedir)
 
 	r, err := vcs.NewHgRepo(ustr, path)
 	if err != nil {
@@ -185,6 +202,10 @@ func (m maybeHgSource) try(ctx context.Context, cachedir string) (source, error)
 	}, nil
 }
 
+func (m maybeHgSource) cachePath(cachedir string) string {
+	return sourceCachePath(cachedir, m.url.String())
+}
+
 func (m maybeHgSource) URL() *url.URL {
 	return m.url
 }
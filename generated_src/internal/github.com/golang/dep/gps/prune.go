@@ -7,10 +7,16 @@ package gps
 import (
 	"bytes"
 	"fmt"
+	"go/build"
+	"io"
+	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"unicode"
 
 	"github.com/palantir/godel-dep-plugin/generated_src/internal/github.com/golang/dep/internal/fs"
 	"github.com/pkg/errors"
@@ -30,11 +36,15 @@ const (
 	PruneNonGoFiles
 	// PruneGoTestFiles indicates if Go test files should be pruned.
 	PruneGoTestFiles
+	// PruneUnusedPlatformFiles indicates if source files that are excluded by
+	// the build constraints of all of the target platforms and build tags in
+	// PruneParams should be pruned.
+	PruneUnusedPlatformFiles
 )
 
 // PruneOptionSet represents trinary distinctions for each of the types of
 // prune rules (as expressed via PruneOptions): nested vendor directories,
-// unused packages, non-go files, and go test files.
+// unused packages, non-go files, go test files and unused platform files.
 //
 // The three-way distinction is between "none", "true", and "false", represented
 // by uint8 values of 0, 1, and 2, respectively.
@@ -47,6 +57,60 @@ type PruneOptionSet struct {
 	UnusedPackages	uint8
 	NonGoFiles	uint8
 	GoTests		uint8
+	UnusedPlatforms	uint8
+}
+
+// PruneParams holds the parameters of the prune options that need more than a
+// flag. They are recorded in the lock alongside the prune options, so that the
+// digests of pruned trees are reproducible.
+type PruneParams struct {
+	// Platforms are the target platforms, as GOOS/GOARCH pairs, for which
+	// source files are kept by PruneUnusedPlatformFiles.
+	Platforms	[]string
+	// BuildTags are the build tags that are set on all of the target
+	// platforms.
+	BuildTags	[]string
+	// Keep are glob patterns of files that are never pruned by the prune
+	// options, such as data files that are needed at run time.
+	Keep	[]string
+	// Exclude are glob patterns of files that are always pruned, even if
+	// they match Keep.
+	Exclude	[]string
+}
+
+// Equal returns true if pp and other have the same parameters.
+func (pp PruneParams) Equal(other PruneParams) bool {
+	return equalStrings(pp.Platforms, other.Platforms) && equalStrings(pp.BuildTags, other.BuildTags) &&
+		equalStrings(pp.Keep, other.Keep) && equalStrings(pp.Exclude, other.Exclude)
+}
+
+func (pp PruneParams) String() string {
+	var parts []string
+	if len(pp.Platforms) > 0 {
+		parts = append(parts, "platforms="+strings.Join(pp.Platforms, ","))
+	}
+	if len(pp.BuildTags) > 0 {
+		parts = append(parts, "tags="+strings.Join(pp.BuildTags, ","))
+	}
+	if len(pp.Keep) > 0 {
+		parts = append(parts, "keep="+strings.Join(pp.Keep, ","))
+	}
+	if len(pp.Exclude) > 0 {
+		parts = append(parts, "exclude="+strings.Join(pp.Exclude, ","))
+	}
+	return strings.Join(parts, " ")
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
 }
 
 // CascadingPruneOptions is a set of rules for pruning a dependency tree.
@@ -54,9 +118,15 @@ type PruneOptionSet struct {
 // The DefaultOptions are the global default pruning rules, expressed as a
 // single PruneOptions bitfield. These global rules will cascade down to
 // individual project rules, unless superseded.
+//
+// The DefaultParams are the parameters of the prune options, which apply to
+// every project for which the corresponding options are set. The
+// PerProjectParams hold the keep and exclude patterns of individual projects.
 type CascadingPruneOptions struct {
 	DefaultOptions		PruneOptions
 	PerProjectOptions	map[ProjectRoot]PruneOptionSet
+	DefaultParams		PruneParams
+	PerProjectParams	map[ProjectRoot]PruneParams
 }
 
 // ParsePruneOptions extracts PruneOptions from a string using the standard
@@ -73,6 +143,8 @@ func ParsePruneOptions(input string) (PruneOptions, error) {
 			po |= PruneNonGoFiles
 		case 'V':
 			po |= PruneNestedVendorDirs
+		case 'P':
+			po |= PruneUnusedPlatformFiles
 		default:
 			return 0, errors.Errorf("unknown pruning code %q", char)
 		}
@@ -93,6 +165,9 @@ func (po PruneOptions) String() string {
 	if po&PruneGoTestFiles != 0 {
 		fmt.Fprintf(&buf, "T")
 	}
+	if po&PruneUnusedPlatformFiles != 0 {
+		fmt.Fprintf(&buf, "P")
+	}
 	if po&PruneNestedVendorDirs != 0 {
 		fmt.Fprintf(&buf, "V")
 	}
@@ -144,9 +219,140 @@ func (o CascadingPruneOptions) PruneOptionsFor(pr ProjectRoot) PruneOptions {
 		}
 	}
 
+	if po.UnusedPlatforms != 0 {
+		if po.UnusedPlatforms == 1 {
+			ops |= PruneUnusedPlatformFiles
+		} else {
+			ops &^= PruneUnusedPlatformFiles
+		}
+	}
+
 	return ops
 }
 
+// PruneParamsFor returns the PruneParams for the given project. Only the
+// parameters of the prune options that apply to the project are returned,
+// along with the keep and exclude patterns of the project.
+func (o CascadingPruneOptions) PruneParamsFor(pr ProjectRoot) PruneParams {
+	var pp PruneParams
+	if o.PruneOptionsFor(pr)&PruneUnusedPlatformFiles != 0 {
+		pp.Platforms = o.DefaultParams.Platforms
+		pp.BuildTags = o.DefaultParams.BuildTags
+	}
+	if ppp, has := o.PerProjectParams[pr]; has {
+		pp.Keep = ppp.Keep
+		pp.Exclude = ppp.Exclude
+	}
+	return pp
+}
+
+// knownOS and knownArch are the values of GOOS and GOARCH that Go supports or
+// reserves, as listed by go/build. A platform with any other value would match
+// no OS or architecture specific file, so they are rejected rather than
+// pruning all of those files.
+var (
+	knownOS	= map[string]bool{
+		"aix":	true, "android": true, "darwin": true, "dragonfly": true,
+		"freebsd":	true, "hurd": true, "illumos": true, "ios": true, "js": true,
+		"linux":	true, "nacl": true, "netbsd": true, "openbsd": true,
+		"plan9":	true, "solaris": true, "wasip1": true, "windows": true,
+		"zos":	true,
+	}
+	knownArch	= map[string]bool{
+		"386":	true, "amd64": true, "amd64p32": true, "arm": true, "armbe": true,
+		"arm64":	true, "arm64be": true, "loong64": true, "mips": true,
+		"mipsle":	true, "mips64": true, "mips64le": true, "mips64p32": true,
+		"mips64p32le":	true, "ppc": true, "ppc64": true, "ppc64le": true,
+		"riscv":	true, "riscv64": true, "s390": true, "s390x": true,
+		"sparc":	true, "sparc64": true, "wasm": true,
+	}
+)
+
+// ValidatePrunePlatform returns an error if platform is not a GOOS/GOARCH pair
+// of a known operating system and architecture.
+func ValidatePrunePlatform(platform string) error {
+	parts := strings.Split(platform, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return errors.Errorf("invalid platform %q: must be GOOS/GOARCH", platform)
+	}
+	if !knownOS[parts[0]] {
+		return errors.Errorf("invalid platform %q: unknown GOOS %q", platform, parts[0])
+	}
+	if !knownArch[parts[1]] {
+		return errors.Errorf("invalid platform %q: unknown GOARCH %q", platform, parts[1])
+	}
+	return nil
+}
+
+// ValidatePruneBuildTag returns an error if tag cannot be used as a build tag.
+// Like the go tool, only letters, digits, underscores and dots are allowed.
+func ValidatePruneBuildTag(tag string) error {
+	if tag == "" {
+		return errors.New("empty build tag")
+	}
+	for _, c := range tag {
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) && c != '_' && c != '.' {
+			return errors.Errorf("invalid build tag %q", tag)
+		}
+	}
+	return nil
+}
+
+// ValidatePruneGlob returns an error if pattern is not a valid keep or exclude
+// pattern.
+//
+// Patterns are matched against the slash-separated paths of files relative to
+// the project root, using the syntax of path.Match for each element. A "**"
+// element matches any number of directories, and a pattern without a slash
+// matches the name of a file in any directory.
+func ValidatePruneGlob(pattern string) error {
+	if pattern == "" {
+		return errors.New("empty pattern")
+	}
+	for _, elem := range strings.Split(pattern, "/") {
+		if _, err := path.Match(elem, ""); err != nil {
+			return errors.Errorf("invalid pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+// matchPruneGlobs checks if the slash-separated relative path matches any of
+// the patterns, as described in ValidatePruneGlob.
+func matchPruneGlobs(patterns []string, relPath string) bool {
+	for _, pattern := range patterns {
+		if !strings.Contains(pattern, "/") {
+			if ok, _ := path.Match(pattern, path.Base(relPath)); ok {
+				return true
+			}
+		} else if matchGlobElems(strings.Split(pattern, "/"), strings.Split(relPath, "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchGlobElems(pattern, elems []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(elems); i++ {
+				if matchGlobElems(pattern[1:], elems[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(elems) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], elems[0]); !ok {
+			return false
+		}
+		pattern, elems = pattern[1:], elems[1:]
+	}
+	return len(elems) == 0
+}
+
 func defaultCascadingPruneOptions() CascadingPruneOptions {
 	return CascadingPruneOptions{
 		DefaultOptions:		PruneNestedVendorDirs,
@@ -178,17 +384,35 @@ var (
 	}
 )
 
-// PruneProject remove excess files according to the options passed, from
-// the lp directory in baseDir.
-func PruneProject(baseDir string, lp LockedProject, options PruneOptions) error {
+// PruneProject remove excess files according to the options and parameters
+// passed, from the lp directory in baseDir.
+//
+// Files matching the Keep patterns of params are not removed by the options,
+// even within nested vendor directories, and files matching the Exclude
+// patterns are always removed.
+func PruneProject(baseDir string, lp LockedProject, options PruneOptions, params PruneParams) error {
 	fsState, err := deriveFilesystemState(baseDir)
 
 	if err != nil {
 		return errors.Wrap(err, "could not derive filesystem state")
 	}
+	allFiles := fsState.files
+
+	var kept []string
+	if len(params.Keep) > 0 {
+		// Hide the kept files from the options.
+		fsState.files = nil
+		for _, path := range allFiles {
+			if matchPruneGlobs(params.Keep, filepath.ToSlash(path)) {
+				kept = append(kept, path)
+			} else {
+				fsState.files = append(fsState.files, path)
+			}
+		}
+	}
 
 	if (options & PruneNestedVendorDirs) != 0 {
-		if err := pruneVendorDirs(fsState); err != nil {
+		if err := pruneVendorDirs(fsState, kept); err != nil {
 			return errors.Wrapf(err, "failed to prune nested vendor directories")
 		}
 	}
@@ -211,6 +435,19 @@ func PruneProject(baseDir string, lp LockedProject, options PruneOptions) error
 		}
 	}
 
+	if (options & PruneUnusedPlatformFiles) != 0 {
+		if err := pruneUnusedPlatformFiles(fsState, params); err != nil {
+			return errors.Wrap(err, "failed to prune unused platform files")
+		}
+	}
+
+	if len(params.Exclude) > 0 {
+		fsState.files = allFiles
+		if err := pruneExcludedFiles(fsState, params.Exclude); err != nil {
+			return errors.Wrap(err, "failed to prune excluded files")
+		}
+	}
+
 	if err := deleteEmptyDirs(fsState); err != nil {
 		return errors.Wrap(err, "could not delete empty dirs")
 	}
@@ -218,14 +455,41 @@ func PruneProject(baseDir string, lp LockedProject, options PruneOptions) error
 	return nil
 }
 
-// pruneVendorDirs deletes all nested vendor directories within baseDir.
-func pruneVendorDirs(fsState filesystemState) error {
+// pruneVendorDirs deletes all nested vendor directories within baseDir. A
+// vendor directory that contains kept files is emptied of its other files
+// instead, and its empty directories are left to deleteEmptyDirs.
+func pruneVendorDirs(fsState filesystemState, kept []string) error {
 	for _, dir := range fsState.dirs {
-		if filepath.Base(dir) == "vendor" {
+		if filepath.Base(dir) != "vendor" {
+			continue
+		}
+		keep := false
+		for _, path := range kept {
+			if isWithinDir(path, dir) {
+				keep = true
+				break
+			}
+		}
+		if !keep {
 			err := os.RemoveAll(filepath.Join(fsState.root, dir))
 			if err != nil && !os.IsNotExist(err) {
 				return err
 			}
+			continue
+		}
+
+		paths := fsState.files
+		for _, link := range fsState.links {
+			paths = append(paths, link.path)
+		}
+		for _, path := range paths {
+			if !isWithinDir(path, dir) {
+				continue
+			}
+			err := os.Remove(filepath.Join(fsState.root, path))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
 		}
 	}
 
@@ -241,6 +505,12 @@ func pruneVendorDirs(fsState filesystemState) error {
 	return nil
 }
 
+// isWithinDir checks if the relative path is within the relative directory
+// dir.
+func isWithinDir(path, dir string) bool {
+	return strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
 // pruneUnusedPackages deletes unimported packages found in fsState.
 // Determining whether packages are imported or not is based on the passed LockedProject.
 func pruneUnusedPackages(lp LockedProject, fsState filesystemState) (map[string]interface{}, error) {
@@ -406,6 +676,137 @@ func pruneGoTestFiles(fsState filesystemState) error {
 	return nil
 }
 
+// pruneExcludedFiles deletes the files in fsState that match any of the
+// exclude patterns.
+func pruneExcludedFiles(fsState filesystemState, exclude []string) error {
+	for _, path := range fsState.files {
+		if !matchPruneGlobs(exclude, filepath.ToSlash(path)) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(fsState.root, path)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// maxGoMinorVersion bounds the Go release tags considered when evaluating build
+// constraints.
+const maxGoMinorVersion = 99
+
+// pruneUnusedPlatformFiles deletes the source files in fsState that are
+// excluded by the build constraints of every platform in params, whether or not
+// cgo is enabled.
+//
+// Release tags (go1.N) are treated as unknown: a file is only deleted if it is
+// excluded for every Go release, so that the result does not depend on the
+// version of Go that dep was built with. C headers, files that the go tool
+// ignores and files in testdata directories are never deleted.
+func pruneUnusedPlatformFiles(fsState filesystemState, params PruneParams) error {
+	if len(params.Platforms) == 0 {
+		return errors.New("no target platforms to prune for")
+	}
+
+	var ctxts []*build.Context
+	for _, platform := range params.Platforms {
+		if err := ValidatePrunePlatform(platform); err != nil {
+			return err
+		}
+		parts := strings.Split(platform, "/")
+		for _, cgo := range []bool{true, false} {
+			ctxts = append(ctxts, &build.Context{
+				GOOS:		parts[0],
+				GOARCH:		parts[1],
+				CgoEnabled:	cgo,
+				BuildTags:	params.BuildTags,
+				Compiler:	"gc",
+			})
+		}
+	}
+
+	var toDelete []string
+	for _, path := range fsState.files {
+		if !isPlatformPrunable(path) {
+			continue
+		}
+
+		used, err := isUsedOnPlatforms(ctxts, filepath.Join(fsState.root, path))
+		if os.IsNotExist(err) {
+			// Already pruned by another option.
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !used {
+			toDelete = append(toDelete, filepath.Join(fsState.root, path))
+		}
+	}
+
+	for _, path := range toDelete {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isPlatformPrunable checks if the file at the relative path may be deleted
+// because of its build constraints.
+func isPlatformPrunable(path string) bool {
+	name := filepath.Base(path)
+	if !isSourceFile(name) || strings.HasPrefix(name, "_") || strings.HasPrefix(name, ".") {
+		return false
+	}
+	switch fileExt(name) {
+	case ".h", ".hh", ".hpp", ".hxx":
+		// Headers are included by other files regardless of their names.
+		return false
+	}
+	for _, elem := range strings.Split(filepath.ToSlash(filepath.Dir(path)), "/") {
+		if elem == "testdata" {
+			return false
+		}
+	}
+	return true
+}
+
+// isUsedOnPlatforms checks if the file at path matches the build constraints of
+// any of ctxts, for any Go release. Files whose constraints cannot be
+// evaluated are considered used.
+func isUsedOnPlatforms(ctxts []*build.Context, path string) (bool, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+
+	// Only sweep the release tags if the file could depend on them.
+	releases := [][]string{nil}
+	if bytes.Contains(content, []byte("go1.")) {
+		releases = make([][]string, maxGoMinorVersion+1)
+		for i := 1; i <= maxGoMinorVersion; i++ {
+			releases[i] = append(releases[i-1][:i-1:i-1], "go1."+strconv.Itoa(i))
+		}
+	}
+
+	dir, name := filepath.Split(path)
+	for _, ctxt := range ctxts {
+		ctxt.OpenFile = func(string) (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(content)), nil
+		}
+		for _, tags := range releases {
+			ctxt.ReleaseTags = tags
+			match, err := ctxt.MatchFile(dir, name)
+			if match || err != nil {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
 func deleteEmptyDirs(fsState filesystemState) error {
 	sort.Sort(sort.Reverse(sort.StringSlice(fsState.dirs)))
 
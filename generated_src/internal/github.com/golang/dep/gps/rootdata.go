@@ -34,6 +34,10 @@ type rootdata struct {
 	// for lock.
 	chngall	bool
 
+	// A map of the ProjectRoots that are allowed to change to the constraint
+	// that limits them to the requested update scope.
+	scope	map[ProjectRoot]Constraint
+
 	// A map of the project names listed in the root's lock.
 	rlm	map[ProjectRoot]LockedProject
 
@@ -23,6 +23,7 @@ func (s *solver) check(a atomWithPackages, pkgonly bool) error {
 	defer func() {
 		if err != nil {
 			s.traceInfo(err)
+			s.traceReject(a, pkgonly, err)
 		}
 		s.mtr.pop()
 	}()
@@ -79,7 +80,7 @@ func (s *solver) check(a atomWithPackages, pkgonly bool) error {
 // checkAtomAllowable ensures that an atom itself is acceptable with respect to
 // the constraints established by the current solution.
 func (s *solver) checkAtomAllowable(pa atom) error {
-	constraint := s.sel.getConstraint(pa.id)
+	constraint := s.getConstraint(pa.id)
 	if constraint.Matches(pa.v) {
 		return nil
 	}
@@ -94,6 +95,15 @@ func (s *solver) checkAtomAllowable(pa atom) error {
 		}
 	}
 
+	if len(failparent) == 0 {
+		// None of the dependers rejected the atom, so it must be outside of
+		// the update scope.
+		return &outOfUpdateScopeFailure{
+			goal:	pa,
+			c:	s.rd.scope[pa.id.ProjectRoot],
+		}
+	}
+
 	err := &versionNotAllowedFailure{
 		goal:		pa,
 		failparent:	failparent,
@@ -147,7 +157,7 @@ func (s *solver) checkRequiredPackagesExist(a atomWithPackages) error {
 // given dep are valid with respect to existing constraints.
 func (s *solver) checkDepsConstraintsAllowable(a atomWithPackages, cdep completeDep) error {
 	dep := cdep.workingConstraint
-	constraint := s.sel.getConstraint(dep.Ident)
+	constraint := s.getConstraint(dep.Ident)
 	// Ensure the constraint expressed by the dep has at least some possible
 	// intersection with the intersection of existing constraints.
 	if constraint.MatchesAny(dep.Constraint) {
@@ -112,7 +112,7 @@ func WriteDepTree(basedir string, l Lock, sm SourceManager, co CascadingPruneOpt
 					return errors.Wrapf(err, "failed to export %s", projectRoot)
 				}
 
-				err := PruneProject(to, p, co.PruneOptionsFor(ident.ProjectRoot))
+				err := PruneProject(to, p, co.PruneOptionsFor(ident.ProjectRoot), co.PruneParamsFor(ident.ProjectRoot))
 				if err != nil {
 					return errors.Wrapf(err, "failed to prune %s", projectRoot)
 				}
@@ -300,6 +300,25 @@ func (e *versionNotAllowedFailure) traceString() string {
 	return buf.String()
 }
 
+// outOfUpdateScopeFailure describes a failure where an atom is rejected because
+// its version is allowed by the constraints of its dependers, but not by the
+// update scope of the solve.
+type outOfUpdateScopeFailure struct {
+	// goal is the atom that was rejected.
+	goal	atom
+	// c is the constraint that represents the update scope of the atom's
+	// identifier.
+	c	Constraint
+}
+
+func (e *outOfUpdateScopeFailure) Error() string {
+	return fmt.Sprintf("Could not introduce %s, as it is outside of the update scope %s.", a2vs(e.goal), e.c)
+}
+
+func (e *outOfUpdateScopeFailure) traceString() string {
+	return fmt.Sprintf("%s outside of update scope %s", a2vs(e.goal), e.c)
+}
+
 type missingSourceFailure struct {
 	goal	ProjectIdentifier
 	prob	string
@@ -92,11 +92,24 @@ type SolveParameters struct {
 	// typical case.
 	Downgrade	bool
 
+	// UpdateScope limits the versions that the projects allowed to change by
+	// ToChange or ChangeAll may change to, relative to their versions in the
+	// root lock. The zero value allows any version.
+	UpdateScope	UpdateScope
+
 	// TraceLogger is the logger to use for generating trace output. If set, the
 	// solver will generate informative trace output as it moves through the
 	// solving process.
 	TraceLogger	*log.Logger
 
+	// Tracer, if non-nil, receives structured trace events as the solver moves
+	// through the solving process, in addition to any text trace output.
+	Tracer	Tracer
+
+	// Explanation, if non-nil, is populated with a record of how the solver
+	// chose the version of the project named by its Project field.
+	Explanation	*Explanation
+
 	// stdLibFn is the function to use to recognize standard library import paths.
 	// Only overridden for tests. Defaults to paths.IsStandardImportPath if nil.
 	stdLibFn	func(string) bool
@@ -118,6 +131,12 @@ type solver struct {
 	// Logger used exclusively for trace output, or nil to suppress.
 	tl	*log.Logger
 
+	// Tracer for structured trace events, or nil to suppress.
+	tr	Tracer
+
+	// Explanation of the version choice for a single project, or nil.
+	ex	*Explanation
+
 	// The function to use to recognize standard library import paths.
 	stdLibFn	func(string) bool
 
@@ -262,6 +281,16 @@ func (params SolveParameters) toRootdata() (rootdata, error) {
 		rd.chng[p] = struct{}{}
 	}
 
+	rd.scope = make(map[ProjectRoot]Constraint)
+	for pr, lp := range rd.rlm {
+		if _, explicit := rd.chng[pr]; !explicit && !rd.chngall {
+			continue
+		}
+		if c := scopeConstraint(lp.Version(), params.UpdateScope); c != nil {
+			rd.scope[pr] = c
+		}
+	}
+
 	return rd, nil
 }
 
@@ -286,10 +315,20 @@ func Prepare(params SolveParameters, sm SourceManager) (Solver, error) {
 
 	s := &solver{
 		tl:		params.TraceLogger,
+		tr:		params.Tracer,
 		stdLibFn:	params.stdLibFn,
 		rd:		rd,
 	}
 
+	if params.Explanation != nil {
+		// Reset anything recorded by a previous solve.
+		*params.Explanation = Explanation{
+			Project:	params.Explanation.Project,
+			Downgrade:	params.Downgrade,
+		}
+		s.ex = params.Explanation
+	}
+
 	// Set up the bridge and ensure the root dir is in good, working order
 	// before doing anything else.
 	if params.mkBridgeFn == nil {
@@ -508,6 +547,7 @@ func (s *solver) solve(ctx context.Context) (map[atom]map[string]struct{}, error
 				// Err means a failure somewhere down the line; try backtracking.
 				s.traceStartBacktrack(bmi, err, false)
 				success, berr := s.backtrack(ctx)
+				s.traceEndBacktrack(success, berr)
 				if berr != nil {
 					err = berr
 				} else if success {
@@ -536,6 +576,7 @@ func (s *solver) solve(ctx context.Context) (map[atom]map[string]struct{}, error
 			}
 
 			s.vqs = append(s.vqs, queue)
+			s.explainSelect(queue)
 		} else {
 			s.mtr.push("add-atom")
 			// We're just trying to add packages to an already-selected project.
@@ -564,6 +605,7 @@ func (s *solver) solve(ctx context.Context) (map[atom]map[string]struct{}, error
 				// Err means a failure somewhere down the line; try backtracking.
 				s.traceStartBacktrack(bmi, err, true)
 				success, berr := s.backtrack(ctx)
+				s.traceEndBacktrack(success, berr)
 				if berr != nil {
 					err = berr
 				} else if success {
@@ -899,6 +941,7 @@ func (s *solver) createVersionQueue(bmi bimodalIdentifier) (*versionQueue, error
 
 	// Having assembled the queue, search it for a valid version.
 	s.traceCheckQueue(q, bmi, false, 1)
+	s.explainQueue(q)
 	return q, s.findValidVersion(q, bmi.pl)
 }
 
@@ -931,6 +974,7 @@ func (s *solver) findValidVersion(q *versionQueue, pl []string) error {
 			// we have a good version, can return safely
 			return nil
 		}
+		s.explainReject(q.id, cur, err)
 
 		if q.advance(err) != nil {
 			// Error on advance, have to bail out
@@ -992,7 +1036,7 @@ func (s *solver) getLockVersionIfValid(id ProjectIdentifier) (Version, error) {
 		return nil, nil
 	}
 
-	constraint := s.sel.getConstraint(id)
+	constraint := s.getConstraint(id)
 	v := lp.Version()
 	if !constraint.Matches(v) {
 		// No match found, which means we're going to be breaking the lock
@@ -1073,6 +1117,7 @@ func (s *solver) backtrack(ctx context.Context) (bool, error) {
 
 		// Advance the queue past the current version, which we know is bad
 		// TODO(sdboyer) is it feasible to make available the failure reason here?
+		s.explainReject(q.id, awp.a.v, nil)
 		if q.advance(nil) == nil && !q.isExhausted() {
 			// Search for another acceptable version of this failed dep in its queue
 			s.traceCheckQueue(q, awp.bmi(), true, 0)
@@ -1089,6 +1134,7 @@ func (s *solver) backtrack(ctx context.Context) (bool, error) {
 					}
 					return false, err
 				}
+				s.explainSelect(q)
 				break
 			}
 		}
@@ -1313,6 +1359,9 @@ func (s *solver) unselectLast() (atomWithPackages, bool, error) {
 	defer s.mtr.pop()
 	awp, first := s.sel.popSelection()
 	heap.Push(s.unsel, bimodalIdentifier{id: awp.a.id, pl: awp.pl})
+	if first {
+		s.explainUnselect(awp.a.id)
+	}
 
 	_, deps, err := s.getImportsAndConstraintsOf(awp)
 	if err != nil {
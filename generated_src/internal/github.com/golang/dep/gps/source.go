@@ -8,10 +8,15 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 
 	"github.com/palantir/godel-dep-plugin/generated_src/internal/github.com/golang/dep/gps/pkgtree"
+	"github.com/palantir/godel-dep-plugin/generated_src/internal/github.com/golang/dep/internal/fs"
 	"github.com/pkg/errors"
 )
 
@@ -67,13 +72,16 @@ type sourceCoordinator struct {
 	psrcmut		sync.Mutex	// guards protoSrcs map
 	protoSrcs	map[string][]chan srcReturn
 	cachedir	string
+	sharedCachedir	string
+	sharedmut	sync.Mutex	// serializes copies from sharedCachedir
 	cache		sourceCache
 	logger		*log.Logger
+	offline		bool
 }
 
 // newSourceCoordinator returns a new sourceCoordinator.
 // Passing a nil sourceCache defaults to an in-memory cache.
-func newSourceCoordinator(superv *supervisor, deducer deducer, cachedir string, cache sourceCache, logger *log.Logger) *sourceCoordinator {
+func newSourceCoordinator(superv *supervisor, deducer deducer, cachedir, sharedCachedir string, cache sourceCache, logger *log.Logger, offline bool) *sourceCoordinator {
 	if cache == nil {
 		cache = memoryCache{}
 	}
@@ -81,8 +89,10 @@ func newSourceCoordinator(superv *supervisor, deducer deducer, cachedir string,
 		supervisor:	superv,
 		deducer:	deducer,
 		cachedir:	cachedir,
+		sharedCachedir:	sharedCachedir,
 		cache:		cache,
 		logger:		logger,
+		offline:	offline,
 		srcs:		make(map[string]*sourceGateway),
 		nameToURL:	make(map[string]string),
 		protoSrcs:	make(map[string][]chan srcReturn),
@@ -190,6 +200,10 @@ func (sc *sourceCoordinator) getSourceGatewayFor(ctx context.Context, id Project
 		return nil, err
 	}
 
+	if sc.sharedCachedir != "" {
+		sc.copySharedSource(pd.mb)
+	}
+
 	// It'd be quite the feat - but not impossible - for a gateway
 	// corresponding to this normalizedName to have slid into the main
 	// sources map after the initial unlock, but before this goroutine got
@@ -228,7 +242,7 @@ func (sc *sourceCoordinator) getSourceGatewayFor(ctx context.Context, id Project
 		src, err := m.try(ctx, sc.cachedir)
 		if err == nil {
 			cache := sc.cache.newSingleSourceCache(id)
-			srcGate, err = newSourceGateway(ctx, src, sc.supervisor, sc.cachedir, cache)
+			srcGate, err = newSourceGateway(ctx, src, sc.supervisor, sc.cachedir, cache, sc.offline)
 			if err == nil {
 				sc.srcs[url] = srcGate
 				break
@@ -259,6 +273,48 @@ func (sc *sourceCoordinator) getSourceGatewayFor(ctx context.Context, id Project
 	return srcGate, nil
 }
 
+// copySharedSource copies the local copy of the first of mb that exists in
+// the shared cache directory into the cache directory, unless a local copy of
+// a preceding one already exists there. The immutable objects of git
+// repositories are hardlinked rather than copied, when possible.
+//
+// Copies are serialized, so that sources deduced from different names do not
+// race to copy the same directory. Other processes are kept out of the cache
+// directory by the source manager's lock file.
+func (sc *sourceCoordinator) copySharedSource(mb maybeSources) {
+	sc.sharedmut.Lock()
+	defer sc.sharedmut.Unlock()
+	for _, m := range mb {
+		path := m.cachePath(sc.cachedir)
+		if _, err := os.Stat(path); err == nil {
+			return
+		}
+		shared := m.cachePath(sc.sharedCachedir)
+		if _, err := os.Stat(shared); err != nil {
+			continue
+		}
+
+		// Copy to a temporary directory first so that an interrupted copy is
+		// not mistaken for a complete one.
+		tmp, err := ioutil.TempDir(filepath.Dir(path), ".shared-")
+		if err != nil {
+			sc.logger.Println(errors.Wrapf(err, "failed to copy shared source %s", shared))
+			return
+		}
+		defer os.RemoveAll(tmp)
+		err = fs.CopyDirLinking(shared, filepath.Join(tmp, "src"), func(p string) bool {
+			return strings.Contains(filepath.ToSlash(p), "/.git/objects/")
+		})
+		if err == nil {
+			err = os.Rename(filepath.Join(tmp, "src"), path)
+		}
+		if err != nil {
+			sc.logger.Println(errors.Wrapf(err, "failed to copy shared source %s", shared))
+		}
+		return
+	}
+}
+
 // sourceGateways manage all incoming calls for data from sources, serializing
 // and caching them as needed.
 type sourceGateway struct {
@@ -268,11 +324,12 @@ type sourceGateway struct {
 	cache		singleSourceCache
 	mu		sync.Mutex	// global lock, serializes all behaviors
 	suprvsr		*supervisor
+	offline		bool	// never access upstream
 }
 
 // newSourceGateway returns a new gateway for src. If the source exists locally,
 // the local state may be cleaned, otherwise we ping upstream.
-func newSourceGateway(ctx context.Context, src source, superv *supervisor, cachedir string, cache singleSourceCache) (*sourceGateway, error) {
+func newSourceGateway(ctx context.Context, src source, superv *supervisor, cachedir string, cache singleSourceCache, offline bool) (*sourceGateway, error) {
 	var state sourceState
 	local := src.existsLocally(ctx)
 	if local {
@@ -290,6 +347,7 @@ func newSourceGateway(ctx context.Context, src source, superv *supervisor, cache
 		cachedir:	cachedir,
 		cache:		cache,
 		suprvsr:	superv,
+		offline:	offline,
 	}
 
 	if !local {
@@ -357,7 +415,7 @@ func (sg *sourceGateway) exportVersionTo(ctx context.Context, v Version, to stri
 	return err
 }
 
-func (sg *sourceGateway) exportPrunedVersionTo(ctx context.Context, lp LockedProject, prune PruneOptions, to string) error {
+func (sg *sourceGateway) exportPrunedVersionTo(ctx context.Context, lp LockedProject, prune PruneOptions, params PruneParams, to string) error {
 	sg.mu.Lock()
 	defer sg.mu.Unlock()
 
@@ -373,7 +431,7 @@ func (sg *sourceGateway) exportPrunedVersionTo(ctx context.Context, lp LockedPro
 
 	if fastprune, ok := sg.src.(sourceFastPrune); ok {
 		return sg.suprvsr.do(ctx, sg.src.upstreamURL(), ctExportTree, func(ctx context.Context) error {
-			return fastprune.exportPrunedRevisionTo(ctx, r, lp.Packages(), prune, to)
+			return fastprune.exportPrunedRevisionTo(ctx, r, lp.Packages(), prune, params, to)
 		})
 	}
 
@@ -383,7 +441,7 @@ func (sg *sourceGateway) exportPrunedVersionTo(ctx context.Context, lp LockedPro
 		return err
 	}
 
-	return PruneProject(to, lp, prune)
+	return PruneProject(to, lp, prune, params)
 }
 
 func (sg *sourceGateway) getManifestAndLock(ctx context.Context, pr ProjectRoot, v Version, an ProjectAnalyzer) (Manifest, Lock, error) {
@@ -571,6 +629,33 @@ func (sg *sourceGateway) revisionPresentIn(ctx context.Context, r Revision) (boo
 	return present, err
 }
 
+func (sg *sourceGateway) listCommits(ctx context.Context, from, to Revision) ([]Commit, error) {
+	sg.mu.Lock()
+	defer sg.mu.Unlock()
+
+	err := sg.require(ctx, sourceExistsLocally)
+	if err != nil {
+		return nil, err
+	}
+
+	// Either revision may be newer than the local copy of the source.
+	for _, r := range []Revision{from, to} {
+		if present, _ := sg.src.revisionPresentIn(r); !present {
+			if err = sg.require(ctx, sourceHasLatestLocally); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+
+	var commits []Commit
+	err = sg.suprvsr.do(ctx, sg.src.upstreamURL(), ctListCommits, func(ctx context.Context) error {
+		commits, err = sg.src.listCommits(ctx, from, to)
+		return err
+	})
+	return commits, err
+}
+
 func (sg *sourceGateway) disambiguateRevision(ctx context.Context, r Revision) (Revision, error) {
 	sg.mu.Lock()
 	defer sg.mu.Unlock()
@@ -647,16 +732,35 @@ func (sg *sourceGateway) require(ctx context.Context, wanted sourceState) (err e
 
 			switch flag {
 			case sourceExistsUpstream:
-				addlState, err = sg.sourceExistsUpstream(ctx)
+				if sg.offline {
+					// The local copy stands in for upstream.
+					if !sg.src.existsLocally(ctx) {
+						err = sg.offlineError("checking upstream for")
+					}
+				} else {
+					addlState, err = sg.sourceExistsUpstream(ctx)
+				}
 			case sourceExistsLocally:
 				if !sg.src.existsLocally(ctx) {
-					addlState, err = sg.initLocal(ctx)
+					if sg.offline {
+						err = sg.offlineError("cloning")
+					} else {
+						addlState, err = sg.initLocal(ctx)
+					}
 				}
 			case sourceHasLatestVersionList:
 				if _, ok := sg.cache.getAllVersions(); !ok {
-					addlState, err = sg.loadLatestVersionList(ctx)
+					if sg.offline {
+						err = sg.offlineError("listing the versions of")
+					} else {
+						addlState, err = sg.loadLatestVersionList(ctx)
+					}
 				}
 			case sourceHasLatestLocally:
+				if sg.offline {
+					err = sg.offlineError("fetching updates for")
+					break
+				}
 				err = sg.suprvsr.do(ctx, sg.src.sourceType(), ctSourceFetch, func(ctx context.Context) error {
 					return sg.src.updateLocal(ctx)
 				})
@@ -678,6 +782,23 @@ func (sg *sourceGateway) require(ctx context.Context, wanted sourceState) (err e
 	return nil
 }
 
+func (sg *sourceGateway) offlineError(op string) error {
+	return &OfflineError{Op: op, Source: sg.src.upstreamURL()}
+}
+
+// OfflineError indicates that an operation required network access, which is
+// disabled because the SourceManager is offline.
+type OfflineError struct {
+	// Op describes the operation, for example "fetching updates for".
+	Op	string
+	// Source is the upstream URL or the import path the operation is for.
+	Source	string
+}
+
+func (e *OfflineError) Error() string {
+	return fmt.Sprintf("%s %s requires network access, which is disabled in offline mode", e.Op, e.Source)
+}
+
 // source is an abstraction around the different underlying types (git, bzr, hg,
 // svn, maybe raw on-disk code, and maybe eventually a registry) that can
 // provide versioned project source trees.
@@ -695,6 +816,9 @@ type source interface {
 	revisionPresentIn(Revision) (bool, error)
 	disambiguateRevision(context.Context, Revision) (Revision, error)
 	exportRevisionTo(context.Context, Revision, string) error
+	// listCommits returns an *UnsupportedCommitsError when the underlying
+	// source does not support listing commits.
+	listCommits(ctx context.Context, from, to Revision) ([]Commit, error)
 	sourceType() string
 	// existsCallsListVersions returns true if calling existsUpstream actually lists
 	// versions underneath, meaning listVersions might as well be used instead.
@@ -706,5 +830,5 @@ type source interface {
 
 type sourceFastPrune interface {
 	source
-	exportPrunedRevisionTo(context.Context, Revision, []string, PruneOptions, string) error
+	exportPrunedRevisionTo(context.Context, Revision, []string, PruneOptions, PruneParams, string) error
 }
@@ -56,6 +56,22 @@ func newBoltCache(cd string, epoch int64, logger *log.Logger) (*boltCache, error
 	}, nil
 }
 
+// newReadOnlyBoltCache returns a new boltCache backed by an existing BoltDB file
+// under the cache directory, which is opened read-only so that it may be shared
+// with other processes and machines. Writes to the returned cache fail.
+func newReadOnlyBoltCache(cd string, epoch int64, logger *log.Logger) (*boltCache, error) {
+	path := filepath.Join(cd, boltCacheFilename)
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 1 * time.Second, ReadOnly: true})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open BoltDB cache file %q", path)
+	}
+	return &boltCache{
+		db:	db,
+		epoch:	epoch,
+		logger:	logger,
+	}, nil
+}
+
 // newSingleSourceCache returns a new singleSourceCache for pi.
 func (c *boltCache) newSingleSourceCache(pi ProjectIdentifier) singleSourceCache {
 	return &singleSourceCacheBolt{
@@ -11,18 +11,22 @@ import (
 // multiCache creates singleSourceMultiCaches, and coordinates their async updates.
 type multiCache struct {
 	mem, disk	sourceCache
+	// Optional read-only cache consulted after disk, or nil.
+	shared	sourceCache
 	// Asynchronous disk cache updates. Closed by the close method.
 	async	chan func()
 	// Closed when async has completed processing.
 	done	chan struct{}
 }
 
-// newMultiCache returns a new multiCache backed by mem and disk sourceCaches.
+// newMultiCache returns a new multiCache backed by mem and disk sourceCaches,
+// and by the shared sourceCache, which is never written to, if it is not nil.
 // Spawns a single background goroutine which lives until close() is called.
-func newMultiCache(mem, disk sourceCache) *multiCache {
+func newMultiCache(mem, disk, shared sourceCache) *multiCache {
 	m := &multiCache{
 		mem:	mem,
 		disk:	disk,
+		shared:	shared,
 		async:	make(chan func(), 50),
 		done:	make(chan struct{}),
 	}
@@ -42,28 +46,40 @@ func (c *multiCache) close() error {
 	close(c.async)
 	_ = c.mem.close()
 	<-c.done
+	if c.shared != nil {
+		_ = c.shared.close()
+	}
 	return c.disk.close()
 }
 
 // newSingleSourceCache returns a singleSourceMultiCache for id.
 func (c *multiCache) newSingleSourceCache(id ProjectIdentifier) singleSourceCache {
-	return &singleSourceMultiCache{
+	ssc := &singleSourceMultiCache{
 		mem:	c.mem.newSingleSourceCache(id),
 		disk:	c.disk.newSingleSourceCache(id),
 		async:	c.async,
 	}
+	if c.shared != nil {
+		ssc.shared = c.shared.newSingleSourceCache(id)
+	}
+	return ssc
 }
 
 // singleSourceMultiCache manages two cache levels, ephemeral in-memory and persistent on-disk.
 //
-// The in-memory cache is always checked first, with the on-disk used as a fallback.
-// Values read from disk are set in-memory when an appropriate method exists.
+// The in-memory cache is always checked first, with the on-disk used as a fallback,
+// and then the shared cache, if any. Values read from disk or from the shared cache
+// are set in-memory when an appropriate method exists. Manifests, locks and
+// package trees read from the shared cache are also set on-disk, since they never
+// change for a given revision.
 //
 // Set values are cached both in-memory and on-disk. Values are set synchronously
 // in-memory. Writes to the on-disk cache are asynchronous, and executed in order by a
 // background goroutine.
 type singleSourceMultiCache struct {
 	mem, disk	singleSourceCache
+	// Read-only, or nil.
+	shared	singleSourceCache
 	// Asynchronous disk cache updates.
 	async	chan<- func()
 }
@@ -85,6 +101,14 @@ func (c *singleSourceMultiCache) getManifestAndLock(r Revision, ai ProjectAnalyz
 		return m, l, true
 	}
 
+	if c.shared != nil {
+		m, l, ok = c.shared.getManifestAndLock(r, ai)
+		if ok {
+			c.setManifestAndLock(r, ai, m, l)
+			return m, l, true
+		}
+	}
+
 	return nil, nil, false
 }
 
@@ -105,6 +129,14 @@ func (c *singleSourceMultiCache) getPackageTree(r Revision, pr ProjectRoot) (pkg
 		return ptree, true
 	}
 
+	if c.shared != nil {
+		ptree, ok = c.shared.getPackageTree(r, pr)
+		if ok {
+			c.setPackageTree(r, ptree)
+			return ptree, true
+		}
+	}
+
 	return pkgtree.PackageTree{}, false
 }
 
@@ -124,7 +156,11 @@ func (c *singleSourceMultiCache) getVersionsFor(rev Revision) ([]UnpairedVersion
 		return uvs, true
 	}
 
-	return c.disk.getVersionsFor(rev)
+	uvs, ok = c.disk.getVersionsFor(rev)
+	if ok || c.shared == nil {
+		return uvs, ok
+	}
+	return c.shared.getVersionsFor(rev)
 }
 
 func (c *singleSourceMultiCache) getAllVersions() ([]PairedVersion, bool) {
@@ -139,6 +175,16 @@ func (c *singleSourceMultiCache) getAllVersions() ([]PairedVersion, bool) {
 		return pvs, true
 	}
 
+	if c.shared != nil {
+		// The version list is not set on-disk, where it would appear to be
+		// fresher than it is.
+		pvs, ok = c.shared.getAllVersions()
+		if ok {
+			c.mem.setVersionMap(pvs)
+			return pvs, true
+		}
+	}
+
 	return nil, false
 }
 
@@ -148,7 +194,11 @@ func (c *singleSourceMultiCache) getRevisionFor(uv UnpairedVersion) (Revision, b
 		return rev, true
 	}
 
-	return c.disk.getRevisionFor(uv)
+	rev, ok = c.disk.getRevisionFor(uv)
+	if ok || c.shared == nil {
+		return rev, ok
+	}
+	return c.shared.getRevisionFor(uv)
 }
 
 func (c *singleSourceMultiCache) toRevision(v Version) (Revision, bool) {
@@ -157,7 +207,11 @@ func (c *singleSourceMultiCache) toRevision(v Version) (Revision, bool) {
 		return rev, true
 	}
 
-	return c.disk.toRevision(v)
+	rev, ok = c.disk.toRevision(v)
+	if ok || c.shared == nil {
+		return rev, ok
+	}
+	return c.shared.toRevision(v)
 }
 
 func (c *singleSourceMultiCache) toUnpaired(v Version) (UnpairedVersion, bool) {
@@ -166,5 +220,28 @@ func (c *singleSourceMultiCache) toUnpaired(v Version) (UnpairedVersion, bool) {
 		return uv, true
 	}
 
-	return c.disk.toUnpaired(v)
+	uv, ok = c.disk.toUnpaired(v)
+	if ok || c.shared == nil {
+		return uv, ok
+	}
+	return c.shared.toUnpaired(v)
 }
+
+// readOnlyCache wraps a sourceCache, discarding all writes to it.
+type readOnlyCache struct {
+	sourceCache
+}
+
+func (c readOnlyCache) newSingleSourceCache(id ProjectIdentifier) singleSourceCache {
+	return readOnlySingleSourceCache{c.sourceCache.newSingleSourceCache(id)}
+}
+
+// readOnlySingleSourceCache wraps a singleSourceCache, discarding all writes to it.
+type readOnlySingleSourceCache struct {
+	singleSourceCache
+}
+
+func (readOnlySingleSourceCache) setManifestAndLock(Revision, ProjectAnalyzerInfo, Manifest, Lock)	{}
+func (readOnlySingleSourceCache) setPackageTree(Revision, pkgtree.PackageTree)				{}
+func (readOnlySingleSourceCache) markRevisionExists(Revision)						{}
+func (readOnlySingleSourceCache) setVersionMap([]PairedVersion)						{}
@@ -106,12 +106,12 @@ type SourceManager interface {
 
 	// ExportPrunedProject writes out the tree corresponding to the provided
 	// LockedProject, the provided version, to the provided directory, applying
-	// the provided pruning options.
+	// the provided pruning options and parameters.
 	//
 	// The first return value is the hex-encoded string representation of the
 	// hash, including colon-separated leaders indicating the version of the
 	// hashing function used, and the prune options that were applied.
-	ExportPrunedProject(context.Context, LockedProject, PruneOptions, string) error
+	ExportPrunedProject(context.Context, LockedProject, PruneOptions, PruneParams, string) error
 
 	// DeduceProjectRoot takes an import path and deduces the corresponding
 	// project/source root.
@@ -190,6 +190,8 @@ type SourceManagerConfig struct {
 	Cachedir	string		// Where to store local instances of upstream sources.
 	Logger		*log.Logger	// Optional info/warn logger. Discards if nil.
 	DisableLocking	bool		// True if the SourceManager should NOT use a lock file to protect the Cachedir from multiple processes.
+	Offline		bool		// True if the SourceManager should NOT access the network, and only use local copies of sources and the persistent cache.
+	SharedCachedir	string		// Optional read-only cache directory, for example shared by a team, used when data is not in Cachedir.
 }
 
 // NewSourceManager produces an instance of gps's built-in SourceManager.
@@ -204,6 +206,13 @@ type SourceManagerConfig struct {
 // is discarded. When cacheAge is <= 0, the persistent cache is
 // not used.
 //
+// If a shared cache directory is configured, its persistent cache is consulted
+// after the local one, and its local copies of sources are copied into the
+// cache directory instead of being cloned. The shared cache directory is never
+// written to; it is typically populated by another SourceManager that uses it
+// as its cache directory. Its version lists are only used if they are younger
+// than cacheAge.
+//
 // gps's SourceManager is intended to be threadsafe (if it's not, please file a
 // bug!). It should be safe to reuse across concurrent solving runs, even on
 // unrelated projects.
@@ -291,19 +300,37 @@ func NewSourceManager(c SourceManagerConfig) (*SourceMgr, error) {
 
 	ctx, cf := context.WithCancel(context.TODO())
 	superv := newSupervisor(ctx)
-	deducer := newDeductionCoordinator(superv)
+	deducer := newDeductionCoordinator(superv, c.Offline)
+
+	epoch := time.Now().Add(-c.CacheAge).Unix()
+	var shared sourceCache
+	if c.SharedCachedir != "" {
+		sharedEpoch := epoch
+		if c.CacheAge <= 0 {
+			// Only use data that does not expire.
+			sharedEpoch = time.Now().Unix()
+		}
+		boltCache, err := newReadOnlyBoltCache(c.SharedCachedir, sharedEpoch, c.Logger)
+		if err != nil {
+			c.Logger.Println(errors.Wrapf(err, "failed to open shared persistent cache %q", c.SharedCachedir))
+		} else {
+			shared = readOnlyCache{boltCache}
+		}
+	}
 
 	var sc sourceCache
 	if c.CacheAge > 0 {
 		// Try to open the BoltDB cache from disk.
-		epoch := time.Now().Add(-c.CacheAge).Unix()
 		boltCache, err := newBoltCache(c.Cachedir, epoch, c.Logger)
 		if err != nil {
 			c.Logger.Println(errors.Wrapf(err, "failed to open persistent cache %q", c.Cachedir))
 		} else {
-			sc = newMultiCache(memoryCache{}, boltCache)
+			sc = newMultiCache(memoryCache{}, boltCache, shared)
 		}
 	}
+	if sc == nil && shared != nil {
+		sc = newMultiCache(memoryCache{}, shared, nil)
+	}
 
 	sm := &SourceMgr{
 		cachedir:	c.Cachedir,
@@ -311,7 +338,7 @@ func NewSourceManager(c SourceManagerConfig) (*SourceMgr, error) {
 		suprvsr:	superv,
 		cancelAll:	cf,
 		deduceCoord:	deducer,
-		srcCoord:	newSourceCoordinator(superv, deducer, c.Cachedir, sc, c.Logger),
+		srcCoord:	newSourceCoordinator(superv, deducer, c.Cachedir, c.SharedCachedir, sc, c.Logger, c.Offline),
 		qch:		make(chan struct{}),
 	}
 
@@ -505,6 +532,29 @@ func (sm *SourceMgr) RevisionPresentIn(id ProjectIdentifier, r Revision) (bool,
 	return srcg.revisionPresentIn(context.TODO(), r)
 }
 
+// ListCommits returns the commits of the given project's source that are
+// reachable from the revision to but not from the revision from, newest first.
+// It is read from the local cache of the source, which is updated from
+// upstream if either revision is not yet present in it.
+//
+// If from is not an ancestor of to, the result does not include the commits
+// that are only reachable from from; swap the revisions to list those.
+//
+// An *UnsupportedCommitsError is returned for sources that cannot list
+// commits.
+func (sm *SourceMgr) ListCommits(id ProjectIdentifier, from, to Revision) ([]Commit, error) {
+	if atomic.LoadInt32(&sm.releasing) == 1 {
+		return nil, ErrSourceManagerIsReleased
+	}
+
+	srcg, err := sm.srcCoord.getSourceGatewayFor(context.TODO(), id)
+	if err != nil {
+		return nil, err
+	}
+
+	return srcg.listCommits(context.TODO(), from, to)
+}
+
 // SourceExists checks if a repository exists, either upstream or in the cache,
 // for the provided ProjectIdentifier.
 func (sm *SourceMgr) SourceExists(id ProjectIdentifier) (bool, error) {
@@ -561,7 +611,7 @@ func (sm *SourceMgr) ExportProject(ctx context.Context, id ProjectIdentifier, v
 
 // ExportPrunedProject writes out a tree of the provided LockedProject, applying
 // provided pruning rules as appropriate.
-func (sm *SourceMgr) ExportPrunedProject(ctx context.Context, lp LockedProject, prune PruneOptions, to string) error {
+func (sm *SourceMgr) ExportPrunedProject(ctx context.Context, lp LockedProject, prune PruneOptions, params PruneParams, to string) error {
 	if atomic.LoadInt32(&sm.releasing) == 1 {
 		return ErrSourceManagerIsReleased
 	}
@@ -571,7 +621,7 @@ func (sm *SourceMgr) ExportPrunedProject(ctx context.Context, lp LockedProject,
 		return err
 	}
 
-	return srcg.exportPrunedVersionTo(ctx, lp, prune, to)
+	return srcg.exportPrunedVersionTo(ctx, lp, prune, params, to)
 }
 
 // DeduceProjectRoot takes an import path and deduces the corresponding
@@ -798,6 +848,7 @@ const (
 	ctSourceFetch
 	ctExportTree
 	ctValidateLocal
+	ctListCommits
 )
 
 func (ct callType) String() string {
@@ -818,6 +869,8 @@ func (ct callType) String() string {
 		return "Fetching latest data into local source cache"
 	case ctExportTree:
 		return "Writing code tree out to disk"
+	case ctListCommits:
+		return "Listing commits"
 	default:
 		panic("unknown calltype")
 	}
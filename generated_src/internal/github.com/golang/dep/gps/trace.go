@@ -22,6 +22,12 @@ const (
 )
 
 func (s *solver) traceCheckPkgs(bmi bimodalIdentifier) {
+	if s.tr != nil {
+		e := atomEvent(TraceCheckPackages, len(s.vqs)+1, atom{id: bmi.id})
+		e.Packages = len(bmi.pl)
+		e.PackagesOnly = true
+		s.tr.Trace(e)
+	}
 	if s.tl == nil {
 		return
 	}
@@ -31,6 +37,14 @@ func (s *solver) traceCheckPkgs(bmi bimodalIdentifier) {
 }
 
 func (s *solver) traceCheckQueue(q *versionQueue, bmi bimodalIdentifier, cont bool, offset int) {
+	if s.tr != nil {
+		e := atomEvent(TraceCheckQueue, len(s.vqs)+offset, atom{id: bmi.id})
+		e.Packages = len(bmi.pl)
+		e.Versions = len(q.pi)
+		e.VersionsComplete = q.allLoaded
+		e.Continue = cont
+		s.tr.Trace(e)
+	}
 	if s.tl == nil {
 		return
 	}
@@ -59,6 +73,13 @@ func (s *solver) traceCheckQueue(q *versionQueue, bmi bimodalIdentifier, cont bo
 // traceStartBacktrack is called with the bmi that first failed, thus initiating
 // backtracking
 func (s *solver) traceStartBacktrack(bmi bimodalIdentifier, err error, pkgonly bool) {
+	if s.tr != nil {
+		e := atomEvent(TraceBacktrackStart, len(s.sel.projects), atom{id: bmi.id})
+		e.Packages = len(bmi.pl)
+		e.PackagesOnly = pkgonly
+		e.Failure = traceFailure(err)
+		s.tr.Trace(e)
+	}
 	if s.tl == nil {
 		return
 	}
@@ -77,6 +98,12 @@ func (s *solver) traceStartBacktrack(bmi bimodalIdentifier, err error, pkgonly b
 // traceBacktrack is called when a package or project is poppped off during
 // backtracking
 func (s *solver) traceBacktrack(bmi bimodalIdentifier, pkgonly bool) {
+	if s.tr != nil {
+		e := atomEvent(TraceBacktrackPop, len(s.sel.projects), atom{id: bmi.id})
+		e.Packages = len(bmi.pl)
+		e.PackagesOnly = pkgonly
+		s.tr.Trace(e)
+	}
 	if s.tl == nil {
 		return
 	}
@@ -92,8 +119,43 @@ func (s *solver) traceBacktrack(bmi bimodalIdentifier, pkgonly bool) {
 	s.tl.Printf("%s\n", tracePrefix(msg, prefix, prefix))
 }
 
+// traceEndBacktrack is called when backtracking ends. It has no counterpart in
+// the text trace, where the next selection or the end of the solve follows.
+func (s *solver) traceEndBacktrack(success bool, err error) {
+	if s.tr == nil {
+		return
+	}
+
+	e := TraceEvent{
+		Type:		TraceBacktrackEnd,
+		Depth:		len(s.sel.projects),
+		Success:	success,
+		Attempts:	s.attempts,
+	}
+	if err != nil {
+		e.Failure = traceFailure(err)
+	}
+	s.tr.Trace(e)
+}
+
 // Called just once after solving has finished, whether success or not
 func (s *solver) traceFinish(sol solution, err error) {
+	if s.tr != nil {
+		e := TraceEvent{
+			Type:		TraceFinish,
+			Success:	err == nil,
+			Attempts:	s.attempts,
+		}
+		if err == nil {
+			for _, lp := range sol.Projects() {
+				e.Packages += len(lp.Packages())
+			}
+			e.Projects = len(sol.Projects())
+		} else {
+			e.Failure = traceFailure(err)
+		}
+		s.tr.Trace(e)
+	}
 	if s.tl == nil {
 		return
 	}
@@ -111,6 +173,14 @@ func (s *solver) traceFinish(sol solution, err error) {
 
 // traceSelectRoot is called just once, when the root project is selected
 func (s *solver) traceSelectRoot(ptree pkgtree.PackageTree, cdeps []completeDep) {
+	if s.tr != nil {
+		e := atomEvent(TraceSelectRoot, 0, s.rd.rootAtom().a)
+		e.Projects = len(cdeps)
+		for _, cdep := range cdeps {
+			e.Packages += len(cdep.pl)
+		}
+		s.tr.Trace(e)
+	}
 	if s.tl == nil {
 		return
 	}
@@ -134,6 +204,12 @@ func (s *solver) traceSelectRoot(ptree pkgtree.PackageTree, cdeps []completeDep)
 
 // traceSelect is called when an atom is successfully selected
 func (s *solver) traceSelect(awp atomWithPackages, pkgonly bool) {
+	if s.tr != nil {
+		e := atomEvent(TraceSelectAtom, len(s.sel.projects)-1, awp.a)
+		e.Packages = len(awp.pl)
+		e.PackagesOnly = pkgonly
+		s.tr.Trace(e)
+	}
 	if s.tl == nil {
 		return
 	}
@@ -149,6 +225,19 @@ func (s *solver) traceSelect(awp atomWithPackages, pkgonly bool) {
 	s.tl.Printf("%s\n", tracePrefix(msg, prefix, prefix))
 }
 
+// traceReject is called when an atom fails a satisfiability check
+func (s *solver) traceReject(awp atomWithPackages, pkgonly bool, err error) {
+	if s.tr == nil {
+		return
+	}
+
+	e := atomEvent(TraceRejectVersion, len(s.sel.projects), awp.a)
+	e.Packages = len(awp.pl)
+	e.PackagesOnly = pkgonly
+	e.Failure = traceFailure(err)
+	s.tr.Trace(e)
+}
+
 func (s *solver) traceInfo(args ...interface{}) {
 	if s.tl == nil {
 		return
@@ -0,0 +1,135 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// TraceEventType identifies the kind of a TraceEvent.
+type TraceEventType string
+
+const (
+	// TraceSelectRoot is emitted once, when the root project is selected.
+	TraceSelectRoot	TraceEventType	= "select-root"
+	// TraceCheckQueue is emitted when the solver starts or continues to search
+	// the version queue of a project for an acceptable version.
+	TraceCheckQueue	TraceEventType	= "check-queue"
+	// TraceCheckPackages is emitted when the solver revisits a selected
+	// project to add packages to it.
+	TraceCheckPackages	TraceEventType	= "check-packages"
+	// TraceRejectVersion is emitted when a version fails a satisfiability
+	// check.
+	TraceRejectVersion	TraceEventType	= "reject-version"
+	// TraceSelectAtom is emitted when a version of a project, or more packages
+	// of an already selected project, are selected.
+	TraceSelectAtom	TraceEventType	= "select-atom"
+	// TraceBacktrackStart is emitted when a failure causes the solver to
+	// start backtracking.
+	TraceBacktrackStart	TraceEventType	= "backtrack-start"
+	// TraceBacktrackPop is emitted when a selection is popped while
+	// backtracking.
+	TraceBacktrackPop	TraceEventType	= "backtrack-pop"
+	// TraceBacktrackEnd is emitted when backtracking ends, either because a
+	// new version was selected or because there was nothing left to try.
+	TraceBacktrackEnd	TraceEventType	= "backtrack-end"
+	// TraceFinish is emitted once, when solving has finished.
+	TraceFinish	TraceEventType	= "finish"
+)
+
+// TraceEvent is a structured record of a step of a solve run. Fields that do
+// not apply to the Type of the event are left empty.
+type TraceEvent struct {
+	Type	TraceEventType	`json:"event"`
+	// Depth is the number of projects that are selected, as shown in the
+	// prefix of the text trace.
+	Depth	int	`json:"depth"`
+	// Project is the root of the project the event is about.
+	Project	string	`json:"project,omitempty"`
+	// Source is the alternate source of Project, if any.
+	Source	string	`json:"source,omitempty"`
+	// Version and Revision identify the version of Project.
+	Version		string	`json:"version,omitempty"`
+	Revision	string	`json:"revision,omitempty"`
+	// Packages is the number of packages involved in the event.
+	Packages	int	`json:"packages,omitempty"`
+	// PackagesOnly is true if the event concerns packages being added to an
+	// already selected project.
+	PackagesOnly	bool	`json:"packagesOnly,omitempty"`
+	// Versions is the number of versions left to try in a version queue, and
+	// VersionsComplete is true if that number includes every version.
+	Versions		int	`json:"versions,omitempty"`
+	VersionsComplete	bool	`json:"versionsComplete,omitempty"`
+	// Continue is true if a version queue is being searched again after
+	// backtracking.
+	Continue	bool	`json:"continue,omitempty"`
+	// Success reports the outcome of backtracking or of the solve. It is
+	// always written, so that a failure is not mistaken for a missing field.
+	Success	bool	`json:"success"`
+	// Failure describes the failure that caused a version to be rejected,
+	// backtracking to start or the solve to fail.
+	Failure	string	`json:"failure,omitempty"`
+	// Projects is the number of projects imported by the root project, or
+	// the number of projects in the solution.
+	Projects	int	`json:"projects,omitempty"`
+	// Attempts is the number of times the solver backtracked and moved
+	// forward again.
+	Attempts	int	`json:"attempts,omitempty"`
+}
+
+// A Tracer receives structured trace events from the solver. It is the
+// machine-readable counterpart of SolveParameters.TraceLogger.
+type Tracer interface {
+	Trace(TraceEvent)
+}
+
+type jsonLinesTracer struct {
+	mu	sync.Mutex
+	enc	*json.Encoder
+}
+
+// NewJSONLinesTracer returns a Tracer that writes each event to w as a single
+// line of JSON. Write errors are ignored.
+func NewJSONLinesTracer(w io.Writer) Tracer {
+	return &jsonLinesTracer{
+		enc: json.NewEncoder(w),
+	}
+}
+
+func (t *jsonLinesTracer) Trace(e TraceEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.enc.Encode(e)
+}
+
+// atomEvent returns a TraceEvent that identifies the provided atom.
+func atomEvent(typ TraceEventType, depth int, a atom) TraceEvent {
+	e := TraceEvent{
+		Type:		typ,
+		Depth:		depth,
+		Project:	string(a.id.ProjectRoot),
+		Source:		a.id.Source,
+	}
+	switch v := a.v.(type) {
+	case nil:
+	case Revision:
+		e.Revision = string(v)
+	case PairedVersion:
+		e.Version = v.Unpair().String()
+		e.Revision = string(v.Revision())
+	default:
+		e.Version = v.String()
+	}
+	return e
+}
+
+func traceFailure(err error) string {
+	if terr, ok := err.(traceError); ok {
+		return terr.traceString()
+	}
+	return err.Error()
+}
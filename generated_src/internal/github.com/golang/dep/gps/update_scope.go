@@ -0,0 +1,73 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"fmt"
+)
+
+// UpdateScope limits how far the solver may move a project that is allowed to
+// change (by ToChange or ChangeAll) away from its version in the root lock.
+//
+// Scopes only apply to projects whose locked version is a semantic version;
+// other projects may change to any version allowed by their constraints.
+type UpdateScope uint8
+
+const (
+	// UpdateAny allows any version that is allowed by the constraints.
+	UpdateAny	UpdateScope	= iota
+	// UpdateMinor only allows versions that have the same major version as
+	// the locked version and are not older than it.
+	UpdateMinor
+	// UpdatePatch only allows versions that have the same major and minor
+	// versions as the locked version and are not older than it.
+	UpdatePatch
+)
+
+// scopeConstraint returns the constraint that limits a project locked at the
+// provided version to the provided scope, or nil if there is no limit.
+func scopeConstraint(v Version, scope UpdateScope) Constraint {
+	var sv semVersion
+	switch tv := v.(type) {
+	case semVersion:
+		sv = tv
+	case versionPair:
+		tsv, ok := tv.v.(semVersion)
+		if !ok {
+			return nil
+		}
+		sv = tsv
+	default:
+		return nil
+	}
+
+	var upper string
+	switch scope {
+	case UpdateMinor:
+		upper = fmt.Sprintf("%d.0.0", sv.sv.Major()+1)
+	case UpdatePatch:
+		upper = fmt.Sprintf("%d.%d.0", sv.sv.Major(), sv.sv.Minor()+1)
+	default:
+		return nil
+	}
+
+	c, err := NewSemverConstraint(fmt.Sprintf(">=%s, <%s", sv.sv.String(), upper))
+	if err != nil {
+		// Can only happen if the locked version does not round-trip, which
+		// semver guarantees it does.
+		panic(fmt.Sprintf("canary - invalid update scope constraint for %s: %s", sv, err))
+	}
+	return c
+}
+
+// getConstraint returns the intersection of the constraints on the provided
+// project in the current selection and of its update scope, if it has one.
+func (s *solver) getConstraint(id ProjectIdentifier) Constraint {
+	c := s.sel.getConstraint(id)
+	if sc, has := s.rd.scope[id.ProjectRoot]; has {
+		c = c.Intersect(sc)
+	}
+	return c
+}
@@ -33,6 +33,13 @@ type ensureCleaner interface {
 	ensureClean(context.Context) error
 }
 
+// commitLister is an optional extension of ctxRepo.
+type commitLister interface {
+	// listCommits returns the commits that are reachable from to but not from
+	// from, newest first.
+	listCommits(ctx context.Context, from, to Revision) ([]Commit, error)
+}
+
 // original implementation of these methods come from
 // https://github.com/Masterminds/vcs
 
@@ -224,6 +231,17 @@ func (r *gitRepo) ensureClean(ctx context.Context) error {
 	return nil
 }
 
+func (r *gitRepo) listCommits(ctx context.Context, from, to Revision) ([]Commit, error) {
+	cmd := commandContext(ctx, "git", "log", "--format=%H%x00%s", string(from)+".."+string(to), "--")
+	cmd.SetDir(r.LocalPath())
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, newVcsLocalErrorOr(err, cmd.Args(), string(out),
+			"unable to list commits")
+	}
+	return parseCommitLines(out)
+}
+
 type bzrRepo struct {
 	*vcs.BzrRepo
 }
@@ -266,6 +284,31 @@ func (r *bzrRepo) updateVersion(ctx context.Context, version string) error {
 	return nil
 }
 
+func (r *bzrRepo) listCommits(ctx context.Context, from, to Revision) ([]Commit, error) {
+	// The range includes from itself, which is dropped below. Merged
+	// revisions are listed as well, as they are by git and hg.
+	cmd := commandContext(ctx, "bzr", "log", "--log-format=long", "--show-ids", "--levels=0",
+		"-r", "revid:"+string(from)+"..revid:"+string(to))
+	cmd.SetDir(r.LocalPath())
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, newVcsLocalErrorOr(err, cmd.Args(), string(out),
+			"unable to list commits")
+	}
+
+	commits, err := parseBzrLog(out)
+	if err != nil {
+		return nil, err
+	}
+	filtered := commits[:0]
+	for _, c := range commits {
+		if c.Revision != from {
+			filtered = append(filtered, c)
+		}
+	}
+	return filtered, nil
+}
+
 type hgRepo struct {
 	*vcs.HgRepo
 }
@@ -301,6 +344,21 @@ func (r *hgRepo) updateVersion(ctx context.Context, version string) error {
 	return nil
 }
 
+func (r *hgRepo) listCommits(ctx context.Context, from, to Revision) ([]Commit, error) {
+	cmd := commandContext(ctx, "hg", "log", "-r", "reverse(only("+string(to)+", "+string(from)+"))",
+		"--template", "{node}\\0{desc|firstline}\\n")
+	cmd.SetDir(r.LocalPath())
+	// Let's make sure extensions don't interfere with our expectations
+	// regarding the output of commands.
+	cmd.Cmd.Env = append(cmd.Cmd.Env, "HGRCPATH=")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, newVcsLocalErrorOr(err, cmd.Args(), string(out),
+			"unable to list commits")
+	}
+	return parseCommitLines(out)
+}
+
 type svnRepo struct {
 	*vcs.SvnRepo
 }
@@ -123,6 +123,19 @@ func (bs *baseVCSSource) maybeClean(ctx context.Context) error {
 	return nil
 }
 
+func (bs *baseVCSSource) listCommits(ctx context.Context, from, to Revision) ([]Commit, error) {
+	cl, ok := bs.repo.(commitLister)
+	if !ok {
+		return nil, &UnsupportedCommitsError{SourceType: bs.sourceType()}
+	}
+
+	commits, err := cl.listCommits(ctx, from, to)
+	if err != nil {
+		return nil, unwrapVcsErr(err)
+	}
+	return commits, nil
+}
+
 func (bs *baseVCSSource) listPackages(ctx context.Context, pr ProjectRoot, r Revision) (ptree pkgtree.PackageTree, err error) {
 	err = bs.repo.updateVersion(ctx, r.String())
 
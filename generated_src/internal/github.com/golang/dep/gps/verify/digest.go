@@ -14,9 +14,11 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"runtime"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/pkg/errors"
 )
@@ -25,7 +27,23 @@ import (
 // the directory hasher.
 //
 //   1: SHA256, as implemented in crypto/sha256
-const HashVersion = 1
+//   2: SHA256, as implemented in crypto/sha256, in which each regular file is
+//      represented by the SHA256 of its contents instead of by its contents
+//      followed by their length, so that the contents of a file can never be
+//      read as other nodes of the directory
+const HashVersion = 2
+
+// oldestHashVersion is the oldest hash version whose digests can be computed.
+// Digests of older supported versions remain valid, and are only replaced by
+// digests of HashVersion when their project is written again or the lock is
+// migrated.
+const oldestHashVersion = 1
+
+// IsSupportedHashVersion returns true if digests of the specified hash version
+// can be computed.
+func IsSupportedHashVersion(version int) bool {
+	return version >= oldestHashVersion && version <= HashVersion
+}
 
 const osPathSeparator = string(filepath.Separator)
 
@@ -141,6 +159,7 @@ type dirWalkClosure struct {
 	someModeBytes	[]byte	// allocate once and reuse for each node
 	someDirLen	int
 	someHash	hash.Hash
+	someFileHash	hash.Hash	// hash of the contents of each file, nil for version 1
 }
 
 // DigestFromDirectory returns a hash of the specified directory contents, which
@@ -158,6 +177,15 @@ type dirWalkClosure struct {
 // Symbolic links are excluded, as they are not considered valid elements in the
 // definition of a Go module.
 func DigestFromDirectory(osDirname string) (VersionedDigest, error) {
+	return DigestFromDirectoryVersion(osDirname, HashVersion)
+}
+
+// DigestFromDirectoryVersion is like DigestFromDirectory, but computes a digest
+// of the specified hash version, which must be supported.
+func DigestFromDirectoryVersion(osDirname string, version int) (VersionedDigest, error) {
+	if !IsSupportedHashVersion(version) {
+		return VersionedDigest{}, errors.Errorf("unsupported hash version %d", version)
+	}
 	osDirname = filepath.Clean(osDirname)
 
 	// Create a single hash instance for the entire operation, rather than a new
@@ -169,6 +197,9 @@ func DigestFromDirectory(osDirname string) (VersionedDigest, error) {
 		someDirLen:	len(osDirname) + len(osPathSeparator),
 		someHash:	sha256.New(),
 	}
+	if version >= 2 {
+		closure.someFileHash = sha256.New()
+	}
 
 	err := filepath.Walk(osDirname, func(osPathname string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -242,10 +273,19 @@ func DigestFromDirectory(osDirname string) (VersionedDigest, error) {
 			return errors.Wrap(err, "cannot Open")
 		}
 
-		var bytesWritten int64
-		bytesWritten, err = io.CopyBuffer(closure.someHash, newLineEndingReader(fh), closure.someCopyBufer)	// fast copy of file contents to hash
-		err = errors.Wrap(err, "cannot Copy")									// errors.Wrap only wraps non-nil, so skip extra check
-		writeBytesWithNull(closure.someHash, []byte(strconv.FormatInt(bytesWritten, 10)))			// 10: format file size as base 10 integer
+		if closure.someFileHash != nil {
+			// The digest of the contents has a fixed size, so it cannot be
+			// confused with the nodes that follow.
+			closure.someFileHash.Reset()
+			_, err = io.CopyBuffer(closure.someFileHash, newLineEndingReader(fh), closure.someCopyBufer)
+			err = errors.Wrap(err, "cannot Copy")
+			_, _ = closure.someHash.Write(closure.someFileHash.Sum(nil))
+		} else {
+			var bytesWritten int64
+			bytesWritten, err = io.CopyBuffer(closure.someHash, newLineEndingReader(fh), closure.someCopyBufer)	// fast copy of file contents to hash
+			err = errors.Wrap(err, "cannot Copy")									// errors.Wrap only wraps non-nil, so skip extra check
+			writeBytesWithNull(closure.someHash, []byte(strconv.FormatInt(bytesWritten, 10)))			// 10: format file size as base 10 integer
+		}
 
 		// Close the file handle to the open file without masking
 		// possible previous error value.
@@ -260,7 +300,7 @@ func DigestFromDirectory(osDirname string) (VersionedDigest, error) {
 	}
 
 	return VersionedDigest{
-		HashVersion:	HashVersion,
+		HashVersion:	version,
 		Digest:		closure.someHash.Sum(nil),
 	}, nil
 }
@@ -292,8 +332,8 @@ const (
 	DigestMismatchInLock
 
 	// HashVersionMismatch indicates that the hashing algorithm used to generate
-	// the digest being compared against is not the same as the one used by the
-	// current program.
+	// the digest being compared against is not supported by the current
+	// program.
 	HashVersionMismatch
 )
 
@@ -373,7 +413,15 @@ func ParseVersionedDigest(input string) (VersionedDigest, error) {
 // platform where the file system path separator is a character other than
 // solidus, one particular dependency would be represented as
 // "github.com/alice/alice1".
+//
+// The digests of the projects are computed concurrently.
 func CheckDepTree(osDirname string, wantDigests map[string]VersionedDigest) (map[string]VendorStatus, error) {
+	return CheckDepTreeCached(osDirname, wantDigests, nil)
+}
+
+// CheckDepTreeCached is like CheckDepTree, but reads the digests of projects
+// that did not change from dc, if it is not nil.
+func CheckDepTreeCached(osDirname string, wantDigests map[string]VersionedDigest, dc *DigestCache) (map[string]VendorStatus, error) {
 	osDirname = filepath.Clean(osDirname)
 
 	// Create associative array to store the results of calling this function.
@@ -448,6 +496,10 @@ func CheckDepTree(osDirname string, wantDigests map[string]VersionedDigest) (map
 		slashStatus[slashPathname] = NotInTree
 	}
 
+	// Projects whose digests must be computed, which is deferred until the
+	// traversal is complete so that they can be computed concurrently.
+	var jobs []digestJob
+
 	for len(queue) > 0 {
 		// Pop node from the top of queue (depth first traversal, reverse
 		// lexicographical order inside a directory), clearing the value stored
@@ -459,20 +511,16 @@ func CheckDepTree(osDirname string, wantDigests map[string]VersionedDigest) (map
 
 		if expectedSum, ok := wantDigests[slashPathname]; ok {
 			ls := EmptyDigestInLock
-			if expectedSum.HashVersion != HashVersion {
+			if !IsSupportedHashVersion(expectedSum.HashVersion) {
 				if !expectedSum.IsEmpty() {
 					ls = HashVersionMismatch
 				}
 			} else if len(expectedSum.Digest) > 0 {
-				projectSum, err := DigestFromDirectory(osPathname)
-				if err != nil {
-					return nil, errors.Wrap(err, "cannot compute dependency hash")
-				}
-				if bytes.Equal(projectSum.Digest, expectedSum.Digest) {
-					ls = NoMismatch
-				} else {
-					ls = DigestMismatchInLock
-				}
+				jobs = append(jobs, digestJob{
+					slashPathname:	slashPathname,
+					osPathname:	osPathname,
+					want:		expectedSum,
+				})
 			}
 			slashStatus[slashPathname] = ls
 
@@ -514,6 +562,10 @@ func CheckDepTree(osDirname string, wantDigests map[string]VersionedDigest) (map
 		}
 	}
 
+	if err := checkDigests(jobs, dc, slashStatus); err != nil {
+		return nil, errors.Wrap(err, "cannot compute dependency hash")
+	}
+
 	// Ignoring first node in the list, walk nodes from last to first. Whenever
 	// the current node is not required, but its parent is required, then the
 	// current node ought to be marked as `NotInLock`.
@@ -532,6 +584,62 @@ func CheckDepTree(osDirname string, wantDigests map[string]VersionedDigest) (map
 	return slashStatus, nil
 }
 
+// digestJob is a project whose digest must be compared with the one expected.
+type digestJob struct {
+	slashPathname	string
+	osPathname	string
+	want		VersionedDigest
+}
+
+// checkDigests computes the digests of the projects of jobs with a bounded
+// pool of workers, and records whether each matches the expected digest in
+// slashStatus.
+func checkDigests(jobs []digestJob, dc *DigestCache, slashStatus map[string]VendorStatus) error {
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(jobs) {
+		workers = len(jobs)
+	}
+
+	work := make(chan digestJob)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range work {
+				var got VersionedDigest
+				var err error
+				if dc != nil {
+					got, err = dc.DigestVersion(job.osPathname, job.want.HashVersion)
+				} else {
+					got, err = DigestFromDirectoryVersion(job.osPathname, job.want.HashVersion)
+				}
+
+				mu.Lock()
+				switch {
+				case err != nil:
+					if firstErr == nil {
+						firstErr = err
+					}
+				case bytes.Equal(got.Digest, job.want.Digest):
+					slashStatus[job.slashPathname] = NoMismatch
+				default:
+					slashStatus[job.slashPathname] = DigestMismatchInLock
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, job := range jobs {
+		work <- job
+	}
+	close(work)
+	wg.Wait()
+	return firstErr
+}
+
 // sortedChildrenFromDirname returns a lexicographically sorted list of child
 // nodes for the specified directory.
 func sortedChildrenFromDirname(osDirname string) ([]string, error) {
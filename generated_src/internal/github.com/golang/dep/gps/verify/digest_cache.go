@@ -0,0 +1,256 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package verify
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/nightlyone/lockfile"
+	"github.com/pkg/errors"
+)
+
+// DigestCacheFilename is a versioned filename for digest caches. The version
+// must be incremented whenever incompatible changes are made.
+const DigestCacheFilename = "digests-v1.json"
+
+// digestCacheRacyWindow is how long after being checked a file must have last
+// been modified for its metadata to be trusted. It accounts for the coarse
+// modification times of some file systems, so that a file that is modified
+// while, or shortly after, its directory is digested is always digested again.
+const digestCacheRacyWindow = 2 * time.Second
+
+// digestCacheLockTimeout is how long Save waits for other processes to finish
+// saving the same cache file.
+const digestCacheLockTimeout = 10 * time.Second
+
+// digestCacheSaveMu serializes saves within the process, which the lock file
+// does not, because it is owned by the process rather than by a DigestCache.
+var digestCacheSaveMu sync.Mutex
+
+// DigestCache is a persistent cache of the digests of directories, such as the
+// projects in a vendor directory. A cached digest is only used if the path,
+// type, size and modification time of every node in the directory are the same
+// as when it was computed, so that unchanged directories are not read again.
+//
+// A DigestCache is safe for concurrent use.
+type DigestCache struct {
+	path	string
+	mu	sync.Mutex
+	entries	map[string]digestCacheEntry
+	dirty	bool
+}
+
+type digestCacheEntry struct {
+	// Stat is a hash of the metadata of the nodes of the directory.
+	Stat	string
+	// Checked is the time at which Stat was computed, in nanoseconds since
+	// the Unix epoch.
+	Checked	int64
+	Digest	string
+}
+
+// OpenDigestCache opens the digest cache stored in the file at path. The cache
+// is empty if the file does not exist or cannot be parsed.
+func OpenDigestCache(path string) (*DigestCache, error) {
+	c := &DigestCache{
+		path:		path,
+		entries:	make(map[string]digestCacheEntry),
+	}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read digest cache %s", path)
+	}
+	if err := json.Unmarshal(b, &c.entries); err != nil {
+		// The cache is rebuilt as needed.
+		c.entries = make(map[string]digestCacheEntry)
+	}
+	return c, nil
+}
+
+// Digest returns the digest of a directory, as DigestFromDirectory does, from
+// the cache if the directory did not change since its digest was cached.
+func (c *DigestCache) Digest(osDirname string) (VersionedDigest, error) {
+	return c.DigestVersion(osDirname, HashVersion)
+}
+
+// DigestVersion is like Digest, but returns a digest of the specified hash
+// version, as DigestFromDirectoryVersion does. Only the digest of the version
+// that was requested last is cached for each directory.
+func (c *DigestCache) DigestVersion(osDirname string, version int) (VersionedDigest, error) {
+	osDirname, err := filepath.Abs(filepath.Clean(osDirname))
+	if err != nil {
+		return VersionedDigest{}, err
+	}
+
+	checked := time.Now()
+	stat, latest, err := statDigest(osDirname)
+	if err != nil {
+		return VersionedDigest{}, err
+	}
+
+	c.mu.Lock()
+	e, ok := c.entries[osDirname]
+	c.mu.Unlock()
+	if ok && e.Stat == stat && latest.Before(time.Unix(0, e.Checked).Add(-digestCacheRacyWindow)) {
+		if vd, err := ParseVersionedDigest(e.Digest); err == nil && vd.HashVersion == version {
+			return vd, nil
+		}
+	}
+
+	vd, err := DigestFromDirectoryVersion(osDirname, version)
+	if err != nil {
+		return VersionedDigest{}, err
+	}
+	c.mu.Lock()
+	c.entries[osDirname] = digestCacheEntry{
+		Stat:		stat,
+		Checked:	checked.UnixNano(),
+		Digest:		vd.String(),
+	}
+	c.dirty = true
+	c.mu.Unlock()
+	return vd, nil
+}
+
+// Save writes the cache to its file if it changed since it was opened. The
+// file may be shared, for example by dep runs on different projects, so the
+// entries that were saved by others in the meantime are kept unless they are
+// older than those of c. The file is locked while it is merged and written.
+func (c *DigestCache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty {
+		return nil
+	}
+
+	digestCacheSaveMu.Lock()
+	defer digestCacheSaveMu.Unlock()
+	unlock, err := lockDigestCache(c.path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if b, err := ioutil.ReadFile(c.path); err == nil {
+		var saved map[string]digestCacheEntry
+		if json.Unmarshal(b, &saved) == nil {
+			for dir, e := range saved {
+				if ours, ok := c.entries[dir]; !ok || e.Checked > ours.Checked {
+					c.entries[dir] = e
+				}
+			}
+		}
+	}
+
+	// Forget directories that no longer exist.
+	for dir := range c.entries {
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			delete(c.entries, dir)
+		}
+	}
+	b, err := json.Marshal(c.entries)
+	if err != nil {
+		return err
+	}
+
+	// Write atomically, so that concurrent readers never see a partial cache.
+	tmp, err := ioutil.TempFile(filepath.Dir(c.path), filepath.Base(c.path))
+	if err != nil {
+		return errors.Wrap(err, "failed to write digest cache")
+	}
+	_, err = tmp.Write(b)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return errors.Wrap(err, "failed to write digest cache")
+	}
+	c.dirty = false
+	return nil
+}
+
+// lockDigestCache locks the digest cache file at path against saves by other
+// processes and returns a function that unlocks it. It waits for up to
+// digestCacheLockTimeout for the lock to be released.
+func lockDigestCache(path string) (func(), error) {
+	abs, err := filepath.Abs(path + ".lock")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to lock digest cache")
+	}
+	lf, err := lockfile.New(abs)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to lock digest cache")
+	}
+
+	deadline := time.Now().Add(digestCacheLockTimeout)
+	for {
+		err = lf.TryLock()
+		if err == nil {
+			return func() { lf.Unlock() }, nil
+		}
+		if terr, ok := err.(lockfile.TemporaryError); !ok || !terr.Temporary() || time.Now().After(deadline) {
+			return nil, errors.Wrapf(err, "failed to lock digest cache %s", path)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// statDigest returns a hash of the relative path, type, size and modification
+// time of every node that DigestFromDirectory reads in a directory, and the
+// latest modification time among them.
+func statDigest(osDirname string) (string, time.Time, error) {
+	h := sha256.New()
+	var latest time.Time
+	buf := make([]byte, 8)
+	dirLen := len(osDirname) + len(osPathSeparator)
+	err := filepath.Walk(osDirname, func(osPathname string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return nil
+		}
+
+		var osRelative string
+		if len(osPathname) > dirLen {
+			osRelative = osPathname[dirLen:]
+		}
+		switch filepath.Base(osRelative) {
+		case "vendor", ".bzr", ".git", ".hg", ".svn":
+			return filepath.SkipDir
+		}
+
+		writeBytesWithNull(h, []byte(filepath.ToSlash(osRelative)))
+		binary.LittleEndian.PutUint32(buf, uint32(info.Mode()&os.ModeType))
+		writeBytesWithNull(h, buf[:4])
+		binary.LittleEndian.PutUint64(buf, uint64(info.Size()))
+		writeBytesWithNull(h, buf)
+		binary.LittleEndian.PutUint64(buf, uint64(info.ModTime().UnixNano()))
+		writeBytesWithNull(h, buf)
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+		return nil
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), latest, nil
+}
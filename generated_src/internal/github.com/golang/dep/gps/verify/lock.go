@@ -9,10 +9,11 @@ import (
 )
 
 // VerifiableProject composes a LockedProject to indicate what the hash digest
-// of a file tree for that LockedProject should be, given the PruneOptions and
-// the list of packages.
+// of a file tree for that LockedProject should be, given the PruneOptions, the
+// PruneParams and the list of packages.
 type VerifiableProject struct {
 	gps.LockedProject
 	PruneOpts	gps.PruneOptions
+	PruneParams	gps.PruneParams
 	Digest		VersionedDigest
 }
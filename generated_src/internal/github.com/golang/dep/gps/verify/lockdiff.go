@@ -58,6 +58,7 @@ type LockedProjectPropertiesDelta struct {
 	RevisionBefore, RevisionAfter		gps.Revision
 	SourceBefore, SourceAfter		string
 	PruneOptsBefore, PruneOptsAfter		gps.PruneOptions
+	PruneParamsBefore, PruneParamsAfter	gps.PruneParams
 	HashVersionBefore, HashVersionAfter	int
 	HashChanged				bool
 }
@@ -91,8 +92,11 @@ func DiffLocks(l1, l2 gps.Lock) LockDelta {
 		lp1 := p1[i1]
 		pr1 := lp1.Ident().ProjectRoot
 
+		// The project was removed unless a match is found in the second lock,
+		// which may also have run out of projects to compare against.
 		lpd := LockedProjectDelta{
-			Name: pr1,
+			Name:		pr1,
+			ProjectRemoved:	true,
 		}
 
 		for i2 := i2next; i2 < len(p2); i2++ {
@@ -102,6 +106,7 @@ func DiffLocks(l1, l2 gps.Lock) LockDelta {
 			switch strings.Compare(string(pr1), string(pr2)) {
 			case 0:	// Found a matching project
 				lpd.LockedProjectPropertiesDelta = DiffLockedProjectProperties(lp1, lp2)
+				lpd.ProjectRemoved = false
 				i2next = i2 + 1	// Don't visit this project again
 			case +1:	// Found a new project
 				diff.ProjectDeltas[pr2] = LockedProjectDelta{
@@ -110,8 +115,7 @@ func DiffLocks(l1, l2 gps.Lock) LockDelta {
 				}
 				i2next = i2 + 1	// Don't visit this project again
 				continue	// Keep looking for a matching project
-			case -1:	// Project has been removed, handled below
-				lpd.ProjectRemoved = true
+			case -1:	// Project has been removed
 			}
 
 			break	// Done evaluating this project, move onto the next
@@ -201,6 +205,7 @@ func DiffLockedProjectProperties(lp1, lp2 gps.LockedProject) LockedProjectProper
 
 	if ok1 && ok2 {
 		ld.PruneOptsBefore, ld.PruneOptsAfter = vp1.PruneOpts, vp2.PruneOpts
+		ld.PruneParamsBefore, ld.PruneParamsAfter = vp1.PruneParams, vp2.PruneParams
 		ld.HashVersionBefore, ld.HashVersionAfter = vp1.Digest.HashVersion, vp2.Digest.HashVersion
 
 		if !bytes.Equal(vp1.Digest.Digest, vp2.Digest.Digest) {
@@ -208,10 +213,12 @@ func DiffLockedProjectProperties(lp1, lp2 gps.LockedProject) LockedProjectProper
 		}
 	} else if ok1 {
 		ld.PruneOptsBefore = vp1.PruneOpts
+		ld.PruneParamsBefore = vp1.PruneParams
 		ld.HashVersionBefore = vp1.Digest.HashVersion
 		ld.HashChanged = true
 	} else if ok2 {
 		ld.PruneOptsAfter = vp2.PruneOpts
+		ld.PruneParamsAfter = vp2.PruneParams
 		ld.HashVersionAfter = vp2.Digest.HashVersion
 		ld.HashChanged = true
 	}
@@ -398,10 +405,16 @@ func (ld LockedProjectPropertiesDelta) PackagesChanged() bool {
 	return len(ld.PackagesAdded) > 0 || len(ld.PackagesRemoved) > 0
 }
 
-// PruneOptsChanged returns true if the pruning flags for the project changed
-// between the first and second locks.
+// PruneOptsChanged returns true if the pruning flags or their parameters for
+// the project changed between the first and second locks.
 func (ld LockedProjectPropertiesDelta) PruneOptsChanged() bool {
-	return ld.PruneOptsBefore != ld.PruneOptsAfter
+	return ld.PruneOptsBefore != ld.PruneOptsAfter || !ld.PruneParamsBefore.Equal(ld.PruneParamsAfter)
+}
+
+// PruneParamsChanged returns true if the parameters of the pruning flags for
+// the project changed between the first and second locks.
+func (ld LockedProjectPropertiesDelta) PruneParamsChanged() bool {
+	return !ld.PruneParamsBefore.Equal(ld.PruneParamsAfter)
 }
 
 // HashVersionChanged returns true if the version of the hashing algorithm
@@ -354,6 +354,20 @@ var (
 // CopyDir recursively copies a directory tree, attempting to preserve permissions.
 // Source directory must exist, destination directory must *not* exist.
 func CopyDir(src, dst string) error {
+	return copyDir(src, dst, nil)
+}
+
+// CopyDirLinking is like CopyDir, but hardlinks the regular files for which
+// link returns true instead of copying them. Files are copied if hardlinking
+// fails, for example because src and dst are on different filesystems.
+//
+// Hardlinked files are shared by both trees, so link must only return true for
+// files that are never modified in place.
+func CopyDirLinking(src, dst string, link func(path string) bool) error {
+	return copyDir(src, dst, link)
+}
+
+func copyDir(src, dst string, link func(path string) bool) error {
 	src = filepath.Clean(src)
 	dst = filepath.Clean(dst)
 
@@ -389,10 +403,15 @@ func CopyDir(src, dst string) error {
 		dstPath := filepath.Join(dst, entry.Name())
 
 		if entry.IsDir() {
-			if err = CopyDir(srcPath, dstPath); err != nil {
+			if err = copyDir(srcPath, dstPath, link); err != nil {
 				return errors.Wrap(err, "copying directory failed")
 			}
 		} else {
+			if link != nil && entry.Mode().IsRegular() && link(srcPath) {
+				if err = os.Link(srcPath, dstPath); err == nil {
+					continue
+				}
+			}
 			// This will include symlinks, which is what we want when
 			// copying things.
 			if err = copyFile(srcPath, dstPath); err != nil {
@@ -55,9 +55,18 @@ type rawLockedProject struct {
 	Source		string		`toml:"source,omitempty"`
 	Packages	[]string	`toml:"packages"`
 	PruneOpts	string		`toml:"pruneopts"`
+	PrunePlatforms	[]string	`toml:"pruneplatforms,omitempty"`
+	PruneTags	[]string	`toml:"prunetags,omitempty"`
+	PruneKeep	[]string	`toml:"prunekeep,omitempty"`
+	PruneExclude	[]string	`toml:"pruneexclude,omitempty"`
 	Digest		string		`toml:"digest"`
 }
 
+// ReadLock reads a lock in the Gopkg.lock format from the provided reader.
+func ReadLock(r io.Reader) (*Lock, error) {
+	return readLock(r)
+}
+
 func readLock(r io.Reader) (*Lock, error) {
 	buf := &bytes.Buffer{}
 	_, err := buf.ReadFrom(r)
@@ -122,6 +131,12 @@ func fromRawLock(raw rawLock) (*Lock, error) {
 		}
 		// Add the vendor pruning bit so that gps doesn't get confused
 		vp.PruneOpts = po | gps.PruneNestedVendorDirs
+		vp.PruneParams = gps.PruneParams{
+			Platforms:	ld.PrunePlatforms,
+			BuildTags:	ld.PruneTags,
+			Keep:		ld.PruneKeep,
+			Exclude:	ld.PruneExclude,
+		}
 
 		l.P = append(l.P, vp)
 	}
@@ -208,6 +223,10 @@ func (l *Lock) toRaw() rawLock {
 		vp := lp.(verify.VerifiableProject)
 		ld.Digest = vp.Digest.String()
 		ld.PruneOpts = (vp.PruneOpts & ^gps.PruneNestedVendorDirs).String()
+		ld.PrunePlatforms = vp.PruneParams.Platforms
+		ld.PruneTags = vp.PruneParams.BuildTags
+		ld.PruneKeep = vp.PruneParams.Keep
+		ld.PruneExclude = vp.PruneParams.Exclude
 
 		raw.Projects = append(raw.Projects, ld)
 	}
@@ -251,6 +270,7 @@ func LockFromSolution(in gps.Solution, prune gps.CascadingPruneOptions) *Lock {
 			l.P = append(l.P, verify.VerifiableProject{
 				LockedProject:	lp,
 				PruneOpts:	prune.PruneOptionsFor(lp.Ident().ProjectRoot),
+				PruneParams:	prune.PruneParamsFor(lp.Ident().ProjectRoot),
 			})
 		}
 	}
@@ -29,6 +29,7 @@ var (
 	errInvalidRequired	= errors.Errorf("%q must be a TOML list of strings", "required")
 	errInvalidIgnored	= errors.Errorf("%q must be a TOML list of strings", "ignored")
 	errInvalidNoVerify	= errors.Errorf("%q must be a TOML list of strings", "noverify")
+	errInvalidMinimal	= errors.Errorf("%q must be a boolean", "minimal-versions")
 	errInvalidPrune		= errors.Errorf("%q must be a TOML table of booleans", "prune")
 	errInvalidPruneProject	= errors.Errorf("%q must be a TOML array of tables", "prune.project")
 	errInvalidMetadata	= errors.New("metadata should be a TOML table")
@@ -41,6 +42,11 @@ var (
 	errRootPruneContainsName	= errors.Errorf("%q should not include a name", "prune")
 	errInvalidRootPruneValue	= errors.New("root prune options must be omitted instead of being set to false")
 	errInvalidPruneProjectName	= errors.Errorf("%q in %q must be a string", "name", "prune.project")
+	errInvalidPrunePlatforms	= errors.Errorf("%q and %q in %q must be TOML lists of strings", pruneParamPlatforms, pruneParamBuildTags, "prune")
+	errPruneProjectPlatforms	= errors.Errorf("%q and %q can only be set in %q", pruneParamPlatforms, pruneParamBuildTags, "prune")
+	errPrunePlatformsRequired	= errors.Errorf("%q must be set in %q if %q is enabled", pruneParamPlatforms, "prune", pruneOptionUnusedPlatforms)
+	errInvalidPruneGlobs		= errors.Errorf("%q and %q in %q must be TOML lists of strings", pruneParamKeep, pruneParamExclude, "prune.project")
+	errRootPruneGlobs		= errors.Errorf("%q and %q can only be set in %q", pruneParamKeep, pruneParamExclude, "prune.project")
 	errNoName			= errors.New("no name provided")
 )
 
@@ -54,6 +60,10 @@ type Manifest struct {
 
 	NoVerify	[]string
 
+	// MinimalVersions makes the solver prefer the oldest versions allowed by
+	// the constraints instead of the newest.
+	MinimalVersions	bool
+
 	PruneOptions	gps.CascadingPruneOptions
 }
 
@@ -63,6 +73,7 @@ type rawManifest struct {
 	Ignored		[]string	`toml:"ignored,omitempty"`
 	Required	[]string	`toml:"required,omitempty"`
 	NoVerify	[]string	`toml:"noverify,omitempty"`
+	MinimalVersions	bool		`toml:"minimal-versions,omitempty"`
 	PruneOptions	rawPruneOptions	`toml:"prune,omitempty"`
 }
 
@@ -75,9 +86,12 @@ type rawProject struct {
 }
 
 type rawPruneOptions struct {
-	UnusedPackages	bool	`toml:"unused-packages,omitempty"`
-	NonGoFiles	bool	`toml:"non-go,omitempty"`
-	GoTests		bool	`toml:"go-tests,omitempty"`
+	UnusedPackages	bool		`toml:"unused-packages,omitempty"`
+	NonGoFiles	bool		`toml:"non-go,omitempty"`
+	GoTests		bool		`toml:"go-tests,omitempty"`
+	UnusedPlatforms	bool		`toml:"unused-platforms,omitempty"`
+	Platforms	[]string	`toml:"platforms,omitempty"`
+	BuildTags	[]string	`toml:"build-tags,omitempty"`
 
 	//Projects []map[string]interface{} `toml:"project,omitempty"`
 	Projects	[]map[string]interface{}
@@ -87,6 +101,14 @@ const (
 	pruneOptionUnusedPackages	= "unused-packages"
 	pruneOptionGoTests		= "go-tests"
 	pruneOptionNonGo		= "non-go"
+	pruneOptionUnusedPlatforms	= "unused-platforms"
+)
+
+const (
+	pruneParamPlatforms	= "platforms"
+	pruneParamBuildTags	= "build-tags"
+	pruneParamKeep		= "keep"
+	pruneParamExclude	= "exclude"
 )
 
 // Constants representing per-project prune uint8 values.
@@ -209,6 +231,10 @@ func validateManifest(s string) ([]error, error) {
 					return warns, errInvalidNoVerify
 				}
 			}
+		case "minimal-versions":
+			if _, ok := val.(bool); !ok {
+				return warns, errInvalidMinimal
+			}
 		case "prune":
 			pruneWarns, err := validatePruneOptions(val, true)
 			warns = append(warns, pruneWarns...)
@@ -230,12 +256,50 @@ func validatePruneOptions(val interface{}, root bool) (warns []error, err error)
 
 	for key, value := range val.(map[string]interface{}) {
 		switch key {
-		case pruneOptionNonGo, pruneOptionGoTests, pruneOptionUnusedPackages:
+		case pruneOptionNonGo, pruneOptionGoTests, pruneOptionUnusedPackages, pruneOptionUnusedPlatforms:
 			if option, ok := value.(bool); !ok {
 				return warns, errInvalidPruneValue
 			} else if root && !option {
 				return warns, errInvalidRootPruneValue
 			}
+		case pruneParamPlatforms, pruneParamBuildTags:
+			if !root {
+				return warns, errPruneProjectPlatforms
+			}
+			list, ok := value.([]interface{})
+			if !ok {
+				return warns, errInvalidPrunePlatforms
+			}
+			for _, elem := range list {
+				s, ok := elem.(string)
+				if !ok {
+					return warns, errInvalidPrunePlatforms
+				}
+				validate := gps.ValidatePrunePlatform
+				if key == pruneParamBuildTags {
+					validate = gps.ValidatePruneBuildTag
+				}
+				if err := validate(s); err != nil {
+					return warns, errors.Wrapf(err, "invalid %q in %q", key, "prune")
+				}
+			}
+		case pruneParamKeep, pruneParamExclude:
+			if root {
+				return warns, errRootPruneGlobs
+			}
+			list, ok := value.([]interface{})
+			if !ok {
+				return warns, errInvalidPruneGlobs
+			}
+			for _, elem := range list {
+				s, ok := elem.(string)
+				if !ok {
+					return warns, errInvalidPruneGlobs
+				}
+				if err := gps.ValidatePruneGlob(s); err != nil {
+					return warns, errors.Wrapf(err, "invalid %q in %q", key, "prune.project")
+				}
+			}
 		case "name":
 			if root {
 				warns = append(warns, errRootPruneContainsName)
@@ -267,9 +331,30 @@ func validatePruneOptions(val interface{}, root bool) (warns []error, err error)
 		}
 	}
 
+	if root && usesUnusedPlatforms(val.(map[string]interface{})) {
+		if platforms, _ := val.(map[string]interface{})[pruneParamPlatforms].([]interface{}); len(platforms) == 0 {
+			return warns, errPrunePlatformsRequired
+		}
+	}
+
 	return warns, err
 }
 
+// usesUnusedPlatforms checks if the unused platforms prune option is enabled
+// for any project by the validated prune table.
+func usesUnusedPlatforms(prunemap map[string]interface{}) bool {
+	if enabled, _ := prunemap[pruneOptionUnusedPlatforms].(bool); enabled {
+		return true
+	}
+	projects, _ := prunemap["project"].([]interface{})
+	for _, project := range projects {
+		if enabled, _ := project.(map[string]interface{})[pruneOptionUnusedPlatforms].(bool); enabled {
+			return true
+		}
+	}
+	return false
+}
+
 func checkRedundantPruneOptions(co gps.CascadingPruneOptions) (warns []error) {
 	for name, project := range co.PerProjectOptions {
 		if project.UnusedPackages != pvnone {
@@ -289,6 +374,12 @@ func checkRedundantPruneOptions(co gps.CascadingPruneOptions) (warns []error) {
 				warns = append(warns, errors.Errorf("redundant prune option %q set for %q", pruneOptionGoTests, name))
 			}
 		}
+
+		if project.UnusedPlatforms != pvnone {
+			if (co.DefaultOptions&gps.PruneUnusedPlatformFiles != 0) == (project.UnusedPlatforms == pvtrue) {
+				warns = append(warns, errors.Errorf("redundant prune option %q set for %q", pruneOptionUnusedPlatforms, name))
+			}
+		}
 	}
 
 	return warns
@@ -376,6 +467,7 @@ func fromRawManifest(raw rawManifest, buf *bytes.Buffer) (*Manifest, error) {
 	m.Ignored = raw.Ignored
 	m.Required = raw.Required
 	m.NoVerify = raw.NoVerify
+	m.MinimalVersions = raw.MinimalVersions
 
 	for i := 0; i < len(raw.Constraints); i++ {
 		name, prj, err := toProject(raw.Constraints[i])
@@ -420,6 +512,7 @@ func fromRawPruneOptions(prunemap map[string]interface{}) gps.CascadingPruneOpti
 	opts := gps.CascadingPruneOptions{
 		DefaultOptions:		gps.PruneNestedVendorDirs,
 		PerProjectOptions:	make(map[gps.ProjectRoot]gps.PruneOptionSet),
+		PerProjectParams:	make(map[gps.ProjectRoot]gps.PruneParams),
 	}
 
 	if val, has := prunemap[pruneOptionUnusedPackages]; has && val.(bool) {
@@ -431,6 +524,23 @@ func fromRawPruneOptions(prunemap map[string]interface{}) gps.CascadingPruneOpti
 	if val, has := prunemap[pruneOptionGoTests]; has && val.(bool) {
 		opts.DefaultOptions |= gps.PruneGoTestFiles
 	}
+	if val, has := prunemap[pruneOptionUnusedPlatforms]; has && val.(bool) {
+		opts.DefaultOptions |= gps.PruneUnusedPlatformFiles
+	}
+
+	strs := func(v interface{}) []string {
+		var s []string
+		for _, elem := range v.([]interface{}) {
+			s = append(s, elem.(string))
+		}
+		return s
+	}
+	if val, has := prunemap[pruneParamPlatforms]; has {
+		opts.DefaultParams.Platforms = strs(val)
+	}
+	if val, has := prunemap[pruneParamBuildTags]; has {
+		opts.DefaultParams.BuildTags = strs(val)
+	}
 
 	trinary := func(v interface{}) uint8 {
 		b := v.(bool)
@@ -445,6 +555,7 @@ func fromRawPruneOptions(prunemap map[string]interface{}) gps.CascadingPruneOpti
 			var pr gps.ProjectRoot
 			// This should be redundant, but being explicit doesn't hurt.
 			pos := gps.PruneOptionSet{NestedVendor: pvtrue}
+			var pp gps.PruneParams
 
 			for key, val := range proj.(map[string]interface{}) {
 				switch key {
@@ -456,9 +567,18 @@ func fromRawPruneOptions(prunemap map[string]interface{}) gps.CascadingPruneOpti
 					pos.GoTests = trinary(val)
 				case pruneOptionUnusedPackages:
 					pos.UnusedPackages = trinary(val)
+				case pruneOptionUnusedPlatforms:
+					pos.UnusedPlatforms = trinary(val)
+				case pruneParamKeep:
+					pp.Keep = strs(val)
+				case pruneParamExclude:
+					pp.Exclude = strs(val)
 				}
 			}
 			opts.PerProjectOptions[pr] = pos
+			if len(pp.Keep) > 0 || len(pp.Exclude) > 0 {
+				opts.PerProjectParams[pr] = pp
+			}
 		}
 	}
 
@@ -486,6 +606,12 @@ func toRawPruneOptions(co gps.CascadingPruneOptions) rawPruneOptions {
 	if (co.DefaultOptions & gps.PruneGoTestFiles) != 0 {
 		raw.GoTests = true
 	}
+
+	if (co.DefaultOptions & gps.PruneUnusedPlatformFiles) != 0 {
+		raw.UnusedPlatforms = true
+	}
+	raw.Platforms = co.DefaultParams.Platforms
+	raw.BuildTags = co.DefaultParams.BuildTags
 	return raw
 }
 
@@ -536,11 +662,12 @@ func (m *Manifest) MarshalTOML() ([]byte, error) {
 // toRaw converts the manifest into a representation suitable to write to the manifest file
 func (m *Manifest) toRaw() rawManifest {
 	raw := rawManifest{
-		Constraints:	make([]rawProject, 0, len(m.Constraints)),
-		Overrides:	make([]rawProject, 0, len(m.Ovr)),
-		Ignored:	m.Ignored,
-		Required:	m.Required,
-		NoVerify:	m.NoVerify,
+		Constraints:		make([]rawProject, 0, len(m.Constraints)),
+		Overrides:		make([]rawProject, 0, len(m.Ovr)),
+		Ignored:		m.Ignored,
+		Required:		m.Required,
+		NoVerify:		m.NoVerify,
+		MinimalVersions:	m.MinimalVersions,
 	}
 
 	for n, prj := range m.Constraints {
@@ -6,6 +6,7 @@ package dep
 
 import (
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
 	"sort"
@@ -122,6 +123,11 @@ type Project struct {
 	VendorStatus	map[string]verify.VendorStatus
 	// The error, if any, from checking vendor.
 	CheckVendorErr	error
+	// The cache of the digests of vendored projects, if enabled.
+	DigestCache	*verify.DigestCache	// Optional
+	// The logger for warnings that do not fail an operation, such as failing
+	// to save DigestCache.
+	Logger	*log.Logger	// Optional
 }
 
 // VerifyVendor checks the vendor directory against the hash digests in
@@ -145,7 +151,14 @@ func (p *Project) VerifyVendor() (map[string]verify.VendorStatus, error) {
 			sums[string(lp.Ident().ProjectRoot)] = lp.(verify.VerifiableProject).Digest
 		}
 
-		p.VendorStatus, p.CheckVendorErr = verify.CheckDepTree(vendorDir, sums)
+		p.VendorStatus, p.CheckVendorErr = verify.CheckDepTreeCached(vendorDir, sums, p.DigestCache)
+		if p.DigestCache != nil {
+			// Failing to save the cache only means that digests are computed
+			// again next time.
+			if err := p.DigestCache.Save(); err != nil && p.Logger != nil {
+				p.Logger.Printf("Warning: %s\n", err)
+			}
+		}
 	})
 
 	return p.VendorStatus, p.CheckVendorErr
@@ -173,6 +186,7 @@ func (p *Project) MakeParams() gps.SolveParameters {
 
 	if p.Manifest != nil {
 		params.Manifest = p.Manifest
+		params.Downgrade = p.Manifest.MinimalVersions
 	}
 
 	// It should be impossible for p.ChangedLock to be nil if p.Lock is non-nil;
@@ -586,8 +586,9 @@ func (dw *DeltaWriter) Write(path string, sm gps.SourceManager, examples bool, l
 			fmt.Fprintf(os.Stderr, "Internal error - %s had change code %v but was not in new Gopkg.lock. Re-running dep ensure should fix this. Please file a bug at https://github.com/golang/dep/issues/new!\n", pr, reason)
 			continue
 		}
-		po := proj.(verify.VerifiableProject).PruneOpts
-		if err := sm.ExportPrunedProject(context.TODO(), projs[pr], po, to); err != nil {
+		vp := proj.(verify.VerifiableProject)
+		po, pp := vp.PruneOpts, vp.PruneParams
+		if err := sm.ExportPrunedProject(context.TODO(), projs[pr], po, pp, to); err != nil {
 			return errors.Wrapf(err, "failed to export %s", pr)
 		}
 
@@ -614,6 +615,7 @@ func (dw *DeltaWriter) Write(path string, sm gps.SourceManager, examples bool, l
 				dw.lock.P[k] = verify.VerifiableProject{
 					LockedProject:	lp,
 					PruneOpts:	po,
+					PruneParams:	pp,
 					Digest:		digest,
 				}
 			}
@@ -159,7 +159,7 @@ func TestDepVerifyApplyFalseFails(t *testing.T) {
 	runPluginCleanup, err = pluginapitester.RunPlugin(pluginapitester.NewPluginProvider(pluginPath), nil, "dep", []string{"--verify"}, projectDir, false, outputBuf)
 	defer runPluginCleanup()
 	require.Error(t, err)
-	assert.Equal(t, "Error: # Gopkg.lock is out of sync:\ngithub.com/pkg/errors: imported or required, but missing from Gopkg.lock's input-imports\n\n", outputBuf.String())
+	assert.Equal(t, "Error: Gopkg.lock is out of sync with imports and Gopkg.toml:\n  github.com/pkg/errors: imported or required, but missing from Gopkg.lock's input-imports\n", outputBuf.String())
 }
 
 func TestDepVerifyApplyFalseExecErrorFails(t *testing.T) {
@@ -213,3 +213,95 @@ func TestDepVerifyApplyFalseExecErrorFails(t *testing.T) {
 %s:1:23: expected 'STRING', found github
 `, files["foo.go"].Path), outputBuf.String())
 }
+
+func TestDepStatusMissing(t *testing.T) {
+	pluginPath, err := products.Bin("dep-plugin")
+	require.NoError(t, err)
+
+	projectDir, cleanup, err := dirs.TempDir(".", "")
+	require.NoError(t, err)
+	defer cleanup()
+
+	origWd, err := os.Getwd()
+	require.NoError(t, err)
+	defer func() {
+		err = os.Chdir(origWd)
+		require.NoError(t, err)
+	}()
+	err = os.Chdir(projectDir)
+	require.NoError(t, err)
+
+	err = os.MkdirAll(path.Join(projectDir, "godel", "config"), 0755)
+	require.NoError(t, err)
+	err = ioutil.WriteFile(path.Join(projectDir, "godel", "config", "godel.yml"), []byte(godelYML), 0644)
+	require.NoError(t, err)
+
+	outputBuf := &bytes.Buffer{}
+	runPluginCleanup, err := pluginapitester.RunPlugin(pluginapitester.NewPluginProvider(pluginPath), nil, "run-dep", []string{"init"}, projectDir, false, outputBuf)
+	defer runPluginCleanup()
+	require.NoError(t, err, "Output: %s", outputBuf.String())
+
+	specs := []gofiles.GoFileSpec{
+		{
+			RelPath: "foo.go",
+			Src:     `package foo; import _ "github.com/pkg/errors";`,
+		},
+	}
+
+	_, err = gofiles.Write(projectDir, specs)
+	require.NoError(t, err)
+
+	outputBuf = &bytes.Buffer{}
+	runPluginCleanup, err = pluginapitester.RunPlugin(pluginapitester.NewPluginProvider(pluginPath), nil, "dep-status", []string{"--missing", "--json"}, projectDir, false, outputBuf)
+	defer runPluginCleanup()
+	require.NoError(t, err, "Output: %s", outputBuf.String())
+	assert.Equal(t, `[{"ProjectRoot":"github.com/pkg/errors","MissingPackages":["github.com/pkg/errors"]}]`+"\n", outputBuf.String())
+}
+
+func TestUpgradeConfig(t *testing.T) {
+	pluginPath, err := products.Bin("dep-plugin")
+	require.NoError(t, err)
+	pluginProvider := pluginapitester.NewPluginProvider(pluginPath)
+
+	pluginapitester.RunUpgradeConfigTest(t,
+		pluginProvider,
+		nil,
+		[]pluginapitester.UpgradeConfigTestCase{
+			{
+				Name: "valid v0 config works",
+				ConfigFiles: map[string]string{
+					"godel/config/godel.yml": godelYML,
+					"godel/config/dep-plugin.yml": `
+version: 0
+ensure-args:
+  - -v
+cache-dir: /tmp/dep-cache
+cache-age: 24h
+verify:
+  strategy: lock-only
+  noverify:
+    - github.com/pkg/errors
+status:
+  format: json
+`,
+				},
+				WantOutput: "",
+				WantFiles: map[string]string{
+					"godel/config/dep-plugin.yml": `
+version: 0
+ensure-args:
+  - -v
+cache-dir: /tmp/dep-cache
+cache-age: 24h
+verify:
+  strategy: lock-only
+  noverify:
+    - github.com/pkg/errors
+status:
+  format: json
+`,
+				},
+			},
+		},
+	)
+}
@@ -7,19 +7,12 @@ package main
 import (
 	"os"
 
-	"github.com/palantir/amalgomate/amalgomated"
 	"github.com/palantir/godel/framework/pluginapi/v2/pluginapi"
 
 	"github.com/palantir/godel-dep-plugin/cmd"
-	"github.com/palantir/godel-dep-plugin/generated_src"
 )
 
 func main() {
-	if len(os.Args) >= 2 && os.Args[1] == amalgomated.ProxyCmdPrefix+"dep" {
-		os.Args = append(os.Args[:1], os.Args[2:]...)
-		amalgomateddep.Instance().Run("dep")
-		os.Exit(0)
-	}
 	if ok := pluginapi.InfoCmd(os.Args, os.Stdout, cmd.PluginInfo); ok {
 		return
 	}